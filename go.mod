@@ -3,15 +3,17 @@ module github.com/GerardPolloRebozado/navifetch
 go 1.25.5
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/joho/godotenv v1.5.1
 	github.com/torabit/itunes v0.0.0-20230702053550-80ae037e7f4f
+	github.com/twoscott/gobble-fm v1.0.9
 	go.uploadedlobster.com/mbtypes v0.4.0
 	go.uploadedlobster.com/musicbrainzws2 v0.18.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/go-resty/resty/v2 v2.17.1 // indirect
 	github.com/google/go-querystring v1.2.0 // indirect
-	github.com/twoscott/gobble-fm v1.0.9 // indirect
 	golang.org/x/net v0.48.0 // indirect
 )
@@ -0,0 +1,307 @@
+package api
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/health"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/service"
+)
+
+//go:embed admin
+var adminFiles embed.FS
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// AdminUI serves the admin UI's static files. The page holds no data, everything it shows comes from the
+// admin API, so it needs no authentication itself.
+func AdminUI() http.Handler {
+	files, err := fs.Sub(adminFiles, "admin")
+	if err != nil {
+		panic(err)
+	}
+	fileServer := http.FileServerFS(files)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'self'")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("Cache-Control", "no-cache")
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// AdminOpenAPI serves the OpenAPI description of the admin API. Like the UI it holds no data, so it needs no
+// authentication.
+func (h *Handler) AdminOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(openAPISpec)
+}
+
+// adminOnly answers requests with another method than method, and those without the credentials of a
+// Navidrome admin in their Subsonic auth parameters, before they reach fn.
+func (h *Handler) adminOnly(method string, fn func(ctx context.Context, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		if !h.requireAdmin(ctx, w, service.ClientAuthQuery(r.URL.Query())) {
+			return
+		}
+		if r.Method != method {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fn(ctx, w, r)
+	}
+}
+
+// AdminLogin trades the username and password posted by the admin UI for Subsonic token auth parameters,
+// after checking they belong to a Navidrome admin, so the UI never keeps the password.
+func (h *Handler) AdminLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	if r.PostForm.Get("u") == "" || r.PostForm.Get("p") == "" {
+		http.Error(w, "Missing u or p", http.StatusBadRequest)
+		return
+	}
+	auth := service.ClientAuthQuery(url.Values{
+		"u": {r.PostForm.Get("u")},
+		"p": {r.PostForm.Get("p")},
+		"v": {model.APIVersion},
+		"c": {"navifetch-admin"},
+	})
+	if !h.requireAdmin(ctx, w, auth) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"u": auth.Get("u"),
+		"t": auth.Get("t"),
+		"s": auth.Get("s"),
+		"v": auth.Get("v"),
+		"c": auth.Get("c"),
+	})
+}
+
+// AdminJobs lists the running downloads and the last finished ones with their logs, or returns the one
+// with the given id.
+func (h *Handler) AdminJobs(_ context.Context, w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusOK, h.streamService.Jobs())
+		return
+	}
+	job, ok := h.streamService.Job(id)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// AdminDownload starts downloading the external song with the given id, or the metadata provider's best
+// match for query, and returns its job without waiting for it. Songs are kept for good unless permanent is
+// false.
+func (h *Handler) AdminDownload(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	permanent := true
+	if value := q.Get("permanent"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid permanent", http.StatusBadRequest)
+			return
+		}
+		permanent = parsed
+	}
+
+	id := q.Get("id")
+	switch {
+	case id != "" && q.Get("query") != "":
+		http.Error(w, "Pass either id or query", http.StatusBadRequest)
+		return
+	case id != "":
+		if !strings.HasPrefix(id, "external-") {
+			http.Error(w, "Only external songs can be downloaded", http.StatusBadRequest)
+			return
+		}
+	case q.Get("query") != "":
+		songs, err := h.metadata.SearchSongs(ctx, q.Get("query"))
+		if err != nil {
+			slog.ErrorContext(ctx, "Download search error", "query", q.Get("query"), "err", err)
+			http.Error(w, "Failed to search the metadata provider", http.StatusBadGateway)
+			return
+		}
+		if len(songs) == 0 {
+			http.Error(w, "No song matches the query", http.StatusNotFound)
+			return
+		}
+		id = songs[0].ID
+	default:
+		http.Error(w, "Missing id or query", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.streamService.StartDownload(ctx, strings.TrimPrefix(id, "external-"), permanent)
+	if errors.Is(err, service.ErrShuttingDown) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Download start error", "id", id, "err", err)
+		http.Error(w, "Failed to start the download", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// AdminCancelJob stops a running download.
+func (h *Handler) AdminCancelJob(_ context.Context, w http.ResponseWriter, r *http.Request) {
+	err := h.streamService.CancelJob(r.URL.Query().Get("id"))
+	switch {
+	case errors.Is(err, service.ErrJobNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrJobFinished):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// AdminRetryJob downloads the song of a failed, cancelled or finished job again and returns the new job.
+func (h *Handler) AdminRetryJob(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	job, err := h.streamService.RetryJob(ctx, r.URL.Query().Get("id"))
+	switch {
+	case errors.Is(err, service.ErrJobNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrJobRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrShuttingDown):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		slog.ErrorContext(ctx, "Download retry error", "err", err)
+		http.Error(w, "Failed to start the download", http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// AdminTracks lists the downloaded tracks with their external and Navidrome IDs.
+func (h *Handler) AdminTracks(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.streamService.Tracks().Tracks())
+}
+
+// AdminCache reports the size of the streaming-only cache and lists its tracks.
+func (h *Handler) AdminCache(ctx context.Context, w http.ResponseWriter, _ *http.Request) {
+	usage, err := h.streamService.Cache()
+	if err != nil {
+		slog.ErrorContext(ctx, "Error reading the cache", "err", err)
+		http.Error(w, "Failed to read the cache", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// AdminEvict removes the cached track at path, relative to the cache folder.
+func (h *Handler) AdminEvict(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	err := h.streamService.Evict(r.URL.Query().Get("path"))
+	if errors.Is(err, service.ErrNotCached) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Cache evict error", "err", err)
+		http.Error(w, "Failed to remove the track", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminPromote keeps the cached track at path, relative to the cache folder, or the one downloaded for the
+// external id for good, moving it to the downloads folder and asking Navidrome to rescan with the admin's
+// credentials.
+func (h *Handler) AdminPromote(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var target string
+	var err error
+	if id := q.Get("id"); id != "" {
+		target, err = h.streamService.PromoteTrack(id)
+	} else {
+		target, err = h.streamService.Promote(q.Get("path"))
+	}
+	if errors.Is(err, service.ErrNotCached) || errors.Is(err, service.ErrTrackNotFound) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Cache promote error", "err", err)
+		http.Error(w, "Failed to move the track", http.StatusInternalServerError)
+		return
+	}
+	if err := service.StartScan(ctx, h.rp, service.ClientAuthQuery(q)); err != nil {
+		slog.WarnContext(ctx, "Rescan after promotion failed", "err", err)
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"path": target})
+}
+
+// AdminDeleteTrack removes the file downloaded for the external id and asks Navidrome to rescan, so the
+// track disappears from the library.
+func (h *Handler) AdminDeleteTrack(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	_, err := h.streamService.DeleteTrack(q.Get("id"))
+	if errors.Is(err, service.ErrTrackNotFound) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Track delete error", "err", err)
+		http.Error(w, "Failed to remove the track", http.StatusInternalServerError)
+		return
+	}
+	if err := service.StartScan(ctx, h.rp, service.ClientAuthQuery(q)); err != nil {
+		slog.WarnContext(ctx, "Rescan after delete failed", "err", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminCleanup runs the cleanup job now instead of waiting for cache.cleanup_interval.
+func (h *Handler) AdminCleanup(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+	evicted := service.CleanupJob(h.cfg.Get(), h.streamService.Tracks())
+	writeJSON(w, http.StatusOK, map[string]int{"evicted": evicted})
+}
+
+// AdminProviders reports the configured providers and how each provider has been answering.
+func (h *Handler) AdminProviders(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+	cfg := h.cfg.Get()
+	enrichment := cfg.Providers.Enrichment
+	if enrichment == "" {
+		enrichment = cfg.Providers.Metadata
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"metadata":   cfg.Providers.Metadata,
+		"enrichment": enrichment,
+		"lyrics":     cfg.Providers.Lyrics,
+		"providers":  health.Providers(),
+	})
+}
+
+// AdminSearches lists the last searches clients made.
+func (h *Handler) AdminSearches(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.searchService.Recent())
+}
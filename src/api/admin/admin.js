@@ -0,0 +1,204 @@
+// Navifetch admin UI. It logs in through /api/admin/login, which trades the password for Subsonic token
+// auth parameters kept in sessionStorage, and refreshes every view from the admin API every few seconds.
+"use strict";
+
+const refreshInterval = 5000;
+let auth = JSON.parse(sessionStorage.getItem("navifetch-auth") || "null");
+let timer = null;
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, text, className) {
+  const node = document.createElement(tag);
+  if (text !== undefined && text !== null) node.textContent = String(text);
+  if (className) node.className = className;
+  return node;
+}
+
+function row(cells) {
+  const tr = el("tr");
+  for (const cell of cells) {
+    const td = el("td");
+    if (cell instanceof Node) td.append(cell);
+    else td.textContent = cell ?? "";
+    tr.append(td);
+  }
+  return tr;
+}
+
+function when(value) {
+  return value ? new Date(value).toLocaleString() : "";
+}
+
+function size(bytes) {
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let n = bytes;
+  let unit = 0;
+  while (n >= 1024 && unit < units.length - 1) {
+    n /= 1024;
+    unit++;
+  }
+  return `${n.toFixed(unit ? 1 : 0)} ${units[unit]}`;
+}
+
+async function api(path, method = "GET", params = {}) {
+  const query = new URLSearchParams({ ...auth, ...params });
+  const resp = await fetch(`/api/${path}?${query}`, { method });
+  if (resp.status === 401 || resp.status === 403) {
+    logout();
+    throw new Error("Your session is no longer valid, log in again");
+  }
+  if (!resp.ok) throw new Error(`${path}: ${(await resp.text()).trim() || resp.status}`);
+  return resp.status === 204 ? null : resp.json();
+}
+
+function jobButton(text, path, job) {
+  const button = el("button", text);
+  button.type = "button";
+  button.onclick = () => act(() => api(path, "POST", { id: job.id }));
+  return button;
+}
+
+function renderJobs(container, jobs) {
+  container.replaceChildren();
+  if (jobs.length === 0) container.append(el("p", "None", "muted"));
+  for (const job of jobs) {
+    const details = el("details");
+    const name = job.title ? `${job.artist} - ${job.title}` : job.trackId;
+    const summary = el("summary", `${name} `);
+    summary.append(el("span", `${job.permanent ? "download" : "stream"}, started ${when(job.startedAt)} `, "muted"));
+    if (job.status === "running") summary.append(jobButton("Cancel", "admin/jobs/cancel", job));
+    else if (job.status !== "done") summary.append(jobButton("Retry", "admin/jobs/retry", job));
+    details.append(summary);
+    if (job.error) details.append(el("p", job.error, "error"));
+    else if (job.status === "cancelled") details.append(el("p", "Cancelled", "muted"));
+    details.append(el("pre", job.log.join("\n")));
+    container.append(details);
+  }
+}
+
+async function refreshDownloads() {
+  const [jobs, wanted] = await Promise.all([api("admin/jobs"), api("wanted")]);
+  renderJobs($("jobs-running"), jobs.filter((j) => j.status === "running"));
+  renderJobs($("jobs-failed"), jobs.filter((j) => j.status === "failed" || j.status === "cancelled"));
+  renderJobs($("jobs-done"), jobs.filter((j) => j.status === "done"));
+
+  const queued = $("queued");
+  queued.replaceChildren();
+  for (const item of wanted.filter((i) => i.status !== "done")) {
+    const next = item.status === "failed" ? el("span", "gave up ", "error") : el("span", `${when(item.nextAttempt)} `);
+    const retry = el("button", "Retry now");
+    retry.type = "button";
+    retry.onclick = () => act(() => api("wanted/retry", "POST", { key: item.key }));
+    next.append(retry);
+    queued.append(row([item.kind, item.name, item.attempts.length, next]));
+  }
+}
+
+async function refreshTracks() {
+  const tracks = await api("admin/tracks");
+  const list = $("track-list");
+  list.replaceChildren();
+  for (const t of tracks) {
+    list.append(row([t.externalId, t.navidromeId || el("span", "not indexed yet", "muted"), `${t.artist} - ${t.title}`,
+      t.permanent ? "permanently" : "cached", when(t.downloadedAt)]));
+  }
+}
+
+async function refreshCache() {
+  const cache = await api("admin/cache");
+  $("cache-usage").textContent = `${cache.files} files, ${size(cache.bytes)}, tracks are kept for ${cache.maxAge}`;
+  const list = $("cache-list");
+  list.replaceChildren();
+  for (const t of cache.tracks) {
+    const actions = el("span");
+    const promote = el("button", "Keep");
+    promote.type = "button";
+    promote.title = "Move to the downloads folder, where it's never removed";
+    promote.onclick = () => act(() => api("admin/cache/promote", "POST", { path: t.path }));
+    const evict = el("button", "Evict");
+    evict.type = "button";
+    evict.onclick = () => act(() => api("admin/cache/evict", "POST", { path: t.path }));
+    actions.append(promote, evict);
+    list.append(row([t.title ? `${t.artist} - ${t.title}` : t.path, size(t.bytes), when(t.expiresAt), actions]));
+  }
+}
+
+async function refreshProviders() {
+  const providers = await api("admin/providers");
+  $("provider-config").textContent =
+    `Metadata: ${providers.metadata}, enrichment: ${providers.enrichment}, lyrics: ${providers.lyrics || "none"}`;
+  const list = $("provider-list");
+  list.replaceChildren();
+  if (providers.providers.length === 0) list.append(row([el("span", "No calls yet", "muted")]));
+  for (const p of providers.providers) {
+    const status = p.healthy ? el("span", "OK", "ok") : el("span", "Failing", "error");
+    const lastError = p.lastError ? `${p.lastError} (${when(p.lastFailure)})` : "";
+    list.append(row([p.name, status, p.calls, p.errors, `${p.averageLatencyMs} ms`, lastError]));
+  }
+}
+
+async function refreshSearches() {
+  const searches = await api("admin/searches");
+  const list = $("search-list");
+  list.replaceChildren();
+  for (const s of searches) {
+    const results = s.error ? el("span", s.error, "error") : s.results;
+    list.append(row([s.query, s.source, results, when(s.at)]));
+  }
+}
+
+async function refresh() {
+  try {
+    await Promise.all([refreshDownloads(), refreshTracks(), refreshCache(), refreshProviders(), refreshSearches()]);
+    $("error").textContent = "";
+  } catch (err) {
+    $("error").textContent = err.message;
+  }
+}
+
+async function act(action) {
+  try {
+    await action();
+  } catch (err) {
+    $("error").textContent = err.message;
+  }
+  await refresh();
+}
+
+function show() {
+  const loggedIn = auth !== null;
+  $("login").hidden = loggedIn;
+  $("app").hidden = !loggedIn;
+  document.querySelector("nav").hidden = !loggedIn;
+  clearInterval(timer);
+  if (loggedIn) {
+    $("user").textContent = auth.u;
+    refresh();
+    timer = setInterval(refresh, refreshInterval);
+  }
+}
+
+function logout() {
+  auth = null;
+  sessionStorage.removeItem("navifetch-auth");
+  show();
+}
+
+$("login").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  $("login-error").textContent = "";
+  const resp = await fetch("/api/admin/login", { method: "POST", body: new URLSearchParams(new FormData(event.target)) });
+  if (!resp.ok) {
+    $("login-error").textContent = resp.status === 403 ? "This user is not a Navidrome admin" : "Wrong username or password";
+    return;
+  }
+  auth = await resp.json();
+  sessionStorage.setItem("navifetch-auth", JSON.stringify(auth));
+  event.target.reset();
+  show();
+});
+
+$("logout").addEventListener("click", logout);
+
+show();
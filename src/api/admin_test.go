@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/service"
+)
+
+// newAdminTestServer serves the admin API in front of a Navidrome that knows alice, an admin, and bob, a
+// regular user, and rejects everyone else.
+func newAdminTestServer(t *testing.T) *http.ServeMux {
+	t.Helper()
+	navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/getUser" {
+			_ = json.NewEncoder(w).Encode(model.OKResponse())
+			return
+		}
+		user := r.URL.Query().Get("u")
+		if user != "alice" && user != "bob" {
+			_ = json.NewEncoder(w).Encode(model.ErrorResponse(40, "Wrong username or password"))
+			return
+		}
+		var resp model.SubsonicUserResponse
+		resp.Subsonic.Envelope = model.OKEnvelope()
+		resp.Subsonic.User = &model.SubsonicUser{Username: user, AdminRole: user == "alice"}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(navidrome.Close)
+
+	rp, err := service.NewSubsonicReverseProxy(navidrome.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Server.DataPath = t.TempDir()
+	cfg.Downloader.MusicLibraryPath = t.TempDir()
+	holder := config.NewHolder(cfg)
+	h := &Handler{
+		cfg:           holder,
+		rp:            rp,
+		searchService: service.NewSearchService(rp, nil),
+		streamService: service.NewStreamService(holder, rp, nil, nil),
+	}
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, h)
+	return mux
+}
+
+func TestAdminAPI(t *testing.T) {
+	mux := newAdminTestServer(t)
+	admin := "u=alice&t=token&s=salt&"
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		form     url.Values
+		want     int
+		wantBody string
+	}{
+		{name: "no credentials", method: http.MethodGet, target: "/api/admin/jobs", want: http.StatusUnauthorized},
+		{name: "not an admin", method: http.MethodGet, target: "/api/admin/jobs?u=bob&t=token&s=salt", want: http.StatusForbidden},
+		{name: "wrong method", method: http.MethodPost, target: "/api/admin/jobs?" + admin, want: http.StatusMethodNotAllowed},
+		{name: "jobs", method: http.MethodGet, target: "/api/admin/jobs?" + admin, want: http.StatusOK, wantBody: "[]"},
+		{name: "unknown job", method: http.MethodGet, target: "/api/admin/jobs?" + admin + "id=9", want: http.StatusNotFound},
+		{name: "cancel unknown job", method: http.MethodPost, target: "/api/admin/jobs/cancel?" + admin + "id=9", want: http.StatusNotFound},
+		{name: "retry unknown job", method: http.MethodPost, target: "/api/admin/jobs/retry?" + admin + "id=9", want: http.StatusNotFound},
+		{name: "download without song", method: http.MethodPost, target: "/api/admin/jobs/download?" + admin, want: http.StatusBadRequest},
+		{name: "download library song", method: http.MethodPost, target: "/api/admin/jobs/download?" + admin + "id=nd-1", want: http.StatusBadRequest},
+		{name: "download id and query", method: http.MethodPost, target: "/api/admin/jobs/download?" + admin + "id=external-1&query=x", want: http.StatusBadRequest},
+		{name: "download invalid permanent", method: http.MethodPost, target: "/api/admin/jobs/download?" + admin + "id=external-1&permanent=maybe", want: http.StatusBadRequest},
+		{name: "tracks", method: http.MethodGet, target: "/api/admin/tracks?" + admin, want: http.StatusOK, wantBody: "[]"},
+		{name: "delete unknown track", method: http.MethodPost, target: "/api/admin/tracks/delete?" + admin + "id=external-404", want: http.StatusNotFound},
+		{name: "cache", method: http.MethodGet, target: "/api/admin/cache?" + admin, want: http.StatusOK, wantBody: `"tracks":[]`},
+		{name: "evict uncached", method: http.MethodPost, target: "/api/admin/cache/evict?" + admin + "path=missing.mp3", want: http.StatusNotFound},
+		{name: "evict outside the cache", method: http.MethodPost, target: "/api/admin/cache/evict?" + admin + "path=../downloads/a.mp3", want: http.StatusNotFound},
+		{name: "promote uncached", method: http.MethodPost, target: "/api/admin/cache/promote?" + admin + "path=missing.mp3", want: http.StatusNotFound},
+		{name: "cleanup", method: http.MethodPost, target: "/api/admin/cleanup?" + admin, want: http.StatusOK, wantBody: `"evicted":0`},
+		{name: "searches", method: http.MethodGet, target: "/api/admin/searches?" + admin, want: http.StatusOK, wantBody: "[]"},
+		{name: "openapi without credentials", method: http.MethodGet, target: "/api/admin/openapi.yaml", want: http.StatusOK, wantBody: "openapi:"},
+		{name: "login with get", method: http.MethodGet, target: "/api/admin/login", want: http.StatusMethodNotAllowed},
+		{name: "login without password", method: http.MethodPost, target: "/api/admin/login", form: url.Values{"u": {"alice"}}, want: http.StatusBadRequest},
+		{name: "login as regular user", method: http.MethodPost, target: "/api/admin/login", form: url.Values{"u": {"bob"}, "p": {"secret"}}, want: http.StatusForbidden},
+		{name: "login", method: http.MethodPost, target: "/api/admin/login", form: url.Values{"u": {"alice"}, "p": {"secret"}}, want: http.StatusOK, wantBody: `"u":"alice"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.form.Encode()))
+			if tt.form != nil {
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("got %d %s, want %d", w.Code, w.Body, tt.want)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("got %s, want it to contain %s", w.Body, tt.wantBody)
+			}
+			if strings.Contains(w.Body.String(), "secret") {
+				t.Errorf("password echoed in %s", w.Body)
+			}
+		})
+	}
+}
@@ -2,43 +2,126 @@ package api
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/logging"
+	"github.com/GerardPolloRebozado/navifetch/src/lyrics"
 	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/playlist"
 	"github.com/GerardPolloRebozado/navifetch/src/service"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
 )
 
 type Handler struct {
-	cfg           *config.Config
+	cfg           *config.Holder
 	rp            *service.SubsonicReverseProxy
-	metadata      metadata.Provider
+	metadata      *metadata.Swappable
+	enrichment    *metadata.Swappable
+	lyrics        *lyrics.Swappable
 	albumService  *service.AlbumService
+	artistService *service.ArtistService
 	searchService *service.SearchService
 	songService   *service.SongService
 	streamService *service.StreamService
+	lyricsService *service.LyricsService
+	releases      *service.ReleaseTracker
+	annotations   *service.AnnotationService
+	importer      *service.PlaylistImporter
+	exporter      *service.PlaylistExporter
+	wanted        *service.WantedList
 }
 
-func NewHandler(cfg *config.Config, rp *service.SubsonicReverseProxy) *Handler {
-	p, err := metadata.NewProvider(cfg.MetadataProvider, cfg.Country, cfg.Limit, cfg.LastFMApiKey)
+// NewHandler builds the handler and its services. Providers are rebuilt whenever cfg is reloaded and
+// swapped in under the services, which keep running.
+func NewHandler(cfg *config.Holder, rp *service.SubsonicReverseProxy) (*Handler, error) {
+	primary, enrichment, err := metadata.NewProviders(cfg.Get())
 	if err != nil {
-		log.Fatalf("Failed to initialize metadata provider: %v", err)
+		return nil, fmt.Errorf("metadata provider: %w", err)
 	}
-	return &Handler{
+	lyricsProvider, err := lyrics.NewProvider(cfg.Get())
+	if err != nil {
+		return nil, fmt.Errorf("lyrics provider: %w", err)
+	}
+	p := metadata.NewSwappable(primary)
+	enrichmentSwap := metadata.NewSwappable(enrichment)
+	lyricsSwap := lyrics.NewSwappable(lyricsProvider)
+
+	artistService := service.NewArtistService(rp, p)
+	streamService := service.NewStreamService(cfg, rp, p, lyricsSwap)
+	h := &Handler{
 		cfg:           cfg,
 		rp:            rp,
 		metadata:      p,
-		albumService:  service.NewAlbumService(rp, p),
+		enrichment:    enrichmentSwap,
+		lyrics:        lyricsSwap,
+		albumService:  service.NewAlbumService(rp, p, enrichmentSwap),
+		artistService: artistService,
 		searchService: service.NewSearchService(rp, p),
 		songService:   service.NewSongService(rp, p),
-		streamService: service.NewStreamService(cfg, p),
+		streamService: streamService,
+		lyricsService: service.NewLyricsService(rp, p, lyricsSwap),
+		releases:      service.NewReleaseTracker(cfg, rp, artistService),
+		annotations:   service.NewAnnotationService(cfg.Get(), rp, p),
+		importer:      service.NewPlaylistImporter(rp, p, streamService),
+		exporter:      service.NewPlaylistExporter(cfg, rp),
+		wanted:        service.NewWantedList(cfg.Get(), p, streamService),
+	}
+	cfg.OnReload(h.reloadProviders)
+	return h, nil
+}
+
+// reloadProviders swaps in providers built from the new configuration. Requests already talking to the
+// old providers finish with them. If the new providers can't be built the old ones stay.
+func (h *Handler) reloadProviders(_, cfg *config.Config) {
+	primary, enrichment, err := metadata.NewProviders(cfg)
+	if err != nil {
+		slog.Error("Config reload: keeping the current metadata providers", "err", err)
+		return
+	}
+	lyricsProvider, err := lyrics.NewProvider(cfg)
+	if err != nil {
+		slog.Error("Config reload: keeping the current lyrics provider", "err", err)
+		return
 	}
+	h.metadata.Swap(primary)
+	h.enrichment.Swap(enrichment)
+	h.lyrics.Swap(lyricsProvider)
+	slog.Info("Metadata provider reloaded", "provider", cfg.Providers.Metadata, "results_per_page", cfg.Providers.ResultsPerPage)
+}
+
+// Releases exposes the release tracker so main can start it alongside the other background jobs.
+func (h *Handler) Releases() *service.ReleaseTracker {
+	return h.releases
+}
+
+// Tracks exposes the index of downloaded tracks so main can hand it to the cleanup job.
+func (h *Handler) Tracks() *service.TrackIndex {
+	return h.streamService.Tracks()
+}
+
+// WantedList exposes the wanted list so main can start its download worker.
+func (h *Handler) WantedList() *service.WantedList {
+	return h.wanted
+}
+
+// Shutdown waits for the downloads in progress until ctx is done, then interrupts the rest.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	return h.streamService.Shutdown(ctx)
+}
+
+// Annotations exposes the annotation queue so main can start its replay job.
+func (h *Handler) Annotations() *service.AnnotationService {
+	return h.annotations
 }
 
 func (h *Handler) Healthz(w http.ResponseWriter, _ *http.Request) {
@@ -52,7 +135,7 @@ func (h *Handler) SmartSearch(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 12*time.Second)
 	defer cancel()
 
-	log.Printf("Search query: %s", query)
+	slog.DebugContext(ctx, "Search", "query", query)
 	if query == "\"\"" || query == "" {
 		h.rp.ServeHTTP(w, r)
 	}
@@ -77,20 +160,12 @@ func (h *Handler) ProxyMetadata(w http.ResponseWriter, r *http.Request) {
 
 		res, err := h.songService.GetSong(ctx, trackID)
 		if err != nil {
-			http.Error(w, "Song not found", http.StatusNotFound)
+			slog.ErrorContext(ctx, "GetSong error", "id", id, "err", err)
+			writeSubsonicError(w, r, 70, "Song not found")
 			return
 		}
 
-		resp := map[string]any{
-			"subsonic-response": map[string]any{
-				"status":  "ok",
-				"version": "1.16.1",
-				"song":    res,
-			},
-		}
-
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		json.NewEncoder(w).Encode(resp)
+		writeSubsonic(w, r, model.WrapResponse("song", res))
 		return
 	}
 
@@ -99,39 +174,16 @@ func (h *Handler) ProxyMetadata(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) ProxyStream(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
-	permanent := strings.Contains(r.URL.Path, "download")
+	permanent := endpointName(r.URL.Path) == "download"
 
 	if strings.HasPrefix(id, "external-") {
-		trackID := strings.TrimPrefix(id, "external-")
-		songMetadata, _, err := h.streamService.DownloadTrack(trackID, permanent)
+		foundSong, err := h.importExternal(r, id, permanent)
 		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to import", "id", id, "err", err)
 			http.Error(w, "Failed to prepare track for streaming", http.StatusInternalServerError)
 			return
 		}
 
-		subsonicUser := r.URL.Query().Get("u")
-		subsonicPass := r.URL.Query().Get("p")
-		if subsonicUser == "" && subsonicPass == "" {
-			http.Error(w, "Failed to get auth parameters", http.StatusInternalServerError)
-			return
-		}
-
-		title := strings.TrimSuffix(songMetadata.Title, " (external)")
-		artist := songMetadata.Artist
-		mbid := songMetadata.MusicBrainzId
-		if mbid == "" {
-			mbid = trackID
-			if strings.HasPrefix(mbid, "external-") {
-				mbid = strings.TrimPrefix(mbid, "external-")
-			}
-		}
-
-		foundSong, err := h.rp.FindNavidromeSongID(artist, title, mbid, r)
-		if err != nil {
-			http.Error(w, "Failed to find song in Navidrome", http.StatusInternalServerError)
-			return
-		}
-
 		// Update request with the internal Navidrome ID
 		q := r.URL.Query()
 		q.Set("id", foundSong.ID)
@@ -146,41 +198,210 @@ func (h *Handler) ProxyPlaylist(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("songIdToAdd")
 
 	if strings.HasPrefix(id, "external-") {
-		id = strings.TrimPrefix(id, "external-")
-
-		songMetadata, _, err := h.streamService.DownloadTrack(id, true)
+		foundSong, err := h.importExternal(r, id, true)
 		if err != nil {
-			http.Error(w, "Failed to prepare track for streaming", http.StatusInternalServerError)
-			return
-		}
-		subsonicUser := r.URL.Query().Get("u")
-		subsonicPass := r.URL.Query().Get("p")
-		if subsonicUser == "" && subsonicPass == "" {
-			http.Error(w, "Failed to get auth parameters", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Failed to import", "id", id, "err", err)
+			writeSubsonicError(w, r, 0, "Failed to prepare track for the playlist")
 			return
 		}
+		q := r.URL.Query()
+		q.Set("songIdToAdd", foundSong.ID)
+		r.URL.RawQuery = q.Encode()
+		h.rp.ServeHTTP(w, r)
+		return
+	}
+	h.rp.ServeHTTP(w, r)
+}
 
-		title := strings.TrimSuffix(songMetadata.Title, " (external)")
-		artist := songMetadata.Artist
-		mbid := songMetadata.MusicBrainzId
-		if mbid == "" {
-			mbid = id
-			if strings.HasPrefix(mbid, "external-") {
-				mbid = strings.TrimPrefix(mbid, "external-")
-			}
+// importExternal downloads an external song and waits for Navidrome to pick it up, replaying any ratings and
+// scrobbles that were queued while it wasn't in the library.
+func (h *Handler) importExternal(r *http.Request, id string, permanent bool) (*model.SubsonicSong, error) {
+	trackID := strings.TrimPrefix(id, "external-")
+	songMetadata, _, err := h.streamService.DownloadTrack(r.Context(), trackID, permanent)
+	if err != nil {
+		// Only downloads meant for the library are worth retrying, a failed stream is just a failed stream.
+		if permanent && !errors.Is(err, service.ErrDownloadCancelled) {
+			go h.wanted.AddFailed(id, err)
 		}
+		return nil, err
+	}
 
-		foundSong, err := h.rp.FindNavidromeSongID(artist, title, mbid, r)
+	if r.URL.Query().Get("u") == "" && r.URL.Query().Get("p") == "" {
+		return nil, fmt.Errorf("missing auth parameters")
+	}
+
+	title := strings.TrimSuffix(songMetadata.Title, " (external)")
+	artist := songMetadata.Artist
+	mbid := songMetadata.MusicBrainzId
+	if mbid == "" {
+		mbid = trackID
+	}
+
+	foundSong, err := h.rp.FindNavidromeSongID(artist, title, mbid, r)
+	if err != nil {
+		return nil, err
+	}
+	h.streamService.Tracks().Link(id, foundSong.ID)
+	go h.annotations.Imported(context.Background(), id, foundSong.ID)
+	return foundSong, nil
+}
+
+// Star imports external songs permanently before starring them, since Navidrome can only star what it has.
+func (h *Handler) Star(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ids := make([]string, 0, len(q["id"]))
+	for _, id := range q["id"] {
+		if !strings.HasPrefix(id, "external-") {
+			ids = append(ids, id)
+			continue
+		}
+		foundSong, err := h.importExternal(r, id, true)
 		if err != nil {
-			http.Error(w, "Failed to find song in Navidrome", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Failed to import for starring", "id", id, "err", err)
+			writeSubsonicError(w, r, 0, "Failed to prepare track for starring")
 			return
 		}
-		q := r.URL.Query()
-		q.Set("songIdToAdd", foundSong.ID)
-		r.URL.RawQuery = q.Encode()
+		ids = append(ids, foundSong.ID)
+	}
+	h.forwardAnnotation(w, r, q, ids)
+}
+
+// Unstar unstars external songs that made it into the library, the others were never starred.
+func (h *Handler) Unstar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	ids := make([]string, 0, len(q["id"]))
+	for _, id := range q["id"] {
+		if !strings.HasPrefix(id, "external-") {
+			ids = append(ids, id)
+			continue
+		}
+		navidromeID, err := h.annotations.Resolve(ctx, id, q)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to resolve for unstarring", "id", id, "err", err)
+		}
+		if navidromeID != "" {
+			ids = append(ids, navidromeID)
+		}
+	}
+	h.forwardAnnotation(w, r, q, ids)
+}
+
+// SetRating rates external songs already in the library directly and queues the rest until they're imported.
+func (h *Handler) SetRating(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	id := q.Get("id")
+	if !strings.HasPrefix(id, "external-") {
 		h.rp.ServeHTTP(w, r)
 		return
 	}
+
+	rating, err := strconv.Atoi(q.Get("rating"))
+	if err != nil || rating < 0 || rating > 5 {
+		writeSubsonicError(w, r, 10, "Required parameter is missing or invalid: rating must be between 0 and 5")
+		return
+	}
+	navidromeID, err := h.annotations.ResolveOrQueue(ctx, service.PendingAnnotation{
+		Kind:   service.AnnotationRating,
+		SongID: id,
+		Rating: rating,
+	}, q)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to rate", "id", id, "err", err)
+		writeSubsonicError(w, r, 70, "Song not found")
+		return
+	}
+
+	ids := []string{}
+	if navidromeID != "" {
+		ids = append(ids, navidromeID)
+	}
+	h.forwardAnnotation(w, r, q, ids)
+}
+
+// Scrobble forwards scrobbles of external songs already in the library and queues submissions of the rest.
+// "Now playing" notifications of songs Navidrome doesn't have are dropped.
+func (h *Handler) Scrobble(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	submission := q.Get("submission") != "false"
+	times := q["time"]
+	ids := make([]string, 0, len(q["id"]))
+	forwardTimes := make([]string, 0, len(times))
+	for i, id := range q["id"] {
+		scrobbleTime := ""
+		if i < len(times) {
+			scrobbleTime = times[i]
+		}
+
+		if strings.HasPrefix(id, "external-") {
+			a := service.PendingAnnotation{Kind: service.AnnotationScrobble, SongID: id}
+			a.Time, _ = strconv.ParseInt(scrobbleTime, 10, 64)
+
+			var navidromeID string
+			var err error
+			if submission {
+				navidromeID, err = h.annotations.ResolveOrQueue(ctx, a, q)
+			} else {
+				navidromeID, err = h.annotations.Resolve(ctx, id, q)
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to scrobble", "id", id, "err", err)
+			}
+			if navidromeID == "" {
+				continue
+			}
+			id = navidromeID
+		}
+
+		ids = append(ids, id)
+		if scrobbleTime != "" {
+			forwardTimes = append(forwardTimes, scrobbleTime)
+		}
+	}
+
+	if submission {
+		// Plays count toward keeping cached tracks for good, which outlives the request.
+		playCtx := logging.WithRequestID(context.Background(), logging.RequestID(ctx))
+		go func() {
+			for _, id := range ids {
+				h.streamService.Played(playCtx, id)
+			}
+		}()
+	}
+
+	q["time"] = forwardTimes
+	h.forwardAnnotation(w, r, q, ids)
+}
+
+// forwardAnnotation sends an annotation request on to Navidrome with the song IDs replaced by ids. When
+// nothing is left to annotate Navifetch answers itself.
+func (h *Handler) forwardAnnotation(w http.ResponseWriter, r *http.Request, q url.Values, ids []string) {
+	q["id"] = ids
+	for _, key := range []string{"albumId", "artistId"} {
+		local := make([]string, 0, len(q[key]))
+		for _, id := range q[key] {
+			if strings.HasPrefix(id, "external-") {
+				slog.InfoContext(r.Context(), "Ignoring external album or artist, they can't be annotated", "param", key, "id", id)
+				continue
+			}
+			local = append(local, id)
+		}
+		q[key] = local
+	}
+
+	if len(q["id"]) == 0 && len(q["albumId"]) == 0 && len(q["artistId"]) == 0 {
+		writeSubsonic(w, r, model.OKResponse())
+		return
+	}
+	r.URL.RawQuery = q.Encode()
 	h.rp.ServeHTTP(w, r)
 }
 
@@ -223,18 +444,455 @@ func (h *Handler) GetAlbum(w http.ResponseWriter, r *http.Request) {
 
 	albumId := r.URL.Query().Get("id")
 
-	resp, err := h.albumService.GetAlbum(ctx, albumId, r.URL.Path, r.URL.RawQuery)
+	resp, err := h.albumService.GetAlbum(ctx, albumId, r.URL.Path, jsonQuery(r))
+	if err != nil {
+		slog.ErrorContext(ctx, "GetAlbum error", "err", err)
+		if strings.HasPrefix(albumId, "external-") {
+			writeSubsonicError(w, r, 70, "Album not found")
+			return
+		}
+		writeSubsonicError(w, r, 0, "Failed to fetch album")
+		return
+	}
+
+	writeSubsonic(w, r, resp)
+}
+
+func (h *Handler) GetMusicDirectory(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if !strings.HasPrefix(id, "external-") {
+		h.rp.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 12*time.Second)
+	defer cancel()
+
+	resp, err := h.albumService.GetMusicDirectory(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetMusicDirectory error", "err", err)
+		writeSubsonicError(w, r, 70, "Directory not found")
+		return
+	}
+	writeSubsonic(w, r, resp)
+}
+
+func (h *Handler) GetAlbumInfo(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if !strings.HasPrefix(id, "external-") {
+		h.rp.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	resp, err := h.albumService.GetAlbumInfo(ctx, id)
+	if err != nil {
+		writeSubsonicError(w, r, 70, "Album not found")
+		return
+	}
+	writeSubsonic(w, r, resp)
+}
+
+func (h *Handler) GetArtist(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 12*time.Second)
+	defer cancel()
+
+	artistId := r.URL.Query().Get("id")
+	if strings.HasPrefix(artistId, "external-") {
+		resp, err := h.artistService.GetArtist(ctx, artistId)
+		if err != nil {
+			slog.ErrorContext(ctx, "GetArtist error", "err", err)
+			writeSubsonicError(w, r, 70, "Artist not found")
+			return
+		}
+		writeSubsonic(w, r, resp)
+		return
+	}
+
+	body, status, contentType, err := h.rp.SendNavidromeRequest(ctx, r.URL.Path, jsonQuery(r))
+	if err != nil {
+		http.Error(w, "Upstream error", http.StatusBadGateway)
+		return
+	}
+	if status != http.StatusOK {
+		w.Header().Set("Content-Type", service.ContentTypeOrJSON(contentType))
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+		return
+	}
+
+	if injected, err := h.artistService.InjectMissingAlbums(ctx, body); err == nil {
+		body = injected
+	} else {
+		slog.ErrorContext(ctx, "Error adding external albums to artist", "err", err)
+	}
+	writeSubsonicBody(w, r, status, body)
+}
+
+func (h *Handler) GetArtistInfo(w http.ResponseWriter, r *http.Request) {
+	artistId := r.URL.Query().Get("id")
+	if !strings.HasPrefix(artistId, "external-") {
+		h.rp.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	resp, err := h.artistService.GetArtistInfo(ctx, artistId)
+	if err != nil {
+		writeSubsonicError(w, r, 70, "Artist not found")
+		return
+	}
+
+	writeSubsonic(w, r, resp)
+}
+
+// GetAlbumList puts the tracked new releases of followed artists in front of getAlbumList2?type=newest,
+// shifting Navidrome's albums down by as many so paging with size and offset still works. Every other list
+// is Navidrome's.
+func (h *Handler) GetAlbumList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("type") != "newest" {
+		h.rp.ServeHTTP(w, r)
+		return
+	}
+	size := min(parseCount(q.Get("size"), 10), 500)
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	releases, localOffset, localSize := h.releases.NewestPage(max(offset, 0), size)
+	if len(releases) == 0 && localOffset == offset {
+		h.rp.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 12*time.Second)
+	defer cancel()
+
+	q.Set("f", "json")
+	q.Set("offset", strconv.Itoa(localOffset))
+	// Navidrome reads a size of 0 as its default, the extra albums are cut again below.
+	q.Set("size", strconv.Itoa(max(localSize, 1)))
+	body, status, contentType, err := h.rp.SendNavidromeRequest(ctx, r.URL.Path, q.Encode())
+	if err != nil {
+		http.Error(w, "Upstream error", http.StatusBadGateway)
+		return
+	}
+	if status != http.StatusOK {
+		w.Header().Set("Content-Type", service.ContentTypeOrJSON(contentType))
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+		return
+	}
+
+	if injected, err := h.releases.InjectNewest(body, releases, localSize); err == nil {
+		body = injected
+	} else {
+		slog.ErrorContext(r.Context(), "Error adding tracked releases to album list", "err", err)
+	}
+	writeSubsonicBody(w, r, status, body)
+}
+
+func (h *Handler) GetTopSongs(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	artist := r.URL.Query().Get("artist")
+	count := parseCount(r.URL.Query().Get("count"), 50)
+
+	songs, err := h.songService.GetTopSongs(ctx, artist, count, r.URL.Query())
+	if err != nil || len(songs) == 0 {
+		slog.InfoContext(ctx, "No external top songs, falling back to Navidrome", "artist", artist, "err", err)
+		h.rp.ServeHTTP(w, r)
+		return
+	}
+
+	writeSubsonic(w, r, service.WrapSongList("topSongs", songs))
+}
+
+func (h *Handler) GetSimilarSongs(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	id := r.URL.Query().Get("id")
+	count := parseCount(r.URL.Query().Get("count"), 50)
+	element := "similarSongs"
+	if endpointName(r.URL.Path) == "getSimilarSongs2" {
+		element = "similarSongs2"
+	}
+
+	songs, err := h.songService.GetSimilarSongs(ctx, id, count, r.URL.Query())
+	if err != nil || len(songs) == 0 {
+		slog.InfoContext(ctx, "No external similar songs, falling back to Navidrome", "id", id, "err", err)
+		h.rp.ServeHTTP(w, r)
+		return
+	}
+
+	writeSubsonic(w, r, service.WrapSongList(element, songs))
+}
+
+func (h *Handler) GetLyrics(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 12*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	resp, err := h.lyricsService.GetLyrics(ctx, q.Get("artist"), q.Get("title"), q)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetLyrics error", "err", err)
+		http.Error(w, "Failed to fetch lyrics", http.StatusBadGateway)
+		return
+	}
+
+	writeSubsonic(w, r, resp)
+}
+
+func (h *Handler) GetLyricsBySongID(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 12*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	resp, err := h.lyricsService.GetLyricsBySongID(ctx, q.Get("id"), q)
 	if err != nil {
-		log.Printf("GetAlbum error: %v", err)
-		http.Error(w, "Failed to fetch album", http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "GetLyricsBySongId error", "err", err)
+		http.Error(w, "Failed to fetch lyrics", http.StatusBadGateway)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeSubsonic(w, r, resp)
+}
+
+func (h *Handler) GetOpenSubsonicExtensions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	writeSubsonic(w, r, service.OpenSubsonicExtensions(ctx, h.rp, jsonQuery(r)))
+}
+
+// GetPlaylists adds the virtual playlist of wanted tracks to Navidrome's playlists.
+func (h *Handler) GetPlaylists(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 12*time.Second)
+	defer cancel()
+
+	body, status, contentType, err := h.rp.SendNavidromeRequest(ctx, r.URL.Path, jsonQuery(r))
+	if err != nil {
+		http.Error(w, "Upstream error", http.StatusBadGateway)
+		return
+	}
+	if status != http.StatusOK {
+		w.Header().Set("Content-Type", service.ContentTypeOrJSON(contentType))
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+		return
+	}
+
+	if injected, err := h.wanted.InjectPlaylist(body); err == nil {
+		body = injected
+	} else {
+		slog.ErrorContext(r.Context(), "Error adding wanted playlist to playlists", "err", err)
+	}
+	writeSubsonicBody(w, r, status, body)
+}
+
+func (h *Handler) GetPlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("id") != service.WantedPlaylistID {
+		h.rp.ServeHTTP(w, r)
+		return
+	}
+
+	var resp model.SubsonicPlaylistResponse
+	resp.Subsonic.Envelope = model.OKEnvelope()
+	resp.Subsonic.Playlist = h.wanted.Playlist()
+	writeSubsonic(w, r, resp)
+}
+
+// Wanted is the admin API of the wanted list: GET lists the items, POST adds the external track, album or
+// artist given by kind and id, DELETE removes the item with the given key.
+func (h *Handler) Wanted(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	if !h.requireAdmin(ctx, w, service.ClientAuthQuery(q)) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, h.wanted.Items())
+	case http.MethodPost:
+		item, err := h.wanted.Add(ctx, q.Get("kind"), q.Get("id"), service.WantedSourceRequest)
+		if errors.Is(err, service.ErrUnknownWantedKind) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Wanted add error", "err", err)
+			http.Error(w, "Failed to look the item up", http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, http.StatusCreated, item)
+	case http.MethodDelete:
+		if !h.wanted.Remove(q.Get("key")) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// RetryWanted schedules the wanted item with the given key for the next run of the worker.
+func (h *Handler) RetryWanted(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	if !h.requireAdmin(ctx, w, service.ClientAuthQuery(q)) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.wanted.Retry(q.Get("key")) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxPlaylistFileSize bounds uploaded playlist files, large exports are a few hundred KB.
+const maxPlaylistFileSize = 10 << 20
+
+// ImportPlaylist creates a Navidrome playlist from an M3U/M3U8, XSPF, JSPF or CSV file, owned by the user the
+// Subsonic auth parameters in the query belong to. The file is the request body or the "file" field of a
+// multipart form, the name parameter overrides the playlist name.
+func (h *Handler) ImportPlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	auth := service.ClientAuthQuery(q)
+	if err := service.CheckAuth(r.Context(), h.rp, auth); err != nil {
+		slog.WarnContext(r.Context(), "ImportPlaylist auth error", "err", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPlaylistFileSize)
+	filename := q.Get("filename")
+	var data []byte
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, formErr := r.FormFile("file")
+		if formErr != nil {
+			http.Error(w, "Missing file field", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		if filename == "" {
+			filename = header.Filename
+		}
+		data, err = io.ReadAll(file)
+	} else {
+		data, err = io.ReadAll(r.Body)
+	}
+	if err != nil {
+		http.Error(w, "Failed to read playlist file", http.StatusBadRequest)
+		return
+	}
+
+	pl, err := playlist.Parse(filename, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if name := q.Get("name"); name != "" {
+		pl.Name = name
+	}
+
+	// Downloading the missing tracks takes far longer than the server's write timeout.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(r.Context(), "ImportPlaylist could not lift the write deadline", "err", err)
+	}
+	report, err := h.importer.Import(r.Context(), pl, auth)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "ImportPlaylist error", "err", err)
+		if report == nil {
+			http.Error(w, "Failed to import playlist", http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, http.StatusBadGateway, report)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// ExportPlaylist downloads any Navidrome playlist, by id or name, as M3U8 or JSPF (the format parameter).
+// Only Navidrome admins may use it.
+func (h *Handler) ExportPlaylist(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	auth := service.ClientAuthQuery(q)
+	if !h.requireAdmin(ctx, w, auth) {
+		return
+	}
+
+	id := q.Get("id")
+	if id == "" {
+		http.Error(w, "Missing id parameter", http.StatusBadRequest)
+		return
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = "m3u8"
+	}
+
+	pl, err := h.exporter.Export(ctx, id, auth)
+	if err != nil {
+		slog.ErrorContext(ctx, "ExportPlaylist error", "err", err)
+		http.Error(w, "Playlist not found", http.StatusNotFound)
+		return
+	}
+	body, err := playlist.Encode(pl, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	contentType, ext := playlist.ContentType(format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", util.SanitizeFilename(pl.Name)+ext))
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		log.Printf("Error encoding response: %v", err)
+	_, _ = w.Write(body)
+}
+
+// requireAdmin answers the request itself and returns false unless auth belongs to a Navidrome admin.
+func (h *Handler) requireAdmin(ctx context.Context, w http.ResponseWriter, auth url.Values) bool {
+	err := service.CheckAdmin(ctx, h.rp, auth)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, service.ErrNotAdmin) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	slog.WarnContext(ctx, "Admin auth error", "err", err)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+func parseCount(count string, def int) int {
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return def
 	}
+	return n
 }
 
 func (h *Handler) CatchAll(w http.ResponseWriter, r *http.Request) {
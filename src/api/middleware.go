@@ -1,8 +1,14 @@
 package api
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/logging"
+	"github.com/GerardPolloRebozado/navifetch/src/metrics"
 )
 
 func CORSMiddleware(next http.Handler) http.Handler {
@@ -19,9 +25,77 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// LoggingMiddleware tags every request with an ID, the client's X-Request-ID when it sends a usable one, which
+// is echoed in the response, forwarded to Navidrome and added to every record logged for the request. Each
+// request is logged once it's answered, with credentials redacted from its query.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s%s from %s", r.Method, r.Host, r.URL.RequestURI(), r.RemoteAddr)
-		next.ServeHTTP(w, r)
+		start := time.Now()
+		id := r.Header.Get(logging.RequestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		// Setting it on the request too makes the reverse proxy pass it on to Navidrome.
+		r.Header.Set(logging.RequestIDHeader, id)
+		w.Header().Set(logging.RequestIDHeader, id)
+		r = r.WithContext(logging.WithRequestID(r.Context(), id))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		slog.InfoContext(r.Context(), "Request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"query", logging.RedactQuery(r.URL.RawQuery),
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration", time.Since(start),
+			"remote", r.RemoteAddr)
 	})
 }
+
+// MetricsMiddleware counts and times the requests mux serves, labelled with the Subsonic method for /rest/
+// paths and the matched route for Navifetch's own endpoints.
+func MetricsMiddleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		endpoint := metricsEndpoint(mux, r)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r)
+		metrics.HTTPRequests.Inc(endpoint, strconv.Itoa(rec.status))
+		metrics.HTTPDuration.Since(start, endpoint)
+	})
+}
+
+func metricsEndpoint(mux *http.ServeMux, r *http.Request) string {
+	if strings.HasPrefix(r.URL.Path, "/rest/") {
+		return metrics.SubsonicMethod(r.URL.Path)
+	}
+	_, pattern := mux.Handler(r)
+	if pattern == "" || pattern == "/" {
+		return "other"
+	}
+	return pattern
+}
+
+// statusRecorder remembers the status code and counts the bytes written through it. Unwrap lets
+// http.ResponseController reach the underlying writer for flushing and deadlines.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
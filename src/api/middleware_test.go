@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	mux := http.NewServeMux()
+	noop := func(http.ResponseWriter, *http.Request) {}
+	mux.HandleFunc("/api/wanted", noop)
+	mux.HandleFunc("/", noop)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/rest/getSong.view", "getSong"},
+		{"/rest/ping", "ping"},
+		{"/rest/notAMethod", "other"},
+		{"/rest/x1y2z3", "other"},
+		{"/api/wanted", "/api/wanted"},
+		{"/app/", "other"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if got := metricsEndpoint(mux, r); got != tt.want {
+			t.Errorf("metricsEndpoint(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
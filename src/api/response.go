@@ -0,0 +1,192 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+const subsonicNamespace = "http://subsonic.org/restapi"
+
+// jsonpCallback matches the JavaScript names, dotted or not, accepted as JSONP callbacks.
+var jsonpCallback = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$.]*$`)
+
+// endpointName returns the Subsonic endpoint of a /rest/ path, without the optional .view suffix.
+func endpointName(path string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(path, "/rest/"), ".view")
+}
+
+// jsonQuery returns the request query with f=json, for upstream requests whose body Navifetch decodes
+// regardless of the format the client asked for.
+func jsonQuery(r *http.Request) string {
+	q := r.URL.Query()
+	q.Set("f", "json")
+	return q.Encode()
+}
+
+// writeSubsonic encodes a Subsonic response in the format the client asked for with the f parameter.
+func writeSubsonic(w http.ResponseWriter, r *http.Request, resp any) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding response", "err", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	writeSubsonicBody(w, r, http.StatusOK, body)
+}
+
+// writeSubsonicError answers with a failed Subsonic response carrying code, in the format the client asked for.
+func writeSubsonicError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	writeSubsonic(w, r, model.ErrorResponse(code, message))
+}
+
+// writeSubsonicBody writes a JSON Subsonic envelope, converting it when the client wants XML or JSONP.
+// A JSONP callback that isn't a JavaScript name is refused with error 10, sent as plain JSON.
+func writeSubsonicBody(w http.ResponseWriter, r *http.Request, status int, body []byte) {
+	switch r.URL.Query().Get("f") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	case "jsonp":
+		callback := r.URL.Query().Get("callback")
+		if !jsonpCallback.MatchString(callback) {
+			refused, err := json.Marshal(model.ErrorResponse(10, "Required parameter is missing or invalid: callback"))
+			if err != nil {
+				http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			status, body = http.StatusOK, refused
+			break
+		}
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		body = []byte(fmt.Sprintf("%s(%s);", callback, body))
+	default:
+		converted, err := subsonicXML(body)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error converting response to XML", "err", err)
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		body = converted
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// writeJSON writes a plain JSON response for Navifetch's own API, outside the Subsonic envelope.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Error encoding response", "err", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// subsonicXML converts a JSON Subsonic envelope to its XML form: scalars become attributes, objects and
+// array items become child elements and a scalar "value" becomes the element text.
+func subsonicXML(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var envelope map[string]any
+	if err := decoder.Decode(&envelope); err != nil {
+		return nil, err
+	}
+	root, ok := envelope["subsonic-response"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("missing subsonic-response")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	root["xmlns"] = subsonicNamespace
+	if err := encodeXMLElement(enc, "subsonic-response", root); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeXMLElement(enc *xml.Encoder, name string, value any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	fields, ok := value.(map[string]any)
+	if !ok {
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		if err := enc.EncodeToken(xml.CharData(xmlScalar(value))); err != nil {
+			return err
+		}
+		return enc.EncodeToken(start.End())
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var text string
+	children := make([]string, 0)
+	for _, key := range keys {
+		switch v := fields[key].(type) {
+		case nil:
+		case map[string]any, []any:
+			children = append(children, key)
+		default:
+			if key == "value" {
+				text = xmlScalar(v)
+				continue
+			}
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: key}, Value: xmlScalar(v)})
+		}
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if text != "" {
+		if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+	for _, key := range children {
+		items, isList := fields[key].([]any)
+		if !isList {
+			items = []any{fields[key]}
+		}
+		for _, item := range items {
+			if err := encodeXMLElement(enc, key, item); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+func xmlScalar(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
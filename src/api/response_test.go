@@ -0,0 +1,140 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+func TestJSONPCallback(t *testing.T) {
+	tests := []struct {
+		callback string
+		want     bool
+	}{
+		{"callback", true},
+		{"jQuery_123", true},
+		{"$", true},
+		{"app.handlers.onSong", true},
+		{"", false},
+		{"1abc", false},
+		{"alert(1);cb", false},
+		{"cb//", false},
+		{"a-b", false},
+		{"</script><script>alert(1)</script>", false},
+		{"cb\n", false},
+		{"a b", false},
+	}
+	for _, tt := range tests {
+		if got := jsonpCallback.MatchString(tt.callback); got != tt.want {
+			t.Errorf("jsonpCallback(%q) = %v, want %v", tt.callback, got, tt.want)
+		}
+	}
+}
+
+func TestWriteSubsonicError(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		wantContentType string
+		want            []string
+	}{
+		{
+			name:            "xml by default",
+			wantContentType: "application/xml",
+			want:            []string{`<subsonic-response`, `status="failed"`, `<error code="70" message="Song not found">`, `xmlns="http://subsonic.org/restapi"`},
+		},
+		{
+			name:            "json",
+			query:           "f=json",
+			wantContentType: "application/json",
+			want:            []string{`{"subsonic-response":{`, `"status":"failed"`, `"error":{"code":70,"message":"Song not found"}`},
+		},
+		{
+			name:            "jsonp",
+			query:           "f=jsonp&callback=player.onSong",
+			wantContentType: "application/javascript",
+			want:            []string{`player.onSong({"subsonic-response":`, `"code":70`, `});`},
+		},
+		{
+			name:            "jsonp with an invalid callback",
+			query:           "f=jsonp&callback=alert(document.cookie)",
+			wantContentType: "application/json",
+			want:            []string{`{"subsonic-response":`, `"code":10`, `callback`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/rest/getSong.view?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			writeSubsonicError(w, r, 70, "Song not found")
+
+			if w.Code != http.StatusOK {
+				t.Errorf("got status %d, Subsonic errors are sent with 200", w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantContentType) {
+				t.Errorf("got content type %q, want %q", got, tt.wantContentType)
+			}
+			body := w.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("%s doesn't contain %s", body, want)
+				}
+			}
+			if strings.Contains(body, "alert") {
+				t.Errorf("callback reflected in %s", body)
+			}
+		})
+	}
+}
+
+func TestSubsonicXML(t *testing.T) {
+	tests := []struct {
+		name string
+		resp any
+		want []string
+	}{
+		{
+			name: "ok envelope",
+			resp: model.OKResponse(),
+			want: []string{`<?xml version="1.0" encoding="UTF-8"?>`, `status="ok"`, `openSubsonic="true"`, `version="` + model.APIVersion + `"`},
+		},
+		{
+			name: "lists and text values",
+			resp: model.WrapResponse("lyrics", map[string]any{
+				"artist": "Daft Punk & Friends",
+				"value":  "One more time <3",
+				"line":   []any{map[string]any{"start": 1000}, map[string]any{"start": 2000}},
+			}),
+			want: []string{`<lyrics artist="Daft Punk &amp; Friends">One more time &lt;3<line start="1000"></line><line start="2000"></line></lyrics>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/rest/ping", nil)
+			w := httptest.NewRecorder()
+			writeSubsonic(w, r, tt.resp)
+			body := w.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("%s doesn't contain %s", body, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEndpointName(t *testing.T) {
+	tests := map[string]string{
+		"/rest/getSong":      "getSong",
+		"/rest/getSong.view": "getSong",
+		"/rest/stream.view":  "stream",
+	}
+	for path, want := range tests {
+		if got := endpointName(path); got != want {
+			t.Errorf("endpointName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
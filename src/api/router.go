@@ -2,35 +2,83 @@ package api
 
 import (
 	"net/http"
+
+	"github.com/GerardPolloRebozado/navifetch/src/metrics"
 )
 
 func RegisterRoutes(mux *http.ServeMux, h *Handler) {
 	mux.HandleFunc("/healthz", h.Healthz)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/api/playlists/import", h.ImportPlaylist)
+	mux.HandleFunc("/api/playlists/export", h.ExportPlaylist)
+	mux.HandleFunc("/api/wanted", h.Wanted)
+	mux.HandleFunc("/api/wanted/retry", h.RetryWanted)
 
-	mux.HandleFunc("/rest/search3.view", h.SmartSearch)
-	mux.HandleFunc("/rest/search2.view", h.SmartSearch)
-	mux.HandleFunc("/rest/search3", h.SmartSearch)
-	mux.HandleFunc("/rest/search2", h.SmartSearch)
-	mux.HandleFunc("/rest/getAlbum", h.GetAlbum)
+	mux.HandleFunc("/api/admin/login", h.AdminLogin)
+	mux.HandleFunc("/api/admin/openapi.yaml", h.AdminOpenAPI)
+	mux.HandleFunc("/api/admin/jobs", h.adminOnly(http.MethodGet, h.AdminJobs))
+	mux.HandleFunc("/api/admin/jobs/download", h.adminOnly(http.MethodPost, h.AdminDownload))
+	mux.HandleFunc("/api/admin/jobs/cancel", h.adminOnly(http.MethodPost, h.AdminCancelJob))
+	mux.HandleFunc("/api/admin/jobs/retry", h.adminOnly(http.MethodPost, h.AdminRetryJob))
+	mux.HandleFunc("/api/admin/tracks", h.adminOnly(http.MethodGet, h.AdminTracks))
+	mux.HandleFunc("/api/admin/tracks/delete", h.adminOnly(http.MethodPost, h.AdminDeleteTrack))
+	mux.HandleFunc("/api/admin/cache", h.adminOnly(http.MethodGet, h.AdminCache))
+	mux.HandleFunc("/api/admin/cache/evict", h.adminOnly(http.MethodPost, h.AdminEvict))
+	mux.HandleFunc("/api/admin/cache/promote", h.adminOnly(http.MethodPost, h.AdminPromote))
+	mux.HandleFunc("/api/admin/cleanup", h.adminOnly(http.MethodPost, h.AdminCleanup))
+	mux.HandleFunc("/api/admin/providers", h.adminOnly(http.MethodGet, h.AdminProviders))
+	mux.HandleFunc("/api/admin/searches", h.adminOnly(http.MethodGet, h.AdminSearches))
+	if path := h.cfg.Get().Server.AdminPath; path != "" {
+		mux.Handle(path+"/", http.StripPrefix(path, AdminUI()))
+	}
 
-	mux.HandleFunc("/rest/getCoverArt.view", h.ProxyCoverArt)
-	mux.HandleFunc("/rest/getCoverArt", h.ProxyCoverArt)
+	// Subsonic clients call endpoints both with and without the legacy .view suffix.
+	for endpoint, handler := range subsonicRoutes(h) {
+		metrics.RegisterSubsonicMethods(endpoint)
+		mux.HandleFunc("/rest/"+endpoint, handler)
+		mux.HandleFunc("/rest/"+endpoint+".view", handler)
+	}
 
-	mux.HandleFunc("/rest/stream.view", h.ProxyStream)
-	mux.HandleFunc("/rest/stream", h.ProxyStream)
-	mux.HandleFunc("/rest/download.view", h.ProxyStream)
-	mux.HandleFunc("/rest/download", h.ProxyStream)
+	// Catch-all reverse proxy
+	mux.HandleFunc("/", h.CatchAll)
+}
 
-	mux.HandleFunc("/rest/getSong.view", h.ProxyMetadata)
-	mux.HandleFunc("/rest/getSong", h.ProxyMetadata)
+// subsonicRoutes maps the Subsonic endpoints Navifetch handles itself, everything else goes to Navidrome.
+func subsonicRoutes(h *Handler) map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"getOpenSubsonicExtensions": h.GetOpenSubsonicExtensions,
 
-	mux.HandleFunc("/rest/createPlaylist.view", h.ProxyPlaylist)
-	mux.HandleFunc("/rest/createPlaylist", h.ProxyPlaylist)
-	mux.HandleFunc("/rest/updatePlaylist.view", h.ProxyPlaylist)
-	mux.HandleFunc("/rest/updatePlaylist", h.ProxyPlaylist)
-	mux.HandleFunc("/rest/savePlayQueue.view", h.ProxyPlaylist)
-	mux.HandleFunc("/rest/savePlayQueue", h.ProxyPlaylist)
+		"search2": h.SmartSearch,
+		"search3": h.SmartSearch,
 
-	// Catch-all reverse proxy
-	mux.HandleFunc("/", h.CatchAll)
+		"getSong":           h.ProxyMetadata,
+		"getAlbum":          h.GetAlbum,
+		"getAlbumInfo2":     h.GetAlbumInfo,
+		"getArtist":         h.GetArtist,
+		"getArtistInfo2":    h.GetArtistInfo,
+		"getMusicDirectory": h.GetMusicDirectory,
+		"getAlbumList2":     h.GetAlbumList,
+		"getCoverArt":       h.ProxyCoverArt,
+
+		"getTopSongs":      h.GetTopSongs,
+		"getSimilarSongs":  h.GetSimilarSongs,
+		"getSimilarSongs2": h.GetSimilarSongs,
+
+		"getLyrics":         h.GetLyrics,
+		"getLyricsBySongId": h.GetLyricsBySongID,
+
+		"stream":   h.ProxyStream,
+		"download": h.ProxyStream,
+
+		"star":      h.Star,
+		"unstar":    h.Unstar,
+		"setRating": h.SetRating,
+		"scrobble":  h.Scrobble,
+
+		"getPlaylists":   h.GetPlaylists,
+		"getPlaylist":    h.GetPlaylist,
+		"createPlaylist": h.ProxyPlaylist,
+		"updatePlaylist": h.ProxyPlaylist,
+		"savePlayQueue":  h.ProxyPlaylist,
+	}
 }
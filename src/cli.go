@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/logging"
+)
+
+// subcommands are named by the first argument, serve runs when there is none.
+var subcommands = map[string]func(args []string) error{
+	"serve":           runServe,
+	"search":          runSearch,
+	"download":        runDownload,
+	"prune":           runPrune,
+	"doctor":          runDoctor,
+	"import-playlist": runImportPlaylist,
+	"export-playlist": runExportPlaylist,
+	"config":          runConfig,
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage: navifetch [command] [flags] [args]
+
+Commands:
+  serve             run the proxy, the default
+  search            search the metadata provider
+  download          download a song into the library without the server
+  prune             remove expired streamed-only tracks now
+  doctor            check yt-dlp, ffmpeg, the library and Navidrome
+  import-playlist   import a playlist file into Navidrome
+  export-playlist   export a Navidrome playlist to a file
+  config check      print and validate the configuration
+
+Run "navifetch <command> -h" for the flags of a command.
+`)
+}
+
+// loadConfig loads the configuration for a subcommand and sets logging up with it.
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	logging.Setup(cfg)
+	return cfg, nil
+}
+
+// loadUserConfig loads the configuration for a subcommand acting as a Navidrome user, NAVIDROME_USER unless
+// user is set.
+func loadUserConfig(user string, password string) (*config.Config, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if user != "" {
+		cfg.Auth.NavidromeUser = user
+		cfg.Auth.NavidromePassword = password
+	}
+	if cfg.Auth.NavidromeUser == "" {
+		return nil, fmt.Errorf("a Navidrome user is required, set NAVIDROME_USER or pass -user")
+	}
+	return cfg, nil
+}
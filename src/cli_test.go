@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUsageListsSubcommands(t *testing.T) {
+	var buf bytes.Buffer
+	usage(&buf)
+	for name := range subcommands {
+		if name == "serve" {
+			continue
+		}
+		if !strings.Contains(buf.String(), "\n  "+name+" ") {
+			t.Errorf("usage doesn't list %s", name)
+		}
+	}
+}
+
+func TestTrimExternal(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Digital Love (external)", "Digital Love"},
+		{"Digital Love", "Digital Love"},
+		{"(external) Digital Love", "(external) Digital Love"},
+	}
+	for _, tt := range tests {
+		if got := trimExternal(tt.name); got != tt.want {
+			t.Errorf("trimExternal(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
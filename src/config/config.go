@@ -1,57 +1,135 @@
 package config
 
 import (
-	"log"
-	"os"
-	"strconv"
-
-	"github.com/joho/godotenv"
+	"time"
 )
 
+// Config is Navifetch's configuration. Defaults come from Default, a YAML or TOML file may override them
+// and environment variables, named in the env tags, override the file. Fields tagged secret are redacted
+// by Redacted.
 type Config struct {
-	NavidromeBase    string
-	Port             string
-	MusicLibraryPath string
-	YTDLPPath        string
-	MetadataProvider string
-	Country          string
-	Limit            int
-	LastFMApiKey     string
+	Server     ServerConfig     `yaml:"server" toml:"server"`
+	Auth       AuthConfig       `yaml:"auth" toml:"auth"`
+	Providers  ProvidersConfig  `yaml:"providers" toml:"providers"`
+	Downloader DownloaderConfig `yaml:"downloader" toml:"downloader"`
+	Cache      CacheConfig      `yaml:"cache" toml:"cache"`
+	Releases   ReleasesConfig   `yaml:"releases" toml:"releases"`
+	Log        LogConfig        `yaml:"log" toml:"log"`
+
+	// File is the configuration file that was read, empty when there was none.
+	File string `yaml:"-" toml:"-"`
 }
 
-func LoadConfig() (*Config, error) {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found or error loading it")
-	}
+type ServerConfig struct {
+	Port string `yaml:"port" toml:"port" env:"PORT"`
+	// NavidromeBase is the URL of the Navidrome server requests are forwarded to.
+	NavidromeBase string `yaml:"navidrome_base" toml:"navidrome_base" env:"NAVIDROME_BASE"`
+	// DataPath is where Navifetch keeps its own state.
+	DataPath string `yaml:"data_path" toml:"data_path" env:"DATA_PATH"`
+	// ShutdownTimeout bounds how long a shutdown waits for requests and downloads before interrupting them.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// AdminPath is where the admin UI is served, which is disabled when empty.
+	AdminPath string `yaml:"admin_path" toml:"admin_path" env:"ADMIN_PATH"`
+}
 
-	base := os.Getenv("NAVIDROME_BASE")
-	if base == "" {
-		log.Fatal("NAVIDROME_BASE env var is required (e.g., http://localhost:4533)")
-	}
+// AuthConfig holds the Navidrome credentials Navifetch's own background requests use.
+type AuthConfig struct {
+	NavidromeUser     string `yaml:"navidrome_user" toml:"navidrome_user" env:"NAVIDROME_USER"`
+	NavidromePassword string `yaml:"navidrome_password" toml:"navidrome_password" env:"NAVIDROME_PASSWORD" secret:"true"`
+}
 
-	libPath := getEnv("MUSIC_LIBRARY_PATH", "/music")
-	var limit = 10
-	if l := getEnv("RESULTS_PER_PAGE", "10"); l != "" {
-		if parsed, err := strconv.ParseInt(l, 10, 8); err == nil {
-			limit = int(parsed)
-		}
-	}
+type ProvidersConfig struct {
+	Metadata string `yaml:"metadata" toml:"metadata" env:"METADATA_PROVIDER"`
+	// Enrichment fills in missing tracks of local albums, Metadata when empty.
+	Enrichment     string `yaml:"enrichment" toml:"enrichment" env:"ENRICHMENT_PROVIDER"`
+	Country        string `yaml:"country" toml:"country" env:"COUNTRY"`
+	ResultsPerPage int    `yaml:"results_per_page" toml:"results_per_page" env:"RESULTS_PER_PAGE"`
+	LastFMApiKey   string `yaml:"lastfm_api_key" toml:"lastfm_api_key" env:"LASTFM_API_KEY" secret:"true"`
+	DiscogsToken   string `yaml:"discogs_token" toml:"discogs_token" env:"DISCOGS_TOKEN" secret:"true"`
+	Lyrics         string `yaml:"lyrics" toml:"lyrics" env:"LYRICS_PROVIDER"`
+	LyricsAPIBase  string `yaml:"lyrics_api_base" toml:"lyrics_api_base" env:"LYRICS_API_BASE"`
+}
+
+type DownloaderConfig struct {
+	YTDLPPath        string `yaml:"ytdlp_path" toml:"ytdlp_path" env:"YTDLP_PATH"`
+	MusicLibraryPath string `yaml:"music_library_path" toml:"music_library_path" env:"MUSIC_LIBRARY_PATH"`
+	// LyricsSidecar writes an .lrc file next to every downloaded track.
+	LyricsSidecar bool `yaml:"lyrics_sidecar" toml:"lyrics_sidecar" env:"LYRICS_SIDECAR"`
+	// StagingPath is where tracks are downloaded and verified before being moved into the library, a folder
+	// inside DataPath when empty.
+	StagingPath string `yaml:"staging_path" toml:"staging_path" env:"STAGING_PATH"`
+	// FFprobePath checks the duration of downloads, which is skipped when it can't be found.
+	FFprobePath string `yaml:"ffprobe_path" toml:"ffprobe_path" env:"FFPROBE_PATH"`
+}
 
+// CacheConfig controls the cleanup of tracks downloaded for streaming only.
+type CacheConfig struct {
+	CleanupInterval time.Duration `yaml:"cleanup_interval" toml:"cleanup_interval" env:"CACHE_CLEANUP_INTERVAL"`
+	MaxAge          time.Duration `yaml:"max_age" toml:"max_age" env:"CACHE_MAX_AGE"`
+	// PromoteAfterPlays moves a cached track to the downloads folder once it's been played this many times
+	// within MaxAge, 0 disables it.
+	PromoteAfterPlays int `yaml:"promote_after_plays" toml:"promote_after_plays" env:"CACHE_PROMOTE_AFTER_PLAYS"`
+}
+
+type ReleasesConfig struct {
+	// TrackingInterval is how often artists are checked for new releases, 0 disables it.
+	TrackingInterval time.Duration `yaml:"tracking_interval" toml:"tracking_interval" env:"RELEASE_TRACKING_INTERVAL"`
+	// MaxAge is how long a release counts as new, 0 keeps it until it's in the library.
+	MaxAge time.Duration `yaml:"max_age" toml:"max_age" env:"RELEASE_MAX_AGE"`
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+	// Format is text or json.
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT"`
+}
+
+// Default returns the configuration used for everything the file and the environment leave unset.
+func Default() *Config {
 	return &Config{
-		NavidromeBase:    base,
-		Port:             getEnv("PORT", "8080"),
-		MusicLibraryPath: libPath,
-		YTDLPPath:        getEnv("YTDLP_PATH", "yt-dlp"),
-		MetadataProvider: getEnv("METADATA_PROVIDER", "itunes"),
-		Country:          getEnv("COUNTRY", "US"),
-		Limit:            limit,
-		LastFMApiKey:     getEnv("LASTFM_API_KEY", ""),
-	}, nil
-}
-
-func getEnv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+		Server: ServerConfig{
+			Port:            "8080",
+			DataPath:        "/data",
+			ShutdownTimeout: 30 * time.Second,
+			AdminPath:       "/admin",
+		},
+		Providers: ProvidersConfig{
+			Metadata:       "itunes",
+			Country:        "US",
+			ResultsPerPage: 10,
+			Lyrics:         "lrclib",
+			LyricsAPIBase:  "https://lrclib.net",
+		},
+		Downloader: DownloaderConfig{
+			YTDLPPath:        "yt-dlp",
+			MusicLibraryPath: "/music",
+			FFprobePath:      "ffprobe",
+		},
+		Cache: CacheConfig{
+			CleanupInterval:   24 * time.Hour,
+			MaxAge:            24 * time.Hour,
+			PromoteAfterPlays: 3,
+		},
+		Releases: ReleasesConfig{
+			TrackingInterval: 24 * time.Hour,
+			MaxAge:           90 * 24 * time.Hour,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// LoadConfig loads and validates the configuration, reporting every problem found at once.
+func LoadConfig() (*Config, error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
-	return def
+	return cfg, nil
 }
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// watchInterval is how often Watch looks at the configuration file's modification time. Polling works
+// with bind mounts and Kubernetes ConfigMaps, whose updates file notifications often miss.
+const watchInterval = 5 * time.Second
+
+// Holder hands out the current configuration and replaces it on reload. Code that must follow reloads keeps
+// the Holder and calls Get each time instead of keeping a *Config.
+type Holder struct {
+	current atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	listeners []func(old, new *Config)
+}
+
+func NewHolder(cfg *Config) *Holder {
+	h := &Holder{}
+	h.current.Store(cfg)
+	return h
+}
+
+// Get returns the current configuration, which must not be modified.
+func (h *Holder) Get() *Config {
+	return h.current.Load()
+}
+
+// OnReload registers fn to be called after every successful reload, in registration order.
+func (h *Holder) OnReload(fn func(old, new *Config)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listeners = append(h.listeners, fn)
+}
+
+// Reload reads the configuration again and swaps it in. An invalid configuration is rejected and the current
+// one stays in place.
+func (h *Holder) Reload() error {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	old := h.current.Swap(cfg)
+	for _, name := range restartOnly(old, cfg) {
+		slog.Warn("Config reload: setting changed, restart Navifetch to apply it", "setting", name)
+	}
+	for _, fn := range h.listeners {
+		fn(old, cfg)
+	}
+	return nil
+}
+
+// Watch reloads the configuration on SIGHUP and whenever the configuration file changes, until ctx is done.
+func (h *Holder) Watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(watchInterval)
+
+	go func() {
+		defer signal.Stop(hup)
+		defer ticker.Stop()
+		path, modTime := watchedFile()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				slog.Info("SIGHUP received, reloading configuration")
+			case <-ticker.C:
+				currentPath, currentModTime := watchedFile()
+				if currentPath == path && currentModTime.Equal(modTime) {
+					continue
+				}
+				path, modTime = currentPath, currentModTime
+				slog.Info("Configuration file changed, reloading", "path", path)
+			}
+			if err := h.Reload(); err != nil {
+				slog.Error("Config reload failed, keeping the current configuration", "err", err)
+			} else {
+				slog.Info("Configuration reloaded")
+			}
+		}
+	}()
+}
+
+// watchedFile returns the configuration file Load would read and its modification time.
+func watchedFile() (string, time.Time) {
+	path, err := findFile()
+	if err != nil || path == "" {
+		return path, time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return path, time.Time{}
+	}
+	return path, info.ModTime()
+}
+
+// restartOnly lists the settings that differ between old and new but are only read at startup.
+func restartOnly(old, new *Config) []string {
+	var changed []string
+	if old.Server.Port != new.Server.Port {
+		changed = append(changed, "server.port")
+	}
+	if old.Server.NavidromeBase != new.Server.NavidromeBase {
+		changed = append(changed, "server.navidrome_base")
+	}
+	if old.Server.DataPath != new.Server.DataPath {
+		changed = append(changed, "server.data_path")
+	}
+	if old.Server.AdminPath != new.Server.AdminPath {
+		changed = append(changed, "server.admin_path")
+	}
+	if old.Log.Format != new.Log.Format {
+		changed = append(changed, "log.format")
+	}
+	return changed
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	t.Chdir(t.TempDir())
+	path := writeConfig(t, "navifetch.yaml", `server:
+  navidrome_base: http://navidrome:4533
+providers:
+  metadata: deezer
+`)
+	t.Setenv(FileEnv, path)
+	t.Setenv("METADATA_PROVIDER", "")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHolder(cfg)
+	var reloads []string
+	h.OnReload(func(old, new *Config) {
+		reloads = append(reloads, old.Providers.Metadata+">"+new.Providers.Metadata)
+	})
+
+	tests := []struct {
+		name         string
+		content      string
+		wantErr      bool
+		wantMetadata string
+	}{
+		{
+			name:         "valid change",
+			content:      "server:\n  navidrome_base: http://navidrome:4533\nproviders:\n  metadata: musicbrainz\n",
+			wantMetadata: "musicbrainz",
+		},
+		{
+			name:         "invalid change is rejected",
+			content:      "server:\n  navidrome_base: \"\"\nproviders:\n  metadata: deezer\n",
+			wantErr:      true,
+			wantMetadata: "musicbrainz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := h.Reload(); (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if got := h.Get().Providers.Metadata; got != tt.wantMetadata {
+				t.Errorf("got provider %q, want %q", got, tt.wantMetadata)
+			}
+		})
+	}
+	if !slices.Equal(reloads, []string{"deezer>musicbrainz"}) {
+		t.Errorf("listeners saw %v", reloads)
+	}
+}
+
+func TestRestartOnly(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   []string
+	}{
+		{name: "nothing changed", modify: func(*Config) {}},
+		{name: "reloadable setting", modify: func(c *Config) { c.Providers.Metadata = "deezer" }},
+		{name: "port", modify: func(c *Config) { c.Server.Port = "9090" }, want: []string{"server.port"}},
+		{
+			name: "several",
+			modify: func(c *Config) {
+				c.Server.DataPath = "/elsewhere"
+				c.Log.Format = "json"
+			},
+			want: []string{"server.data_path", "log.format"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, updated := Default(), Default()
+			tt.modify(updated)
+			if got := restartOnly(old, updated); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
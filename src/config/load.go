@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the configuration file to read instead of looking for one in the default locations.
+const FileEnv = "NAVIFETCH_CONFIG"
+
+// searchDirs are looked into, in order, for navifetch.yaml, navifetch.yml or navifetch.toml.
+var searchDirs = []string{".", "/data"}
+
+var searchNames = []string{"navifetch.yaml", "navifetch.yml", "navifetch.toml"}
+
+// Load reads the configuration file, if any, and the environment on top of the defaults without validating
+// the result.
+func Load() (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		slog.Debug("No .env file found or error loading it", "err", err)
+	}
+
+	cfg := Default()
+	path, err := findFile()
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		cfg.File = path
+	}
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func findFile() (string, error) {
+	if path := os.Getenv(FileEnv); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("%s: %w", FileEnv, err)
+		}
+		return path, nil
+	}
+	for _, dir := range searchDirs {
+		for _, name := range searchNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// readFile decodes a YAML or TOML file, told apart by its extension, rejecting keys Navifetch doesn't know
+// so typos don't go unnoticed.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return err
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, 0, len(undecoded))
+			for _, key := range undecoded {
+				keys = append(keys, key.String())
+			}
+			return fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+		}
+		return nil
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported format, use .yaml, .yml or .toml")
+	}
+}
+
+// applyEnv overrides every field with an env tag whose variable is set, collecting the values that can't be
+// parsed.
+func applyEnv(v reflect.Value) error {
+	var errs []error
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		sf := v.Type().Field(i)
+		if field.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Duration(0)) {
+			errs = append(errs, applyEnv(field))
+			continue
+		}
+		name := sf.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := setValue(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func setValue(field reflect.Value, value string) error {
+	switch {
+	case field.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetInt(int64(n))
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration with the secrets that are set replaced, safe to print.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redact(reflect.ValueOf(&redacted).Elem())
+	return &redacted
+}
+
+func redact(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			redact(field)
+			continue
+		}
+		if v.Type().Field(i).Tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "" {
+			field.SetString("REDACTED")
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr bool
+	}{
+		{
+			name: "yaml",
+			file: "navifetch.yaml",
+			content: `server:
+  navidrome_base: http://navidrome:4533
+  shutdown_timeout: 1m
+providers:
+  metadata: deezer
+  results_per_page: 25
+downloader:
+  lyrics_sidecar: true
+`,
+		},
+		{
+			name: "toml",
+			file: "navifetch.toml",
+			content: `[server]
+navidrome_base = "http://navidrome:4533"
+shutdown_timeout = "1m"
+
+[providers]
+metadata = "deezer"
+results_per_page = 25
+
+[downloader]
+lyrics_sidecar = true
+`,
+		},
+		{name: "empty yaml", file: "navifetch.yml"},
+		{name: "unknown yaml key", file: "navifetch.yaml", content: "server:\n  prot: \"9090\"\n", wantErr: true},
+		{name: "unknown toml key", file: "navifetch.toml", content: "[server]\nprot = \"9090\"\n", wantErr: true},
+		{name: "invalid yaml", file: "navifetch.yaml", content: "server: [\n", wantErr: true},
+		{name: "unsupported extension", file: "navifetch.json", content: "{}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			err := cfg.readFile(writeConfig(t, tt.file, tt.content))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.content == "" {
+				if cfg.Providers.Metadata != "itunes" {
+					t.Errorf("got metadata provider %q, want the default", cfg.Providers.Metadata)
+				}
+				return
+			}
+			if cfg.Server.NavidromeBase != "http://navidrome:4533" || cfg.Server.ShutdownTimeout != time.Minute {
+				t.Errorf("got server %+v", cfg.Server)
+			}
+			if cfg.Providers.Metadata != "deezer" || cfg.Providers.ResultsPerPage != 25 || !cfg.Downloader.LyricsSidecar {
+				t.Errorf("got providers %+v, lyrics sidecar %v", cfg.Providers, cfg.Downloader.LyricsSidecar)
+			}
+			if cfg.Server.Port != "8080" || cfg.Providers.Country != "US" {
+				t.Errorf("defaults not kept: port %q, country %q", cfg.Server.Port, cfg.Providers.Country)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv(FileEnv, writeConfig(t, "navifetch.yaml", `server:
+  port: "9090"
+  navidrome_base: http://navidrome:4533
+providers:
+  metadata: deezer
+`))
+	t.Setenv("PORT", "9191")
+	t.Setenv("CACHE_MAX_AGE", "2h")
+	t.Setenv("CACHE_PROMOTE_AFTER_PLAYS", "5")
+	t.Setenv("LYRICS_SIDECAR", "true")
+	t.Setenv("METADATA_PROVIDER", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.File != os.Getenv(FileEnv) {
+		t.Errorf("got file %q", cfg.File)
+	}
+	if cfg.Server.Port != "9191" || cfg.Server.NavidromeBase != "http://navidrome:4533" {
+		t.Errorf("got port %q, navidrome %q", cfg.Server.Port, cfg.Server.NavidromeBase)
+	}
+	if cfg.Providers.Metadata != "deezer" {
+		t.Errorf("an empty variable overrode the file, got %q", cfg.Providers.Metadata)
+	}
+	if cfg.Cache.MaxAge != 2*time.Hour || cfg.Cache.PromoteAfterPlays != 5 || !cfg.Downloader.LyricsSidecar {
+		t.Errorf("got cache %+v, lyrics sidecar %v", cfg.Cache, cfg.Downloader.LyricsSidecar)
+	}
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv(FileEnv, "")
+	t.Setenv("RESULTS_PER_PAGE", "ten")
+	t.Setenv("SHUTDOWN_TIMEOUT", "30")
+	t.Setenv("LYRICS_SIDECAR", "sometimes")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	for _, want := range []string{"RESULTS_PER_PAGE", "SHUTDOWN_TIMEOUT", "LYRICS_SIDECAR"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%v doesn't mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	t.Setenv(FileEnv, filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := Load(); err == nil {
+		t.Error("got no error")
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	metadataProviders = []string{"itunes", "musicbrainz", "lastfm", "deezer", "discogs"}
+	lyricsProviders   = []string{"", "none", "lrclib"}
+	logLevels         = []string{"debug", "info", "warn", "error"}
+	logFormats        = []string{"text", "json"}
+	// reservedPaths can't hold the admin UI.
+	reservedPaths = []string{"/rest", "/api", "/app", "/share", "/metrics", "/healthz"}
+)
+
+// Validate checks the whole configuration and returns every problem joined into one error.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if c.Server.NavidromeBase == "" {
+		errs = append(errs, errors.New("server.navidrome_base (NAVIDROME_BASE) is required, e.g. http://localhost:4533"))
+	} else {
+		u, err := url.Parse(c.Server.NavidromeBase)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"server.navidrome_base (NAVIDROME_BASE) %q is not an http(s) URL", c.Server.NavidromeBase)
+	}
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && port > 0 && port <= 65535, "server.port (PORT) %q is not a valid port", c.Server.Port)
+	check(c.Server.DataPath != "", "server.data_path (DATA_PATH) can't be empty")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout (SHUTDOWN_TIMEOUT) must be positive")
+	check(validAdminPath(c.Server.AdminPath),
+		"server.admin_path (ADMIN_PATH) %q must be empty or a path such as /admin, outside of %v", c.Server.AdminPath, reservedPaths)
+
+	check(c.Auth.NavidromeUser == "" || c.Auth.NavidromePassword != "",
+		"auth.navidrome_password (NAVIDROME_PASSWORD) is required with auth.navidrome_user")
+
+	check(slices.Contains(metadataProviders, c.Providers.Metadata),
+		"providers.metadata (METADATA_PROVIDER) %q is not one of %v", c.Providers.Metadata, metadataProviders)
+	check(c.Providers.Enrichment == "" || slices.Contains(metadataProviders, c.Providers.Enrichment),
+		"providers.enrichment (ENRICHMENT_PROVIDER) %q is not one of %v", c.Providers.Enrichment, metadataProviders)
+	if c.usesProvider("lastfm") {
+		check(c.Providers.LastFMApiKey != "", "providers.lastfm_api_key (LASTFM_API_KEY) is required by lastfm")
+	}
+	if c.usesProvider("discogs") {
+		check(c.Providers.DiscogsToken != "", "providers.discogs_token (DISCOGS_TOKEN) is required by discogs")
+	}
+	check(c.Providers.ResultsPerPage > 0, "providers.results_per_page (RESULTS_PER_PAGE) must be positive, got %d", c.Providers.ResultsPerPage)
+	check(slices.Contains(lyricsProviders, c.Providers.Lyrics),
+		"providers.lyrics (LYRICS_PROVIDER) %q is not one of none, lrclib", c.Providers.Lyrics)
+	if c.Providers.Lyrics == "lrclib" {
+		_, err := url.ParseRequestURI(c.Providers.LyricsAPIBase)
+		check(err == nil, "providers.lyrics_api_base (LYRICS_API_BASE) %q is not a URL", c.Providers.LyricsAPIBase)
+	}
+
+	check(c.Downloader.YTDLPPath != "", "downloader.ytdlp_path (YTDLP_PATH) can't be empty")
+	check(c.Downloader.MusicLibraryPath != "", "downloader.music_library_path (MUSIC_LIBRARY_PATH) can't be empty")
+
+	check(c.Cache.CleanupInterval > 0, "cache.cleanup_interval (CACHE_CLEANUP_INTERVAL) must be positive")
+	check(c.Cache.MaxAge > 0, "cache.max_age (CACHE_MAX_AGE) must be positive")
+	check(c.Cache.PromoteAfterPlays >= 0, "cache.promote_after_plays (CACHE_PROMOTE_AFTER_PLAYS) can't be negative")
+	check(c.Releases.TrackingInterval >= 0, "releases.tracking_interval (RELEASE_TRACKING_INTERVAL) can't be negative")
+	check(c.Releases.MaxAge >= 0, "releases.max_age (RELEASE_MAX_AGE) can't be negative")
+
+	check(slices.Contains(logLevels, c.Log.Level), "log.level (LOG_LEVEL) %q is not one of %v", c.Log.Level, logLevels)
+	check(slices.Contains(logFormats, c.Log.Format), "log.format (LOG_FORMAT) %q is not one of %v", c.Log.Format, logFormats)
+
+	return errors.Join(errs...)
+}
+
+// validAdminPath accepts "" and paths like /admin that don't shadow the routes Navifetch and Navidrome use.
+func validAdminPath(path string) bool {
+	if path == "" {
+		return true
+	}
+	if !strings.HasPrefix(path, "/") || path == "/" || strings.HasSuffix(path, "/") || strings.ContainsAny(path, " ?#{}") {
+		return false
+	}
+	for _, reserved := range reservedPaths {
+		if path == reserved || strings.HasPrefix(path, reserved+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Config) usesProvider(name string) bool {
+	return c.Providers.Metadata == name || c.Providers.Enrichment == name
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func validConfig() *Config {
+	cfg := Default()
+	cfg.Server.NavidromeBase = "http://localhost:4533"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		// want is a fragment of the error, empty when the configuration is valid.
+		want string
+	}{
+		{name: "defaults", modify: func(*Config) {}},
+		{name: "missing navidrome", modify: func(c *Config) { c.Server.NavidromeBase = "" }, want: "NAVIDROME_BASE"},
+		{name: "navidrome without scheme", modify: func(c *Config) { c.Server.NavidromeBase = "localhost:4533" }, want: "NAVIDROME_BASE"},
+		{name: "port out of range", modify: func(c *Config) { c.Server.Port = "70000" }, want: "PORT"},
+		{name: "user without password", modify: func(c *Config) { c.Auth.NavidromeUser = "navifetch" }, want: "NAVIDROME_PASSWORD"},
+		{name: "unknown provider", modify: func(c *Config) { c.Providers.Metadata = "spotify" }, want: "METADATA_PROVIDER"},
+		{name: "lastfm without key", modify: func(c *Config) { c.Providers.Enrichment = "lastfm" }, want: "LASTFM_API_KEY"},
+		{
+			name: "lastfm with key",
+			modify: func(c *Config) {
+				c.Providers.Metadata = "lastfm"
+				c.Providers.LastFMApiKey = "key"
+			},
+		},
+		{name: "discogs without token", modify: func(c *Config) { c.Providers.Metadata = "discogs" }, want: "DISCOGS_TOKEN"},
+		{name: "lyrics disabled", modify: func(c *Config) { c.Providers.Lyrics, c.Providers.LyricsAPIBase = "none", "" }},
+		{name: "lyrics without base", modify: func(c *Config) { c.Providers.LyricsAPIBase = "" }, want: "LYRICS_API_BASE"},
+		{name: "zero cache age", modify: func(c *Config) { c.Cache.MaxAge = 0 }, want: "CACHE_MAX_AGE"},
+		{name: "negative release age", modify: func(c *Config) { c.Releases.MaxAge = -time.Hour }, want: "RELEASE_MAX_AGE"},
+		{name: "unknown log level", modify: func(c *Config) { c.Log.Level = "trace" }, want: "LOG_LEVEL"},
+		{name: "reserved admin path", modify: func(c *Config) { c.Server.AdminPath = "/rest" }, want: "ADMIN_PATH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("got %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("got %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Port = "http"
+	cfg.Log.Format = "xml"
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	for _, want := range []string{"NAVIDROME_BASE", "PORT", "LOG_FORMAT"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%v doesn't mention %s", err, want)
+		}
+	}
+}
+
+func TestValidAdminPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"", true},
+		{"/admin", true},
+		{"/navifetch/admin", true},
+		{"/restricted", true},
+		{"admin", false},
+		{"/", false},
+		{"/admin/", false},
+		{"/ad min", false},
+		{"/admin?x", false},
+		{"/{id}", false},
+		{"/rest", false},
+		{"/rest/admin", false},
+		{"/app", false},
+		{"/metrics", false},
+		{"/healthz", false},
+	}
+	for _, tt := range tests {
+		if got := validAdminPath(tt.path); got != tt.want {
+			t.Errorf("validAdminPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"gopkg.in/yaml.v3"
+)
+
+// runConfig implements "navifetch config check", which prints the effective configuration with its secrets
+// redacted and fails when it isn't valid.
+func runConfig(args []string) error {
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: navifetch config check")
+		fmt.Fprintf(flags.Output(), "Prints the effective configuration, read from %s or navifetch.yaml/.yml/.toml and the environment, and validates it.\n", config.FileEnv)
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 || flags.Arg(0) != "check" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.File != "" {
+		fmt.Printf("# Read from %s\n", cfg.File)
+	} else {
+		fmt.Println("# No configuration file found, using defaults and the environment")
+	}
+	out, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(out))
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "\nThe configuration is not valid:")
+		var joined interface{ Unwrap() []error }
+		if errors.As(err, &joined) {
+			for _, e := range joined.Unwrap() {
+				fmt.Fprintf(os.Stderr, "  - %v\n", e)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "  - %v\n", err)
+		}
+		return errors.New("invalid configuration")
+	}
+	fmt.Fprintln(os.Stderr, "\nThe configuration is valid.")
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/service"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
+)
+
+// runDoctor implements "navifetch doctor", which checks what the server needs around it: the programs
+// downloads run, folders it writes to and the Navidrome server with its credentials.
+func runDoctor(args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: navifetch doctor")
+		fmt.Fprintln(flags.Output(), "Checks yt-dlp, ffmpeg, the library and data folders, and Navidrome.")
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	d := &doctor{}
+	if err := cfg.Validate(); err != nil {
+		d.fail("configuration", "invalid, run navifetch config check for details")
+	} else if cfg.File != "" {
+		d.ok("configuration", "read from "+cfg.File)
+	} else {
+		d.ok("configuration", "defaults and the environment")
+	}
+	d.program("yt-dlp", cfg.Downloader.YTDLPPath, true, "--version")
+	d.program("ffmpeg", "ffmpeg", true, "-version")
+	d.program("ffprobe", cfg.Downloader.FFprobePath, false, "-version")
+	d.writable("music library", cfg.Downloader.MusicLibraryPath)
+	d.writable("staging folder", util.StagingDir(cfg))
+	d.writable("data folder", cfg.Server.DataPath)
+	d.navidrome(cfg)
+
+	if d.failed > 0 {
+		return fmt.Errorf("%d checks failed", d.failed)
+	}
+	return nil
+}
+
+// doctor prints the outcome of each check and counts the failures.
+type doctor struct {
+	failed int
+}
+
+func (d *doctor) ok(name string, detail string) {
+	fmt.Printf("ok    %-15s %s\n", name, detail)
+}
+
+func (d *doctor) warn(name string, detail string) {
+	fmt.Printf("warn  %-15s %s\n", name, detail)
+}
+
+func (d *doctor) fail(name string, detail string) {
+	d.failed++
+	fmt.Printf("FAIL  %-15s %s\n", name, detail)
+}
+
+// program checks that an executable can be found and reports the first line of its version output. Missing
+// optional programs only warrant a warning.
+func (d *doctor) program(name string, path string, required bool, versionFlag string) {
+	report := d.fail
+	if !required {
+		report = d.warn
+	}
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		report(name, fmt.Sprintf("not found: %v", err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, resolved, versionFlag).Output()
+	if err != nil {
+		report(name, fmt.Sprintf("%s doesn't run: %v", resolved, err))
+		return
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	version, _, _ = strings.Cut(version, " Copyright")
+	d.ok(name, fmt.Sprintf("%s (%s)", version, resolved))
+}
+
+// writable checks that files can be created in dir, or in the closest folder above it when dir doesn't
+// exist yet and will be created on first use.
+func (d *doctor) writable(name string, dir string) {
+	existing := dir
+	for {
+		if _, err := os.Stat(existing); !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	f, err := os.CreateTemp(existing, ".navifetch-doctor-*")
+	if err != nil {
+		d.fail(name, fmt.Sprintf("%s is not writable: %v", dir, err))
+		return
+	}
+	f.Close()
+	_ = os.Remove(f.Name())
+	if existing != dir {
+		d.ok(name, fmt.Sprintf("%s doesn't exist yet, %s is writable", dir, existing))
+		return
+	}
+	d.ok(name, dir+" is writable")
+}
+
+// navidrome checks that NAVIDROME_BASE answers Subsonic requests and that NAVIDROME_USER can log in, with
+// the admin role rescans need.
+func (d *doctor) navidrome(cfg *config.Config) {
+	rp, err := service.NewSubsonicReverseProxy(cfg.Server.NavidromeBase)
+	if err != nil {
+		d.fail("navidrome", err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	body, status, _, err := rp.SendNavidromeRequest(ctx, "/rest/ping", "f=json")
+	if err != nil {
+		d.fail("navidrome", fmt.Sprintf("%s is unreachable: %v", cfg.Server.NavidromeBase, err))
+		return
+	}
+	if status != 200 || !strings.Contains(string(body), "subsonic-response") {
+		d.fail("navidrome", fmt.Sprintf("%s answered with status %d, is it a Navidrome server?", cfg.Server.NavidromeBase, status))
+		return
+	}
+	d.ok("navidrome", fmt.Sprintf("%s answered in %s", cfg.Server.NavidromeBase, time.Since(start).Round(time.Millisecond)))
+
+	if cfg.Auth.NavidromeUser == "" {
+		d.warn("credentials", "NAVIDROME_USER is not set, release tracking and rescans after promotions are off")
+		return
+	}
+	auth := service.ServiceAuthQuery(cfg)
+	if err := service.CheckAuth(ctx, rp, auth); err != nil {
+		d.fail("credentials", fmt.Sprintf("Navidrome rejected %s: %v", cfg.Auth.NavidromeUser, err))
+		return
+	}
+	err = service.CheckAdmin(ctx, rp, auth)
+	switch {
+	case errors.Is(err, service.ErrNotAdmin):
+		d.warn("credentials", fmt.Sprintf("%s is not an admin, Navidrome won't start scans for it", cfg.Auth.NavidromeUser))
+	case err != nil:
+		d.fail("credentials", fmt.Sprintf("checking the role of %s: %v", cfg.Auth.NavidromeUser, err))
+	default:
+		d.ok("credentials", cfg.Auth.NavidromeUser+" is a Navidrome admin")
+	}
+}
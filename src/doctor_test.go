@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+func TestDoctorWritable(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		dir        string
+		wantFailed int
+	}{
+		{name: "existing", dir: dir},
+		{name: "created on first use", dir: filepath.Join(dir, "music", "downloads")},
+		{name: "under a file", dir: filepath.Join(file, "music"), wantFailed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &doctor{}
+			d.writable("folder", tt.dir)
+			if d.failed != tt.wantFailed {
+				t.Errorf("got %d failures, want %d", d.failed, tt.wantFailed)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("left files behind: %v", entries)
+			}
+		})
+	}
+}
+
+func TestDoctorNavidrome(t *testing.T) {
+	navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.URL.Query().Get("u")
+		switch {
+		case r.URL.Path == "/not-navidrome/rest/ping":
+			_, _ = w.Write([]byte("<html></html>"))
+		case user == "mallory":
+			_ = json.NewEncoder(w).Encode(model.ErrorResponse(40, "Wrong username or password"))
+		case r.URL.Path == "/rest/getUser":
+			var resp model.SubsonicUserResponse
+			resp.Subsonic.Envelope = model.OKEnvelope()
+			resp.Subsonic.User = &model.SubsonicUser{Username: user, AdminRole: user == "alice"}
+			_ = json.NewEncoder(w).Encode(resp)
+		default:
+			_ = json.NewEncoder(w).Encode(model.OKResponse())
+		}
+	}))
+	defer navidrome.Close()
+
+	tests := []struct {
+		name       string
+		base       string
+		user       string
+		wantFailed int
+	}{
+		{name: "admin", base: navidrome.URL, user: "alice"},
+		{name: "regular user", base: navidrome.URL, user: "bob"},
+		{name: "no user", base: navidrome.URL},
+		{name: "rejected user", base: navidrome.URL, user: "mallory", wantFailed: 1},
+		{name: "not navidrome", base: navidrome.URL + "/not-navidrome", wantFailed: 1},
+		{name: "unreachable", base: "http://127.0.0.1:1", wantFailed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Server.NavidromeBase = tt.base
+			cfg.Auth.NavidromeUser = tt.user
+			cfg.Auth.NavidromePassword = "secret"
+			d := &doctor{}
+			d.navidrome(cfg)
+			if d.failed != tt.wantFailed {
+				t.Errorf("got %d failures, want %d", d.failed, tt.wantFailed)
+			}
+		})
+	}
+}
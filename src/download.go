@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/lyrics"
+	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/service"
+)
+
+// runDownload implements "navifetch download [-cache] EXTERNAL-ID|QUERY", which runs the server's download
+// pipeline on its own. A query downloads the metadata provider's best match.
+func runDownload(args []string) error {
+	flags := flag.NewFlagSet("download", flag.ExitOnError)
+	cache := flags.Bool("cache", false, "save to the streaming-only cache, removed after CACHE_MAX_AGE, rather than for good")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: navifetch download [flags] EXTERNAL-ID|QUERY")
+		fmt.Fprintln(flags.Output(), "Downloads a song, by the external ID search prints or the best match for a query, into the library.")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	arg := strings.Join(flags.Args(), " ")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	rp, err := service.NewSubsonicReverseProxy(cfg.Server.NavidromeBase)
+	if err != nil {
+		return err
+	}
+	p, err := metadata.NewProvider(cfg)
+	if err != nil {
+		return err
+	}
+	lyricsProvider, err := lyrics.NewProvider(cfg)
+	if err != nil {
+		return err
+	}
+	stream := service.NewStreamService(config.NewHolder(cfg), rp, p, lyricsProvider)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	id := arg
+	if !strings.HasPrefix(id, "external-") {
+		searchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		songs, err := p.SearchSongs(searchCtx, arg)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if len(songs) == 0 {
+			return fmt.Errorf("no song matches %q", arg)
+		}
+		id = songs[0].ID
+		fmt.Printf("Best match: %s - %s (%s)\n", songs[0].Artist, trimExternal(songs[0].Title), id)
+	}
+
+	type result struct {
+		song *model.SubsonicSong
+		path string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		song, path, err := stream.DownloadTrack(ctx, strings.TrimPrefix(id, "external-"), !*cache)
+		done <- result{song, path, err}
+	}()
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		// ctx is already done, so this interrupts yt-dlp and waits for the partial files to be removed.
+		_ = stream.Shutdown(ctx)
+		res = <-done
+	}
+	if ctx.Err() != nil {
+		return errors.New("interrupted")
+	}
+	if res.err != nil {
+		return res.err
+	}
+	fmt.Printf("Saved %s - %s to %s\n", res.song.Artist, trimExternal(res.song.Title), res.path)
+
+	if cfg.Auth.NavidromeUser == "" {
+		fmt.Println("Navidrome lists it after its next scan, set NAVIDROME_USER to start one right away.")
+		return nil
+	}
+	scanCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := service.StartScan(scanCtx, rp, service.ServiceAuthQuery(cfg)); err != nil {
+		return fmt.Errorf("saved, but Navidrome didn't start a scan: %w", err)
+	}
+	return nil
+}
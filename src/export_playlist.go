@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/playlist"
+	"github.com/GerardPolloRebozado/navifetch/src/service"
+)
+
+// runExportPlaylist implements "navifetch export-playlist [-format m3u8|jspf] [-o FILE] PLAYLIST", where
+// PLAYLIST is a Navidrome playlist ID or name.
+func runExportPlaylist(args []string) error {
+	flags := flag.NewFlagSet("export-playlist", flag.ExitOnError)
+	format := flags.String("format", "m3u8", "output format, m3u8 or jspf")
+	output := flags.String("o", "", "file to write, defaults to standard output")
+	user := flags.String("user", "", "Navidrome user the playlist is visible to, defaults to NAVIDROME_USER")
+	password := flags.String("password", "", "password of -user, defaults to NAVIDROME_PASSWORD")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: navifetch export-playlist [flags] PLAYLIST")
+		fmt.Fprintln(flags.Output(), "Exports a Navidrome playlist, by ID or name, with paths relative to the music library.")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := loadUserConfig(*user, *password)
+	if err != nil {
+		return err
+	}
+	rp, err := service.NewSubsonicReverseProxy(cfg.Server.NavidromeBase)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	pl, err := service.NewPlaylistExporter(config.NewHolder(cfg), rp).Export(ctx, flags.Arg(0), service.ServiceAuthQuery(cfg))
+	if err != nil {
+		return err
+	}
+	body, err := playlist.Encode(pl, *format)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(body)
+		return err
+	}
+	return os.WriteFile(*output, body, 0644)
+}
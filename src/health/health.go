@@ -0,0 +1,70 @@
+// Package health keeps track of how the metadata and lyrics providers have been answering lately, for the
+// admin UI. Unlike the metrics, it remembers when each provider last failed and why.
+package health
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/logging"
+)
+
+// Provider summarises the calls made to one provider since Navifetch started.
+type Provider struct {
+	Name   string `json:"name"`
+	Calls  int64  `json:"calls"`
+	Errors int64  `json:"errors"`
+	// Healthy is false when the last call failed.
+	Healthy          bool       `json:"healthy"`
+	AverageLatencyMs int64      `json:"averageLatencyMs"`
+	LastSuccess      *time.Time `json:"lastSuccess,omitempty"`
+	LastFailure      *time.Time `json:"lastFailure,omitempty"`
+	LastError        string     `json:"lastError,omitempty"`
+
+	totalLatency time.Duration
+}
+
+var (
+	mu        sync.Mutex
+	providers = make(map[string]*Provider)
+)
+
+// Record notes a call to the named provider that took d, failing with err unless err is nil. Callers leave
+// out errors that don't mean the provider is unwell, such as lyrics that don't exist.
+func Record(name string, d time.Duration, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	p, ok := providers[name]
+	if !ok {
+		p = &Provider{Name: name, Healthy: true}
+		providers[name] = p
+	}
+	now := time.Now()
+	p.Calls++
+	p.totalLatency += d
+	p.AverageLatencyMs = (p.totalLatency / time.Duration(p.Calls)).Milliseconds()
+	if err != nil {
+		p.Errors++
+		p.Healthy = false
+		p.LastFailure = &now
+		p.LastError = logging.Redact(err.Error())
+		return
+	}
+	p.Healthy = true
+	p.LastSuccess = &now
+}
+
+// Providers returns every provider called so far, sorted by name.
+func Providers() []Provider {
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
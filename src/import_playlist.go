@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/lyrics"
+	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+	"github.com/GerardPolloRebozado/navifetch/src/playlist"
+	"github.com/GerardPolloRebozado/navifetch/src/service"
+)
+
+// runImportPlaylist implements "navifetch import-playlist [-name NAME] [-user USER -password PASSWORD] FILE".
+// The playlist belongs to NAVIDROME_USER unless another user is given.
+func runImportPlaylist(args []string) error {
+	flags := flag.NewFlagSet("import-playlist", flag.ExitOnError)
+	name := flags.String("name", "", "playlist name, defaults to the one in the file or the file name")
+	user := flags.String("user", "", "Navidrome user that will own the playlist, defaults to NAVIDROME_USER")
+	password := flags.String("password", "", "password of -user, defaults to NAVIDROME_PASSWORD")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: navifetch import-playlist [flags] FILE")
+		fmt.Fprintln(flags.Output(), "Imports an M3U/M3U8, XSPF, JSPF or CSV (artist,title,album,isrc) playlist into Navidrome.")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	path := flags.Arg(0)
+
+	cfg, err := loadUserConfig(*user, *password)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	pl, err := playlist.Parse(path, data)
+	if err != nil {
+		return err
+	}
+	if *name != "" {
+		pl.Name = *name
+	}
+
+	rp, err := service.NewSubsonicReverseProxy(cfg.Server.NavidromeBase)
+	if err != nil {
+		return err
+	}
+	p, err := metadata.NewProvider(cfg)
+	if err != nil {
+		return err
+	}
+	lyricsProvider, err := lyrics.NewProvider(cfg)
+	if err != nil {
+		return err
+	}
+	importer := service.NewPlaylistImporter(rp, p, service.NewStreamService(config.NewHolder(cfg), rp, p, lyricsProvider))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	auth := service.ServiceAuthQuery(cfg)
+	if err := service.CheckAuth(ctx, rp, auth); err != nil {
+		return fmt.Errorf("navidrome rejected %s: %w", cfg.Auth.NavidromeUser, err)
+	}
+
+	report, err := importer.Import(ctx, pl, auth)
+	if report != nil {
+		fmt.Printf("Playlist %q: %d tracks, %d already in the library, %d downloaded, %d unmatched\n",
+			report.Name, report.Total, report.Local, report.Downloaded, len(report.Unmatched))
+		for _, unmatched := range report.Unmatched {
+			fmt.Printf("  unmatched: %s - %s (%s)\n", unmatched.Entry.Artist, unmatched.Entry.Title, unmatched.Reason)
+		}
+	}
+	return err
+}
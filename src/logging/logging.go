@@ -0,0 +1,139 @@
+// Package logging sets up Navifetch's structured logger. Every record goes through a handler that redacts
+// Subsonic credentials and API keys and tags records with the request ID found in their context.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+)
+
+// RequestIDHeader carries the request ID from clients to Navifetch and from Navifetch to Navidrome.
+const RequestIDHeader = "X-Request-ID"
+
+// level is shared by every logger Setup creates, so a reload can change it in place.
+var level = new(slog.LevelVar)
+
+// Setup makes a logger configured by cfg.Log the default for slog and the standard log package.
+func Setup(cfg *config.Config) {
+	slog.SetDefault(slog.New(NewHandler(os.Stderr, cfg.Log.Format)))
+	SetLevel(cfg.Log.Level)
+}
+
+// SetLevel changes the minimum level of the loggers made by Setup, ignoring unknown levels.
+func SetLevel(name string) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err == nil {
+		level.Set(l)
+	}
+}
+
+// NewHandler returns a text or JSON handler writing to w that redacts secrets and adds request IDs.
+func NewHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	if format == "json" {
+		return &handler{slog.NewJSONHandler(w, opts)}
+	}
+	return &handler{slog.NewTextHandler(w, opts)}
+}
+
+// handler adds the request ID of the record's context.
+type handler struct {
+	slog.Handler
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{h.Handler.WithGroup(name)}
+}
+
+// secretParams are query parameters whose values never reach the logs: Subsonic passwords, tokens and
+// salts, and the API keys of metadata providers.
+var secretParams = []string{"p", "t", "s", "password", "apiKey", "api_key", "token"}
+
+var secretParam = regexp.MustCompile(`(^|[?&\s])(` + strings.Join(secretParams, "|") + `)=[^&\s"]*`)
+
+// Redact hides the values of secret query parameters anywhere in s, such as in a URL or an error quoting one.
+func Redact(s string) string {
+	return secretParam.ReplaceAllString(s, "${1}${2}=REDACTED")
+}
+
+// RedactQuery returns a raw query with the values of secret parameters replaced.
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redact(rawQuery)
+	}
+	for _, key := range secretParams {
+		if values.Has(key) {
+			values.Set(key, "REDACTED")
+		}
+	}
+	return values.Encode()
+}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(Redact(err.Error()))
+		} else if s, ok := a.Value.Any().(fmt.Stringer); ok {
+			a.Value = slog.StringValue(Redact(s.String()))
+		}
+	}
+	return a
+}
+
+type requestIDKey struct{}
+
+// NewRequestID returns a random ID for a request that didn't bring one.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns ctx carrying id, or ctx itself when id is empty.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ValidRequestID reports whether a client-supplied request ID is safe to log and forward.
+func ValidRequestID(id string) bool {
+	return validRequestID.MatchString(id)
+}
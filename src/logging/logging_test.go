@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "nothing secret", in: "/rest/getSong?id=1&f=json", want: "/rest/getSong?id=1&f=json"},
+		{
+			name: "password in url",
+			in:   "http://navidrome:4533/rest/ping?u=alice&p=enc:736563726574&v=1.16.1",
+			want: "http://navidrome:4533/rest/ping?u=alice&p=REDACTED&v=1.16.1",
+		},
+		{name: "token and salt", in: "?t=abc123&s=salt&u=alice", want: "?t=REDACTED&s=REDACTED&u=alice"},
+		{name: "bare query", in: "apiKey=key&id=1", want: "apiKey=REDACTED&id=1"},
+		{name: "provider key", in: "GET https://ws.audioscrobbler.com/2.0/?method=track.getInfo&api_key=abc", want: "GET https://ws.audioscrobbler.com/2.0/?method=track.getInfo&api_key=REDACTED"},
+		{name: "quoted in an error", in: `Get "http://navidrome/rest/ping?p=secret": EOF`, want: `Get "http://navidrome/rest/ping?p=REDACTED": EOF`},
+		{name: "after a space", in: "request token=abc failed", want: "request token=REDACTED failed"},
+		{name: "similar names kept", in: "?songs=1&ids=2&tp=3&password2=4", want: "?songs=1&ids=2&tp=3&password2=4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want url.Values
+	}{
+		{name: "empty", in: ""},
+		{
+			name: "subsonic auth",
+			in:   "u=alice&t=abc&s=def&v=1.16.1&c=app&id=1",
+			want: url.Values{"u": {"alice"}, "t": {"REDACTED"}, "s": {"REDACTED"}, "v": {"1.16.1"}, "c": {"app"}, "id": {"1"}},
+		},
+		{
+			name: "repeated password",
+			in:   "p=one&p=two&apiKey=key&password=x&token=y",
+			want: url.Values{"p": {"REDACTED"}, "apiKey": {"REDACTED"}, "password": {"REDACTED"}, "token": {"REDACTED"}},
+		},
+		{name: "escaped secret", in: "p=a%26b%3Dc&id=1", want: url.Values{"p": {"REDACTED"}, "id": {"1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RedactQuery(tt.in)
+			if tt.want == nil {
+				if got != "" {
+					t.Errorf("got %q", got)
+				}
+				return
+			}
+			if got != tt.want.Encode() {
+				t.Errorf("got %q, want %q", got, tt.want.Encode())
+			}
+		})
+	}
+
+	// Unparsable queries are still redacted.
+	if got := RedactQuery("p=secret%zz&id=1"); strings.Contains(got, "secret") {
+		t.Errorf("got %q", got)
+	}
+}
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		attr   any
+	}{
+		{name: "text string", format: "text", attr: "http://navidrome/rest/ping?p=secret"},
+		{name: "json error", format: "json", attr: errors.New(`Get "http://navidrome/rest/ping?t=secret&s=secret"`)},
+		{name: "text stringer", format: "text", attr: stringer("u=alice&apiKey=secret")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewHandler(&buf, tt.format))
+			logger.InfoContext(WithRequestID(context.Background(), "req-1"), "Request", "detail", tt.attr)
+
+			out := buf.String()
+			if strings.Contains(out, "secret") || !strings.Contains(out, "REDACTED") {
+				t.Errorf("secret not redacted: %s", out)
+			}
+			if !strings.Contains(out, "req-1") {
+				t.Errorf("request ID missing: %s", out)
+			}
+		})
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"a1b2c3d4e5f60718", true},
+		{"client-req_1.2", true},
+		{"", false},
+		{"has space", false},
+		{"new\nline", false},
+		{strings.Repeat("a", 65), false},
+	}
+	for _, tt := range tests {
+		if got := ValidRequestID(tt.id); got != tt.want {
+			t.Errorf("ValidRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+	if id := NewRequestID(); !ValidRequestID(id) {
+		t.Errorf("NewRequestID returned %q", id)
+	}
+}
@@ -0,0 +1,146 @@
+package lyrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/health"
+	"github.com/GerardPolloRebozado/navifetch/src/metrics"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+// Track identifies the song lyrics are looked up for, Duration is in seconds and may be 0.
+type Track struct {
+	Artist   string
+	Title    string
+	Album    string
+	Duration int64
+}
+
+// Lyrics holds what a provider knows about a track, Synced is in LRC format.
+type Lyrics struct {
+	Plain        string
+	Synced       string
+	Instrumental bool
+}
+
+type Provider interface {
+	GetLyrics(ctx context.Context, track Track) (*Lyrics, error)
+}
+
+// ErrNotFound is returned when the provider has no lyrics for the track.
+var ErrNotFound = errors.New("lyrics not found")
+
+// NewProvider returns the configured lyrics provider, or nil when lyrics lookups are disabled.
+func NewProvider(cfg *config.Config) (Provider, error) {
+	switch cfg.Providers.Lyrics {
+	case "", "none":
+		return nil, nil
+	case "lrclib":
+		return instrument("lrclib", NewLRCLibProvider(cfg.Providers.LyricsAPIBase)), nil
+	default:
+		return nil, fmt.Errorf("unsupported lyrics provider: %s", cfg.Providers.Lyrics)
+	}
+}
+
+var lrcTimestamp = regexp.MustCompile(`^\[(\d+):(\d{2})(?:[.:](\d{1,3}))?\]`)
+
+// ParseLRC turns LRC text into timed lines. Lines with several timestamps are repeated for each of them,
+// tags such as [ar:] and lines without a timestamp are dropped.
+func ParseLRC(lrc string) []model.LyricLine {
+	lines := make([]model.LyricLine, 0)
+	for _, raw := range strings.Split(lrc, "\n") {
+		raw = strings.TrimSpace(raw)
+		var starts []int64
+		for {
+			m := lrcTimestamp.FindStringSubmatch(raw)
+			if m == nil {
+				break
+			}
+			minutes, _ := strconv.ParseInt(m[1], 10, 64)
+			seconds, _ := strconv.ParseInt(m[2], 10, 64)
+			var millis int64
+			if m[3] != "" {
+				fraction, _ := strconv.ParseInt(m[3], 10, 64)
+				// Pad .5 and .50 to the same 500ms.
+				for i := len(m[3]); i < 3; i++ {
+					fraction *= 10
+				}
+				millis = fraction
+			}
+			starts = append(starts, (minutes*60+seconds)*1000+millis)
+			raw = raw[len(m[0]):]
+		}
+		for _, start := range starts {
+			lines = append(lines, model.LyricLine{Start: &start, Value: strings.TrimSpace(raw)})
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return *lines[i].Start < *lines[j].Start
+	})
+	return lines
+}
+
+// PlainText returns the lyrics without timing, derived from the synced version when there's no plain one.
+func (l *Lyrics) PlainText() string {
+	if l.Plain != "" {
+		return l.Plain
+	}
+	parsed := ParseLRC(l.Synced)
+	values := make([]string, 0, len(parsed))
+	for _, line := range parsed {
+		values = append(values, line.Value)
+	}
+	return strings.Join(values, "\n")
+}
+
+// Structured converts the lyrics to the OpenSubsonic form, preferring the synced version.
+func (l *Lyrics) Structured(track Track) model.StructuredLyrics {
+	structured := model.StructuredLyrics{
+		DisplayArtist: track.Artist,
+		DisplayTitle:  track.Title,
+		Lang:          "xxx",
+		Line:          make([]model.LyricLine, 0),
+	}
+	if l.Synced != "" {
+		structured.Synced = true
+		structured.Line = ParseLRC(l.Synced)
+		return structured
+	}
+	for _, line := range strings.Split(l.Plain, "\n") {
+		structured.Line = append(structured.Line, model.LyricLine{Value: strings.TrimRight(line, "\r")})
+	}
+	return structured
+}
+
+// instrumented records the latency and errors of lyrics lookups. Lyrics that don't exist are not errors.
+type instrumented struct {
+	name string
+	p    Provider
+}
+
+func instrument(name string, p Provider) Provider {
+	return &instrumented{name: name, p: p}
+}
+
+func (i *instrumented) GetLyrics(ctx context.Context, track Track) (*Lyrics, error) {
+	start := time.Now()
+	found, err := i.p.GetLyrics(ctx, track)
+	metrics.ProviderDuration.Since(start, i.name, "GetLyrics")
+	failure := err
+	if errors.Is(err, ErrNotFound) {
+		failure = nil
+	}
+	if failure != nil {
+		metrics.ProviderErrors.Inc(i.name, "GetLyrics")
+	}
+	health.Record(i.name, time.Since(start), failure)
+	return found, err
+}
@@ -0,0 +1,108 @@
+package lyrics
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+type timedLine struct {
+	start int64
+	value string
+}
+
+func timed(lines []model.LyricLine) []timedLine {
+	out := make([]timedLine, 0, len(lines))
+	for _, line := range lines {
+		start := int64(-1)
+		if line.Start != nil {
+			start = *line.Start
+		}
+		out = append(out, timedLine{start, line.Value})
+	}
+	return out
+}
+
+func TestParseLRC(t *testing.T) {
+	tests := []struct {
+		name string
+		lrc  string
+		want []timedLine
+	}{
+		{name: "empty", want: []timedLine{}},
+		{
+			name: "centiseconds and milliseconds",
+			lrc:  "[00:01.50] One more time\n[00:03.250]We're gonna celebrate\r\n[01:02]Oh yeah",
+			want: []timedLine{{1500, "One more time"}, {3250, "We're gonna celebrate"}, {62000, "Oh yeah"}},
+		},
+		{
+			name: "tags and untimed lines dropped",
+			lrc:  "[ar:Daft Punk]\n[ti:One More Time]\nno timestamp\n[00:05.1]Celebrate",
+			want: []timedLine{{5100, "Celebrate"}},
+		},
+		{
+			name: "repeated lines",
+			lrc:  "[00:10.00][00:30.00]Chorus\n[00:20.00]Verse",
+			want: []timedLine{{10000, "Chorus"}, {20000, "Verse"}, {30000, "Chorus"}},
+		},
+		{
+			name: "instrumental gap",
+			lrc:  "[00:01.00]Start\n[00:02.00]\n",
+			want: []timedLine{{1000, "Start"}, {2000, ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timed(ParseLRC(tt.lrc)); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLyricsConversions(t *testing.T) {
+	track := Track{Artist: "Daft Punk", Title: "One More Time"}
+	tests := []struct {
+		name       string
+		lyrics     Lyrics
+		wantPlain  string
+		wantSynced bool
+		wantLines  []timedLine
+	}{
+		{
+			name:       "synced only",
+			lyrics:     Lyrics{Synced: "[00:01.00]One more time\n[00:02.00]Celebrate"},
+			wantPlain:  "One more time\nCelebrate",
+			wantSynced: true,
+			wantLines:  []timedLine{{1000, "One more time"}, {2000, "Celebrate"}},
+		},
+		{
+			name:      "plain only",
+			lyrics:    Lyrics{Plain: "One more time\r\nCelebrate"},
+			wantPlain: "One more time\r\nCelebrate",
+			wantLines: []timedLine{{-1, "One more time"}, {-1, "Celebrate"}},
+		},
+		{
+			name:       "both",
+			lyrics:     Lyrics{Plain: "Plain version", Synced: "[00:01.00]Synced version"},
+			wantPlain:  "Plain version",
+			wantSynced: true,
+			wantLines:  []timedLine{{1000, "Synced version"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lyrics.PlainText(); got != tt.wantPlain {
+				t.Errorf("got plain text %q, want %q", got, tt.wantPlain)
+			}
+			structured := tt.lyrics.Structured(track)
+			if structured.Synced != tt.wantSynced || structured.DisplayArtist != track.Artist || structured.DisplayTitle != track.Title {
+				t.Errorf("got synced %v, artist %q, title %q", structured.Synced, structured.DisplayArtist, structured.DisplayTitle)
+			}
+			if got := timed(structured.Line); !slices.Equal(got, tt.wantLines) {
+				t.Errorf("got lines %v, want %v", got, tt.wantLines)
+			}
+		})
+	}
+}
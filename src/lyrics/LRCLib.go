@@ -0,0 +1,128 @@
+package lyrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/GerardPolloRebozado/navifetch/src/util"
+)
+
+const LRCLibAPIBase = "https://lrclib.net"
+
+// LRCLibProvider talks to LRCLIB or any server exposing the same /api/get and /api/search endpoints.
+type LRCLibProvider struct {
+	baseURL string
+}
+
+type lrclibRecord struct {
+	ID           int64   `json:"id"`
+	TrackName    string  `json:"trackName"`
+	ArtistName   string  `json:"artistName"`
+	AlbumName    string  `json:"albumName"`
+	Duration     float64 `json:"duration"`
+	Instrumental bool    `json:"instrumental"`
+	PlainLyrics  string  `json:"plainLyrics"`
+	SyncedLyrics string  `json:"syncedLyrics"`
+}
+
+func NewLRCLibProvider(baseURL string) *LRCLibProvider {
+	if baseURL == "" {
+		baseURL = LRCLibAPIBase
+	}
+	return &LRCLibProvider{baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// GetLyrics tries the exact signature lookup first, which needs the duration, and falls back to a search.
+func (p *LRCLibProvider) GetLyrics(ctx context.Context, track Track) (*Lyrics, error) {
+	if track.Duration > 0 {
+		params := url.Values{}
+		params.Set("artist_name", track.Artist)
+		params.Set("track_name", track.Title)
+		params.Set("album_name", track.Album)
+		params.Set("duration", strconv.FormatInt(track.Duration, 10))
+
+		var record lrclibRecord
+		found, err := p.get(ctx, "/api/get?"+params.Encode(), &record)
+		if err != nil {
+			return nil, err
+		}
+		if found && hasLyrics(record) {
+			return toLyrics(record), nil
+		}
+	}
+
+	params := url.Values{}
+	params.Set("artist_name", track.Artist)
+	params.Set("track_name", track.Title)
+	var records []lrclibRecord
+	if _, err := p.get(ctx, "/api/search?"+params.Encode(), &records); err != nil {
+		return nil, err
+	}
+
+	// Prefer synced lyrics from the record closest in length.
+	var best *lrclibRecord
+	for i, record := range records {
+		if !hasLyrics(record) || !strings.EqualFold(strings.TrimSpace(record.ArtistName), strings.TrimSpace(track.Artist)) {
+			continue
+		}
+		if best == nil || betterRecord(record, *best, track.Duration) {
+			best = &records[i]
+		}
+	}
+	if best == nil {
+		return nil, ErrNotFound
+	}
+	return toLyrics(*best), nil
+}
+
+func (p *LRCLibProvider) get(ctx context.Context, path string, v any) (bool, error) {
+	headers := map[string]string{"User-Agent": "navifetch/0.10.1 +https://github.com/GerardPolloRebozado/navifetch"}
+	body, status, _, err := util.HTTPGet(ctx, p.baseURL+path, headers)
+	if err != nil {
+		return false, err
+	}
+	if status == http.StatusNotFound {
+		return false, nil
+	}
+	if status != http.StatusOK {
+		return false, fmt.Errorf("lrclib returned status %d", status)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func hasLyrics(record lrclibRecord) bool {
+	return record.Instrumental || record.PlainLyrics != "" || record.SyncedLyrics != ""
+}
+
+func betterRecord(a, b lrclibRecord, duration int64) bool {
+	if (a.SyncedLyrics != "") != (b.SyncedLyrics != "") {
+		return a.SyncedLyrics != ""
+	}
+	if duration <= 0 {
+		return false
+	}
+	distance := func(r lrclibRecord) float64 {
+		d := r.Duration - float64(duration)
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	return distance(a) < distance(b)
+}
+
+func toLyrics(record lrclibRecord) *Lyrics {
+	return &Lyrics{
+		Plain:        record.PlainLyrics,
+		Synced:       record.SyncedLyrics,
+		Instrumental: record.Instrumental,
+	}
+}
@@ -0,0 +1,91 @@
+package lyrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// lrclibServer answers /api/get with get when it's set and /api/search with search.
+func lrclibServer(t *testing.T, get *lrclibRecord, search []lrclibRecord) *LRCLibProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body any
+		switch r.URL.Path {
+		case "/api/get":
+			if get == nil {
+				http.NotFound(w, r)
+				return
+			}
+			body = get
+		case "/api/search":
+			body = search
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(srv.Close)
+	return NewLRCLibProvider(srv.URL)
+}
+
+func TestLRCLibGetLyrics(t *testing.T) {
+	exact := &lrclibRecord{ArtistName: "Daft Punk", PlainLyrics: "exact"}
+	search := []lrclibRecord{
+		{ArtistName: "Someone Else", SyncedLyrics: "[00:01.00]cover"},
+		{ArtistName: "Daft Punk", Duration: 320, PlainLyrics: "plain"},
+		{ArtistName: "daft punk", Duration: 400, SyncedLyrics: "[00:01.00]radio edit"},
+		{ArtistName: "Daft Punk", Duration: 321, SyncedLyrics: "[00:01.00]album"},
+		{ArtistName: "Daft Punk"},
+	}
+	tests := []struct {
+		name    string
+		track   Track
+		get     *lrclibRecord
+		search  []lrclibRecord
+		want    string
+		wantErr error
+	}{
+		{name: "exact match", track: Track{Artist: "Daft Punk", Title: "One More Time", Duration: 320}, get: exact, search: search, want: "exact"},
+		{name: "synced record closest in length", track: Track{Artist: "Daft Punk", Title: "One More Time", Duration: 320}, search: search, want: "[00:01.00]album"},
+		{name: "first synced record without a duration", track: Track{Artist: "Daft Punk", Title: "One More Time"}, get: exact, search: search, want: "[00:01.00]radio edit"},
+		{name: "exact match without lyrics", track: Track{Artist: "Daft Punk", Title: "One More Time", Duration: 320}, get: &lrclibRecord{}, search: search[1:2], want: "plain"},
+		{name: "other artists only", track: Track{Artist: "Daft Punk", Title: "One More Time"}, search: search[:1], wantErr: ErrNotFound},
+		{name: "nothing", track: Track{Artist: "Daft Punk", Title: "One More Time", Duration: 320}, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := lrclibServer(t, tt.get, tt.search)
+			got, err := p.GetLyrics(context.Background(), tt.track)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := got.Synced + got.Plain; text != tt.want {
+				t.Errorf("got %q, want %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestLRCLibServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	_, err := NewLRCLibProvider(srv.URL).GetLyrics(context.Background(), Track{Artist: "Daft Punk", Title: "One More Time"})
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want a server error", err)
+	}
+}
@@ -0,0 +1,36 @@
+package lyrics
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Swappable is a Provider whose underlying provider can be replaced while it's in use, so a configuration
+// reload reaches every service holding it. With no provider, lookups are disabled and report ErrNotFound.
+type Swappable struct {
+	current atomic.Pointer[providerRef]
+}
+
+// providerRef boxes the interface so providers of different types can share one atomic.Pointer.
+type providerRef struct {
+	Provider
+}
+
+func NewSwappable(p Provider) *Swappable {
+	s := &Swappable{}
+	s.Swap(p)
+	return s
+}
+
+// Swap makes p, which may be nil, the provider every following lookup goes to.
+func (s *Swappable) Swap(p Provider) {
+	s.current.Store(&providerRef{Provider: p})
+}
+
+func (s *Swappable) GetLyrics(ctx context.Context, track Track) (*Lyrics, error) {
+	p := s.current.Load().Provider
+	if p == nil {
+		return nil, ErrNotFound
+	}
+	return p.GetLyrics(ctx, track)
+}
@@ -1,37 +1,85 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/GerardPolloRebozado/navifetch/src/api"
 	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/logging"
 	"github.com/GerardPolloRebozado/navifetch/src/service"
 )
 
 func main() {
+	name, args := "serve", []string(nil)
+	if len(os.Args) > 1 {
+		name, args = os.Args[1], os.Args[2:]
+	}
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage(os.Stdout)
+		return
+	}
+	run, ok := subcommands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "navifetch: unknown command %q\n\n", name)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	if err := run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// runServe implements "navifetch serve", the proxy server, which is also what runs without a command.
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: navifetch serve")
+		fmt.Fprintln(flags.Output(), "Runs the proxy in front of Navidrome until SIGINT or SIGTERM.")
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("config load error: %v", err)
+		fatal("Config load error", err)
 	}
+	logging.Setup(cfg)
 
-	rp, err := service.NewSubsonicReverseProxy(cfg.NavidromeBase)
+	rp, err := service.NewSubsonicReverseProxy(cfg.Server.NavidromeBase)
 	if err != nil {
-		log.Fatalf("proxy creation error: %v", err)
+		fatal("Proxy creation error", err)
 	}
 
-	h := api.NewHandler(cfg, rp)
+	holder := config.NewHolder(cfg)
+	holder.OnReload(func(_, updated *config.Config) {
+		logging.SetLevel(updated.Log.Level)
+	})
+	h, err := api.NewHandler(holder, rp)
+	if err != nil {
+		fatal("Handler creation error", err)
+	}
 	mux := http.NewServeMux()
 
 	api.RegisterRoutes(mux, h)
 
 	// Middleware
-	handler := api.LoggingMiddleware(mux)
+	handler := api.LoggingMiddleware(api.MetricsMiddleware(mux))
 	handler = api.CORSMiddleware(handler)
 
 	srv := &http.Server{
-		Addr:              ":" + cfg.Port,
+		Addr:              ":" + cfg.Server.Port,
 		Handler:           handler,
 		ReadTimeout:       15 * time.Second,
 		ReadHeaderTimeout: 15 * time.Second,
@@ -39,11 +87,45 @@ func main() {
 		IdleTimeout:       60 * time.Second,
 	}
 
-	// Start background services
-	service.StartCleanupCron(cfg)
+	// Background services run until ctx is cancelled by SIGINT or SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	service.StartCleanupCron(ctx, holder, h.Tracks())
+	h.Releases().Start(ctx)
+	h.Annotations().Start(ctx)
+	h.WantedList().Start(ctx)
+	holder.Watch(ctx)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Proxy listening", "port", cfg.Server.Port, "navidrome", cfg.Server.NavidromeBase)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		fatal("Server error", err)
+	case <-ctx.Done():
+	}
+	stop()
 
-	log.Printf("Proxy listening on :%s, forwarding to %s", cfg.Port, cfg.NavidromeBase)
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("server error: %v", err)
+	// Stop taking requests, then give the ones in flight and the downloads the shutdown timeout to finish.
+	// Downloads still running after it are interrupted and their partial files removed.
+	timeout := holder.Get().Server.ShutdownTimeout
+	slog.Info("Shutting down, waiting for requests and downloads", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Requests still running at shutdown", "err", err)
+	}
+	if err := h.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Downloads interrupted at shutdown", "err", err)
 	}
+	slog.Info("Shutdown complete")
+	return nil
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
 }
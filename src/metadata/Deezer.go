@@ -0,0 +1,489 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
+)
+
+const DeezerAPIBase = "https://api.deezer.com"
+
+// Deezer IDs are namespaced so they can be routed back to this provider when
+// it is only used for enrichment, and because tracks, albums and artists share
+// the same numeric space.
+const (
+	DeezerIDPrefix     = "deezer-"
+	deezerTrackPrefix  = DeezerIDPrefix + "track-"
+	deezerAlbumPrefix  = DeezerIDPrefix + "album-"
+	deezerArtistPrefix = DeezerIDPrefix + "artist-"
+)
+
+type DeezerProvider struct {
+	baseURL string
+	limit   int
+}
+
+type deezerError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+type deezerArtist struct {
+	ID            int64        `json:"id"`
+	Name          string       `json:"name"`
+	Picture       string       `json:"picture"`
+	PictureSmall  string       `json:"picture_small"`
+	PictureMedium string       `json:"picture_medium"`
+	PictureBig    string       `json:"picture_big"`
+	PictureXL     string       `json:"picture_xl"`
+	NbAlbum       int64        `json:"nb_album"`
+	Error         *deezerError `json:"error,omitempty"`
+}
+
+type deezerAlbum struct {
+	ID          int64        `json:"id"`
+	Title       string       `json:"title"`
+	UPC         string       `json:"upc"`
+	Cover       string       `json:"cover"`
+	CoverSmall  string       `json:"cover_small"`
+	CoverMedium string       `json:"cover_medium"`
+	CoverBig    string       `json:"cover_big"`
+	CoverXL     string       `json:"cover_xl"`
+	NbTracks    int64        `json:"nb_tracks"`
+	Duration    int          `json:"duration"`
+	ReleaseDate string       `json:"release_date"`
+	RecordType  string       `json:"record_type"`
+	Artist      deezerArtist `json:"artist"`
+	Genres      struct {
+		Data []struct {
+			Name string `json:"name"`
+		} `json:"data"`
+	} `json:"genres"`
+	Error *deezerError `json:"error,omitempty"`
+}
+
+type deezerTrack struct {
+	ID             int64        `json:"id"`
+	Title          string       `json:"title"`
+	TitleShort     string       `json:"title_short"`
+	ISRC           string       `json:"isrc"`
+	Duration       int64        `json:"duration"`
+	TrackPosition  int          `json:"track_position"`
+	DiskNumber     int          `json:"disk_number"`
+	ReleaseDate    string       `json:"release_date"`
+	ExplicitLyrics bool         `json:"explicit_lyrics"`
+	Bpm            float64      `json:"bpm"`
+	Artist         deezerArtist `json:"artist"`
+	Album          deezerAlbum  `json:"album"`
+	Error          *deezerError `json:"error,omitempty"`
+}
+
+type deezerList[T any] struct {
+	Data  []T          `json:"data"`
+	Total int          `json:"total"`
+	Error *deezerError `json:"error,omitempty"`
+}
+
+func NewDeezerProvider(baseURL string, limit int) *DeezerProvider {
+	return &DeezerProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		limit:   limit,
+	}
+}
+
+func (p *DeezerProvider) SearchSongs(ctx context.Context, query string) ([]model.SubsonicSong, error) {
+	slog.DebugContext(ctx, "Searching Deezer", "query", query)
+	var res deezerList[deezerTrack]
+	if err := p.get(ctx, "/search/track", url.Values{"q": {query}, "limit": {strconv.Itoa(p.limit)}}, &res); err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	songs := make([]model.SubsonicSong, 0)
+	for _, t := range res.Data {
+		songs = append(songs, p.DeezerTrackToSubsonicSong(t, t.Album))
+	}
+	return songs, nil
+}
+
+func (p *DeezerProvider) SearchAlbums(ctx context.Context, query string) ([]model.SubsonicAlbum, error) {
+	var res deezerList[deezerAlbum]
+	if err := p.get(ctx, "/search/album", url.Values{"q": {query}, "limit": {strconv.Itoa(p.limit)}}, &res); err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	albums := make([]model.SubsonicAlbum, 0)
+	for _, a := range res.Data {
+		albums = append(albums, p.DeezerAlbumToSubsonicAlbum(a))
+	}
+	return albums, nil
+}
+
+func (p *DeezerProvider) SearchArtists(ctx context.Context, query string) ([]model.SubsonicArtist, error) {
+	var res deezerList[deezerArtist]
+	if err := p.get(ctx, "/search/artist", url.Values{"q": {query}, "limit": {strconv.Itoa(p.limit)}}, &res); err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	artists := make([]model.SubsonicArtist, 0)
+	for _, a := range res.Data {
+		artists = append(artists, p.DeezerArtistToSubsonicArtist(a))
+	}
+	return artists, nil
+}
+
+func (p *DeezerProvider) GetAlbumSongs(ctx context.Context, albumID string) ([]model.SubsonicSong, error) {
+	albumID, err := deezerID(albumID, deezerAlbumPrefix)
+	if err != nil {
+		return nil, err
+	}
+	album, err := p.getAlbum(ctx, albumID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tracks embedded in /album/{id} have no positions, the dedicated endpoint does.
+	var res deezerList[deezerTrack]
+	if err := p.get(ctx, "/album/"+url.PathEscape(albumID)+"/tracks", url.Values{"limit": {"500"}}, &res); err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	tracks := res.Data
+	sort.SliceStable(tracks, func(i, j int) bool {
+		if tracks[i].DiskNumber != tracks[j].DiskNumber {
+			return tracks[i].DiskNumber < tracks[j].DiskNumber
+		}
+		return tracks[i].TrackPosition < tracks[j].TrackPosition
+	})
+
+	songs := make([]model.SubsonicSong, 0, len(tracks))
+	for _, t := range tracks {
+		songs = append(songs, p.DeezerTrackToSubsonicSong(t, *album))
+	}
+	return songs, nil
+}
+
+func (p *DeezerProvider) GetSong(ctx context.Context, id string) (*model.SubsonicSong, error) {
+	id, err := deezerID(id, deezerTrackPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var res deezerTrack
+	if err := p.get(ctx, "/track/"+url.PathEscape(id), nil, &res); err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	song := p.DeezerTrackToSubsonicSong(res, res.Album)
+	return &song, nil
+}
+
+func (p *DeezerProvider) GetAlbum(ctx context.Context, id string) (*model.SubsonicAlbum, error) {
+	id, err := deezerID(id, deezerAlbumPrefix)
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.getAlbum(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	album := p.DeezerAlbumToSubsonicAlbum(*res)
+	return &album, nil
+}
+
+func (p *DeezerProvider) GetArtist(ctx context.Context, id string) (*model.SubsonicArtist, error) {
+	id, err := deezerID(id, deezerArtistPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var res deezerArtist
+	if err := p.get(ctx, "/artist/"+url.PathEscape(id), nil, &res); err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	var albums deezerList[deezerAlbum]
+	if err := p.get(ctx, "/artist/"+url.PathEscape(id)+"/albums", url.Values{"limit": {"100"}}, &albums); err != nil {
+		return nil, err
+	}
+	if albums.Error != nil {
+		return nil, albums.Error
+	}
+
+	artist := p.DeezerArtistToSubsonicArtist(res)
+	for _, a := range albums.Data {
+		// Albums listed under an artist don't repeat the artist.
+		a.Artist = res
+		artist.Album = append(artist.Album, p.DeezerAlbumToSubsonicAlbum(a))
+	}
+	artist.AlbumCount = int64(len(artist.Album))
+	return &artist, nil
+}
+
+func (p *DeezerProvider) GetTopSongs(ctx context.Context, artist string, count int) ([]model.SubsonicSong, error) {
+	artists, err := p.SearchArtists(ctx, artist)
+	if err != nil {
+		return nil, err
+	}
+	artistID := ""
+	for _, a := range artists {
+		if strings.EqualFold(a.Name, artist) {
+			artistID = strings.TrimPrefix(a.ID, "external-"+deezerArtistPrefix)
+			break
+		}
+	}
+	if artistID == "" {
+		return nil, fmt.Errorf("artist not found")
+	}
+
+	var res deezerList[deezerTrack]
+	if err := p.get(ctx, "/artist/"+url.PathEscape(artistID)+"/top", url.Values{"limit": {strconv.Itoa(count)}}, &res); err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	songs := make([]model.SubsonicSong, 0)
+	for _, t := range res.Data {
+		songs = append(songs, p.DeezerTrackToSubsonicSong(t, t.Album))
+	}
+	return songs, nil
+}
+
+func (p *DeezerProvider) GetCoverArt(ctx context.Context, id string, size int64) ([]byte, string, error) {
+	id = strings.TrimPrefix(id, "external-")
+	var coverURL string
+	switch {
+	case strings.HasPrefix(id, deezerAlbumPrefix):
+		album, err := p.getAlbum(ctx, strings.TrimPrefix(id, deezerAlbumPrefix))
+		if err != nil {
+			return nil, "", err
+		}
+		coverURL = deezerImageForSize(size, album.CoverSmall, album.CoverMedium, album.CoverBig, album.CoverXL)
+	case strings.HasPrefix(id, deezerArtistPrefix):
+		var artist deezerArtist
+		if err := p.get(ctx, "/artist/"+url.PathEscape(strings.TrimPrefix(id, deezerArtistPrefix)), nil, &artist); err != nil {
+			return nil, "", err
+		}
+		if artist.Error != nil {
+			return nil, "", artist.Error
+		}
+		coverURL = deezerImageForSize(size, artist.PictureSmall, artist.PictureMedium, artist.PictureBig, artist.PictureXL)
+	case strings.HasPrefix(id, deezerTrackPrefix):
+		var track deezerTrack
+		if err := p.get(ctx, "/track/"+url.PathEscape(strings.TrimPrefix(id, deezerTrackPrefix)), nil, &track); err != nil {
+			return nil, "", err
+		}
+		if track.Error != nil {
+			return nil, "", track.Error
+		}
+		coverURL = deezerImageForSize(size, track.Album.CoverSmall, track.Album.CoverMedium, track.Album.CoverBig, track.Album.CoverXL)
+	default:
+		return nil, "", fmt.Errorf("not a Deezer ID: %s", id)
+	}
+
+	if coverURL == "" {
+		return nil, "", fmt.Errorf("no cover art found for ID: %s", id)
+	}
+
+	body, _, contentType, err := util.HTTPGet(ctx, coverURL, nil)
+	return body, contentType, err
+}
+
+// deezerID returns the numeric Deezer ID of an ID minted with prefix, refusing IDs of other entities.
+func deezerID(id, prefix string) (string, error) {
+	id = strings.TrimPrefix(id, "external-")
+	if !strings.HasPrefix(id, prefix) {
+		return "", fmt.Errorf("not a Deezer %s ID: %s", strings.Trim(strings.TrimPrefix(prefix, DeezerIDPrefix), "-"), id)
+	}
+	return strings.TrimPrefix(id, prefix), nil
+}
+
+func (p *DeezerProvider) getAlbum(ctx context.Context, id string) (*deezerAlbum, error) {
+	var res deezerAlbum
+	if err := p.get(ctx, "/album/"+url.PathEscape(id), nil, &res); err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &res, nil
+}
+
+func (p *DeezerProvider) get(ctx context.Context, path string, query url.Values, out any) error {
+	u := p.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	body, status, _, err := util.HTTPGet(ctx, u, nil)
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return fmt.Errorf("deezer request %s failed with status %d", path, status)
+	}
+	return json.Unmarshal(body, out)
+}
+
+func (e *deezerError) Error() string {
+	return fmt.Sprintf("deezer error %d (%s): %s", e.Code, e.Type, e.Message)
+}
+
+// deezerImageForSize picks the smallest Deezer image (56, 250, 500 and 1000px) covering the requested size.
+func deezerImageForSize(size int64, small, medium, big, xl string) string {
+	switch {
+	case size <= 56 && size >= 0 && small != "":
+		return small
+	case size <= 250 && size >= 0 && medium != "":
+		return medium
+	case size <= 500 && size >= 0 && big != "":
+		return big
+	case xl != "":
+		return xl
+	default:
+		return big
+	}
+}
+
+func deezerYear(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+func (p *DeezerProvider) DeezerTrackToSubsonicSong(rec deezerTrack, album deezerAlbum) model.SubsonicSong {
+	title := rec.Title
+	if title == "" {
+		title = rec.TitleShort
+	}
+	artist := rec.Artist.Name
+	if artist == "" {
+		artist = album.Artist.Name
+	}
+	year := deezerYear(album.ReleaseDate)
+	if year == 0 {
+		year = deezerYear(rec.ReleaseDate)
+	}
+	var isrc []string
+	if rec.ISRC != "" {
+		isrc = []string{rec.ISRC}
+	}
+	explicitStatus := "clean"
+	if rec.ExplicitLyrics {
+		explicitStatus = "explicit"
+	}
+
+	return model.SubsonicSong{
+		ID:                    fmt.Sprintf("external-%s%d", deezerTrackPrefix, rec.ID),
+		Parent:                fmt.Sprintf("external-%s%d", deezerAlbumPrefix, album.ID),
+		Title:                 title + " (external)",
+		Artist:                artist,
+		ArtistID:              fmt.Sprintf("external-%s%d", deezerArtistPrefix, rec.Artist.ID),
+		Album:                 album.Title,
+		AlbumID:               fmt.Sprintf("external-%s%d", deezerAlbumPrefix, album.ID),
+		CoverArt:              fmt.Sprintf("external-%s%d", deezerAlbumPrefix, album.ID),
+		Track:                 rec.TrackPosition,
+		DiscNumber:            rec.DiskNumber,
+		Year:                  year,
+		Duration:              rec.Duration,
+		Size:                  (rec.Duration * 160000) / 8,
+		IsDir:                 false,
+		IsVideo:               false,
+		Suffix:                "mp3",
+		ContentType:           "audio/mpeg",
+		TranscodedSuffix:      "mp3",
+		TranscodedContentType: "audio/mpeg",
+		Type:                  "music",
+		MediaType:             "song",
+		Created:               time.Now(),
+		Bpm:                   int(rec.Bpm),
+		Comment:               "deezer",
+		SortName:              title,
+		DisplayArtist:         artist,
+		DisplayAlbumArtist:    album.Artist.Name,
+		ExplicitStatus:        explicitStatus,
+		Artists:               []model.ArtistID3{{ID: fmt.Sprintf("external-%s%d", deezerArtistPrefix, rec.Artist.ID), Name: artist}},
+		ISRC:                  isrc,
+	}
+}
+
+func (p *DeezerProvider) DeezerAlbumToSubsonicAlbum(rec deezerAlbum) model.SubsonicAlbum {
+	genre := ""
+	if len(rec.Genres.Data) > 0 {
+		genre = rec.Genres.Data[0].Name
+	}
+
+	return model.SubsonicAlbum{
+		ID:           fmt.Sprintf("external-%s%d", deezerAlbumPrefix, rec.ID),
+		Parent:       fmt.Sprintf("external-%s%d", deezerArtistPrefix, rec.Artist.ID),
+		Album:        rec.Title,
+		Title:        rec.Title,
+		Name:         rec.Title,
+		IsDir:        true,
+		CoverArt:     fmt.Sprintf("external-%s%d", deezerAlbumPrefix, rec.ID),
+		SongCount:    rec.NbTracks,
+		Created:      time.Now(),
+		Duration:     rec.Duration,
+		ArtistID:     fmt.Sprintf("external-%s%d", deezerArtistPrefix, rec.Artist.ID),
+		Artist:       rec.Artist.Name,
+		Year:         deezerYear(rec.ReleaseDate),
+		Genre:        genre,
+		ReleaseTypes: deezerReleaseTypes(rec.RecordType),
+	}
+}
+
+func deezerReleaseTypes(recordType string) []string {
+	switch recordType {
+	case "album":
+		return []string{ReleaseTypeAlbum}
+	case "ep":
+		return []string{ReleaseTypeEP}
+	case "single":
+		return []string{ReleaseTypeSingle}
+	case "compile":
+		return []string{ReleaseTypeAlbum, "Compilation"}
+	default:
+		return nil
+	}
+}
+
+func (p *DeezerProvider) DeezerArtistToSubsonicArtist(rec deezerArtist) model.SubsonicArtist {
+	return model.SubsonicArtist{
+		ID:             fmt.Sprintf("external-%s%d", deezerArtistPrefix, rec.ID),
+		Name:           rec.Name,
+		CoverArt:       fmt.Sprintf("external-%s%d", deezerArtistPrefix, rec.ID),
+		ArtistImageURL: rec.PictureXL,
+		AlbumCount:     rec.NbAlbum,
+	}
+}
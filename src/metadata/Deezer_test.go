@@ -0,0 +1,162 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// deezerFixtures serves the recorded Deezer responses in testdata/deezer by request path. Paths without a
+// fixture answer like Deezer does for unknown IDs, with status 200 and an error object.
+func deezerFixtures(t *testing.T) *DeezerProvider {
+	t.Helper()
+	routes := map[string]string{
+		"/search/track":        "search_track.json",
+		"/track/3135556":       "track.json",
+		"/album/302127":        "album.json",
+		"/album/302127/tracks": "album_tracks.json",
+		"/artist/27":           "artist.json",
+		"/artist/27/albums":    "artist_albums.json",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := routes[r.URL.Path]
+		if !ok {
+			name = "error.json"
+		}
+		body, err := os.ReadFile(filepath.Join("testdata", "deezer", name))
+		if err != nil {
+			t.Errorf("reading fixture: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return NewDeezerProvider(srv.URL, 10)
+}
+
+func TestDeezerSearchSongs(t *testing.T) {
+	p := deezerFixtures(t)
+	songs, err := p.SearchSongs(context.Background(), "harder better")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 1 {
+		t.Fatalf("got %d songs, want 1", len(songs))
+	}
+	song := songs[0]
+	if song.ID != "external-deezer-track-3135556" || song.Title != "Harder, Better, Faster, Stronger (external)" {
+		t.Errorf("got %s %q", song.ID, song.Title)
+	}
+	if song.Artist != "Daft Punk" || song.Album != "Discovery" || song.AlbumID != "external-deezer-album-302127" {
+		t.Errorf("got artist %q, album %q (%s)", song.Artist, song.Album, song.AlbumID)
+	}
+	if song.CoverArt != "external-deezer-album-302127" {
+		t.Errorf("got cover art %q", song.CoverArt)
+	}
+}
+
+func TestDeezerGetSong(t *testing.T) {
+	p := deezerFixtures(t)
+	song, err := p.GetSong(context.Background(), "external-deezer-track-3135556")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(song.ISRC, []string{"GBDUW0000059"}) {
+		t.Errorf("got ISRC %v", song.ISRC)
+	}
+	if song.Year != 2001 || song.Track != 4 || song.DiscNumber != 1 || song.Duration != 224 || song.Bpm != 123 {
+		t.Errorf("got year %d, track %d/%d, duration %d, bpm %d", song.Year, song.DiscNumber, song.Track, song.Duration, song.Bpm)
+	}
+}
+
+func TestDeezerGetAlbumSongs(t *testing.T) {
+	p := deezerFixtures(t)
+	songs, err := p.GetAlbumSongs(context.Background(), "external-deezer-album-302127")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, song := range songs {
+		titles = append(titles, song.Title)
+		if song.Year != 2001 || song.Album != "Discovery" {
+			t.Errorf("%s: got album %q, year %d", song.Title, song.Album, song.Year)
+		}
+	}
+	want := []string{"One More Time (external)", "Aerodynamic (external)", "Harder, Better, Faster, Stronger (external)"}
+	if !slices.Equal(titles, want) {
+		t.Errorf("got %q, want %q", titles, want)
+	}
+	if songs[0].ISRC[0] != "GBDUW0000053" {
+		t.Errorf("got ISRC %v", songs[0].ISRC)
+	}
+}
+
+func TestDeezerGetArtist(t *testing.T) {
+	p := deezerFixtures(t)
+	artist, err := p.GetArtist(context.Background(), "external-deezer-artist-27")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artist.Name != "Daft Punk" || artist.AlbumCount != 2 || len(artist.Album) != 2 {
+		t.Fatalf("got %q with %d albums", artist.Name, len(artist.Album))
+	}
+	ram := artist.Album[1]
+	if ram.Name != "Random Access Memories" || ram.Year != 2013 || ram.Artist != "Daft Punk" || ram.ArtistID != "external-deezer-artist-27" {
+		t.Errorf("got %+v", ram)
+	}
+	if !slices.Equal(ram.ReleaseTypes, []string{ReleaseTypeAlbum}) {
+		t.Errorf("got release types %v", ram.ReleaseTypes)
+	}
+}
+
+func TestDeezerErrors(t *testing.T) {
+	p := deezerFixtures(t)
+	ctx := context.Background()
+	if _, err := p.GetSong(ctx, "external-deezer-track-1"); err == nil {
+		t.Error("GetSong of an unknown track succeeded")
+	}
+	if _, err := p.GetArtist(ctx, "external-deezer-artist-1"); err == nil {
+		t.Error("GetArtist of an unknown artist succeeded")
+	}
+	for _, id := range []string{"external-deezer-track-1", "external-deezer-album-1", "external-deezer-artist-1", "external-1"} {
+		if _, _, err := p.GetCoverArt(ctx, id, 300); err == nil {
+			t.Errorf("GetCoverArt(%s) succeeded", id)
+		}
+	}
+}
+
+func TestDeezerID(t *testing.T) {
+	tests := []struct {
+		id      string
+		prefix  string
+		want    string
+		wantErr bool
+	}{
+		{"external-deezer-track-3135556", deezerTrackPrefix, "3135556", false},
+		{"deezer-album-302127", deezerAlbumPrefix, "302127", false},
+		{"external-deezer-artist-27", deezerAlbumPrefix, "", true},
+		{"external-deezer-album-302127", deezerArtistPrefix, "", true},
+		{"external-302127", deezerAlbumPrefix, "", true},
+	}
+	for _, tt := range tests {
+		got, err := deezerID(tt.id, tt.prefix)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("deezerID(%q, %q) = %q, %v", tt.id, tt.prefix, got, err)
+		}
+	}
+}
+
+// An artist ID must not resolve to the album that happens to share its number, GetMusicDirectory tries
+// albums first.
+func TestDeezerIDsDontCollide(t *testing.T) {
+	p := deezerFixtures(t)
+	if _, err := p.GetAlbum(context.Background(), "external-deezer-artist-302127"); err == nil {
+		t.Error("GetAlbum of an artist ID succeeded")
+	}
+}
@@ -0,0 +1,646 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
+)
+
+const DiscogsAPIBase = "https://api.discogs.com"
+
+// Discogs IDs are namespaced so they can be routed back to this provider when
+// it is only used for enrichment, and so releases and masters don't collide.
+const (
+	DiscogsIDPrefix      = "discogs-"
+	discogsReleasePrefix = DiscogsIDPrefix + "release-"
+	discogsMasterPrefix  = DiscogsIDPrefix + "master-"
+	discogsArtistPrefix  = DiscogsIDPrefix + "artist-"
+)
+
+// discogsReleaseWorkers bounds how many releases a song search fetches at once, Discogs allows 60
+// authenticated requests a minute.
+const discogsReleaseWorkers = 3
+
+// discogsDiscPosition matches multi-disc positions such as "2-5", "CD2-5" or "2.5".
+var discogsDiscPosition = regexp.MustCompile(`^(?:[A-Za-z]+)?(\d+)[-.](\d+)$`)
+
+// discogsNameSuffix matches the numeric disambiguation Discogs appends to artist names, e.g. "Burial (2)".
+var discogsNameSuffix = regexp.MustCompile(`\s\(\d+\)$`)
+
+type DiscogsProvider struct {
+	baseURL string
+	token   string
+	limit   int
+}
+
+type discogsArtist struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Anv  string `json:"anv"`
+	Join string `json:"join"`
+}
+
+type discogsImage struct {
+	Type   string `json:"type"`
+	URI    string `json:"uri"`
+	URI150 string `json:"uri150"`
+}
+
+type discogsTrack struct {
+	Position  string          `json:"position"`
+	Type      string          `json:"type_"`
+	Title     string          `json:"title"`
+	Duration  string          `json:"duration"`
+	Artists   []discogsArtist `json:"artists"`
+	SubTracks []discogsTrack  `json:"sub_tracks"`
+}
+
+type discogsLabel struct {
+	Name  string `json:"name"`
+	CatNo string `json:"catno"`
+}
+
+// discogsRelease covers both /releases/{id} and /masters/{id}, masters simply leave the label fields empty.
+type discogsRelease struct {
+	ID          int64           `json:"id"`
+	Title       string          `json:"title"`
+	Year        int             `json:"year"`
+	Country     string          `json:"country"`
+	MasterID    int64           `json:"master_id"`
+	MainRelease int64           `json:"main_release"`
+	Artists     []discogsArtist `json:"artists"`
+	Labels      []discogsLabel  `json:"labels"`
+	Genres      []string        `json:"genres"`
+	Styles      []string        `json:"styles"`
+	Images      []discogsImage  `json:"images"`
+	Tracklist   []discogsTrack  `json:"tracklist"`
+	Message     string          `json:"message"`
+}
+
+type discogsArtistInfo struct {
+	ID      int64          `json:"id"`
+	Name    string         `json:"name"`
+	Profile string         `json:"profile"`
+	Images  []discogsImage `json:"images"`
+}
+
+type discogsArtistReleases struct {
+	Releases []struct {
+		ID     int64  `json:"id"`
+		Type   string `json:"type"`
+		Title  string `json:"title"`
+		Year   int    `json:"year"`
+		Format string `json:"format"`
+		Role   string `json:"role"`
+		Label  string `json:"label"`
+	} `json:"releases"`
+}
+
+type discogsSearchResponse struct {
+	Results []struct {
+		ID         int64    `json:"id"`
+		Type       string   `json:"type"`
+		Title      string   `json:"title"`
+		Year       string   `json:"year"`
+		Thumb      string   `json:"thumb"`
+		CoverImage string   `json:"cover_image"`
+		Label      []string `json:"label"`
+		CatNo      string   `json:"catno"`
+		Genre      []string `json:"genre"`
+	} `json:"results"`
+	Message string `json:"message"`
+}
+
+func NewDiscogsProvider(baseURL string, token string, limit int) *DiscogsProvider {
+	return &DiscogsProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		limit:   limit,
+	}
+}
+
+// SearchSongs has no native Discogs equivalent, so it searches releases and keeps the tracks whose title
+// appears in the query.
+func (p *DiscogsProvider) SearchSongs(ctx context.Context, query string) ([]model.SubsonicSong, error) {
+	slog.DebugContext(ctx, "Searching Discogs", "query", query)
+	res, err := p.search(ctx, query, "release")
+	if err != nil {
+		return nil, err
+	}
+
+	cleanQuery := strings.ToLower(query)
+	matches := make([][]model.SubsonicSong, len(res.Results))
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, discogsReleaseWorkers)
+	for i, r := range res.Results {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+		wg.Add(1)
+		go func(idx int, id int64) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			release, err := p.getRelease(ctx, discogsReleasePrefix+strconv.FormatInt(id, 10))
+			if err != nil {
+				slog.WarnContext(ctx, "Error fetching Discogs release", "release", id, "err", err)
+				return
+			}
+			for _, song := range p.releaseSongs(release, discogsReleasePrefix) {
+				title := strings.ToLower(strings.TrimSuffix(song.Title, " (external)"))
+				if title != "" && strings.Contains(cleanQuery, title) {
+					matches[idx] = append(matches[idx], song)
+				}
+			}
+		}(i, r.ID)
+	}
+	wg.Wait()
+
+	songs := make([]model.SubsonicSong, 0)
+	for _, m := range matches {
+		songs = append(songs, m...)
+		if len(songs) >= p.limit {
+			return songs[:p.limit], nil
+		}
+	}
+	return songs, nil
+}
+
+func (p *DiscogsProvider) SearchAlbums(ctx context.Context, query string) ([]model.SubsonicAlbum, error) {
+	res, err := p.search(ctx, query, "master")
+	if err != nil {
+		return nil, err
+	}
+	prefix := discogsMasterPrefix
+	if len(res.Results) == 0 {
+		// Plenty of vinyl-era releases were never grouped under a master.
+		res, err = p.search(ctx, query, "release")
+		if err != nil {
+			return nil, err
+		}
+		prefix = discogsReleasePrefix
+	}
+
+	albums := make([]model.SubsonicAlbum, 0)
+	for _, r := range res.Results {
+		artist, title, found := strings.Cut(r.Title, " - ")
+		if !found {
+			artist, title = "", r.Title
+		}
+		year, _ := strconv.Atoi(r.Year)
+		genre := ""
+		if len(r.Genre) > 0 {
+			genre = r.Genre[0]
+		}
+		id := prefix + strconv.FormatInt(r.ID, 10)
+		album := model.SubsonicAlbum{
+			ID:       "external-" + id,
+			Album:    title,
+			Title:    title,
+			Name:     title,
+			IsDir:    true,
+			CoverArt: "external-" + id,
+			Created:  time.Now(),
+			Artist:   discogsNameSuffix.ReplaceAllString(artist, ""),
+			Year:     year,
+			Genre:    genre,
+		}
+		for _, label := range r.Label {
+			album.RecordLabels = append(album.RecordLabels, model.RecordLabel{Name: label})
+		}
+		albums = append(albums, album)
+	}
+	return albums, nil
+}
+
+func (p *DiscogsProvider) SearchArtists(ctx context.Context, query string) ([]model.SubsonicArtist, error) {
+	res, err := p.search(ctx, query, "artist")
+	if err != nil {
+		return nil, err
+	}
+
+	artists := make([]model.SubsonicArtist, 0)
+	for _, r := range res.Results {
+		id := discogsArtistPrefix + strconv.FormatInt(r.ID, 10)
+		artists = append(artists, model.SubsonicArtist{
+			ID:             "external-" + id,
+			Name:           discogsNameSuffix.ReplaceAllString(r.Title, ""),
+			CoverArt:       "external-" + id,
+			ArtistImageURL: r.CoverImage,
+		})
+	}
+	return artists, nil
+}
+
+func (p *DiscogsProvider) GetArtist(ctx context.Context, id string) (*model.SubsonicArtist, error) {
+	id = strings.TrimPrefix(id, "external-")
+	info, err := p.getArtist(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var res discogsArtistReleases
+	params := url.Values{"sort": {"year"}, "per_page": {"100"}}
+	if err := p.get(ctx, "/artists/"+url.PathEscape(strings.TrimPrefix(id, discogsArtistPrefix))+"/releases?"+params.Encode(), &res); err != nil {
+		return nil, err
+	}
+
+	name := discogsNameSuffix.ReplaceAllString(info.Name, "")
+	artist := model.SubsonicArtist{
+		ID:       "external-" + id,
+		Name:     name,
+		CoverArt: "external-" + id,
+	}
+	if image := primaryDiscogsImage(info.Images); image != nil {
+		artist.ArtistImageURL = image.URI
+	}
+	for _, r := range res.Releases {
+		// Appearances, remixes and productions are listed too.
+		if r.Role != "Main" {
+			continue
+		}
+		prefix := discogsReleasePrefix
+		if r.Type == "master" {
+			prefix = discogsMasterPrefix
+		}
+		albumID := "external-" + prefix + strconv.FormatInt(r.ID, 10)
+		album := model.SubsonicAlbum{
+			ID:           albumID,
+			Parent:       artist.ID,
+			Album:        r.Title,
+			Title:        r.Title,
+			Name:         r.Title,
+			IsDir:        true,
+			CoverArt:     albumID,
+			Created:      time.Now(),
+			ArtistID:     artist.ID,
+			Artist:       name,
+			Year:         r.Year,
+			ReleaseTypes: []string{discogsReleaseType(r.Format)},
+		}
+		if r.Label != "" {
+			album.RecordLabels = []model.RecordLabel{{Name: r.Label}}
+		}
+		artist.Album = append(artist.Album, album)
+	}
+	artist.AlbumCount = int64(len(artist.Album))
+	return &artist, nil
+}
+
+func (p *DiscogsProvider) GetAlbumSongs(ctx context.Context, albumID string) ([]model.SubsonicSong, error) {
+	albumID = strings.TrimPrefix(albumID, "external-")
+	release, err := p.getRelease(ctx, albumID)
+	if err != nil {
+		return nil, err
+	}
+	return p.releaseSongs(release, releasePrefix(albumID)), nil
+}
+
+func (p *DiscogsProvider) GetSong(ctx context.Context, id string) (*model.SubsonicSong, error) {
+	id = strings.TrimPrefix(id, "external-")
+	// Song IDs are "<release id>-<tracklist index>" since Discogs tracks have no ID of their own.
+	idx := strings.LastIndex(id, "-")
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid Discogs track ID: %s", id)
+	}
+	albumID := id[:idx]
+	release, err := p.getRelease(ctx, albumID)
+	if err != nil {
+		return nil, err
+	}
+	for _, song := range p.releaseSongs(release, releasePrefix(albumID)) {
+		if song.ID == "external-"+id {
+			return &song, nil
+		}
+	}
+	return nil, fmt.Errorf("song not found")
+}
+
+func (p *DiscogsProvider) GetAlbum(ctx context.Context, id string) (*model.SubsonicAlbum, error) {
+	id = strings.TrimPrefix(id, "external-")
+	release, err := p.getRelease(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	album := p.DiscogsReleaseToSubsonicAlbum(release, releasePrefix(id))
+	return &album, nil
+}
+
+func (p *DiscogsProvider) GetCoverArt(ctx context.Context, id string, size int64) ([]byte, string, error) {
+	id = strings.TrimPrefix(id, "external-")
+	if idx := strings.LastIndex(id, "-"); idx > 0 && strings.Count(id, "-") > 2 {
+		// Track IDs share the cover of their release.
+		id = id[:idx]
+	}
+	var images []discogsImage
+	if strings.HasPrefix(id, discogsArtistPrefix) {
+		artist, err := p.getArtist(ctx, id)
+		if err != nil {
+			return nil, "", err
+		}
+		images = artist.Images
+	} else {
+		release, err := p.getRelease(ctx, id)
+		if err != nil {
+			return nil, "", err
+		}
+		images = release.Images
+	}
+
+	image := primaryDiscogsImage(images)
+	if image == nil {
+		return nil, "", fmt.Errorf("no cover art found for ID: %s", id)
+	}
+	imageURL := image.URI
+	if size > 0 && size <= 150 && image.URI150 != "" {
+		imageURL = image.URI150
+	}
+
+	body, _, contentType, err := util.HTTPGet(ctx, imageURL, p.headers())
+	return body, contentType, err
+}
+
+func (p *DiscogsProvider) search(ctx context.Context, query string, kind string) (*discogsSearchResponse, error) {
+	params := url.Values{
+		"q":        {query},
+		"type":     {kind},
+		"per_page": {strconv.Itoa(p.limit)},
+	}
+	var res discogsSearchResponse
+	if err := p.get(ctx, "/database/search?"+params.Encode(), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p *DiscogsProvider) getRelease(ctx context.Context, id string) (*discogsRelease, error) {
+	var path string
+	switch {
+	case strings.HasPrefix(id, discogsReleasePrefix):
+		path = "/releases/" + url.PathEscape(strings.TrimPrefix(id, discogsReleasePrefix))
+	case strings.HasPrefix(id, discogsMasterPrefix):
+		path = "/masters/" + url.PathEscape(strings.TrimPrefix(id, discogsMasterPrefix))
+	default:
+		return nil, fmt.Errorf("not a Discogs ID: %s", id)
+	}
+
+	var res discogsRelease
+	if err := p.get(ctx, path, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p *DiscogsProvider) getArtist(ctx context.Context, id string) (*discogsArtistInfo, error) {
+	if !strings.HasPrefix(id, discogsArtistPrefix) {
+		return nil, fmt.Errorf("not a Discogs artist ID: %s", id)
+	}
+	var res discogsArtistInfo
+	if err := p.get(ctx, "/artists/"+url.PathEscape(strings.TrimPrefix(id, discogsArtistPrefix)), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p *DiscogsProvider) get(ctx context.Context, pathAndQuery string, out any) error {
+	body, status, _, err := util.HTTPGet(ctx, p.baseURL+pathAndQuery, p.headers())
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &e)
+		return fmt.Errorf("discogs request failed with status %d: %s", status, e.Message)
+	}
+	return json.Unmarshal(body, out)
+}
+
+func (p *DiscogsProvider) headers() map[string]string {
+	headers := map[string]string{
+		"User-Agent": "navifetch/0.10.1 +https://github.com/GerardPolloRebozado/navifetch",
+	}
+	if p.token != "" {
+		headers["Authorization"] = "Discogs token=" + p.token
+	}
+	return headers
+}
+
+// discogsReleaseType reads the release type out of a Discogs format description such as `12", EP`.
+func discogsReleaseType(format string) string {
+	for _, f := range strings.Split(format, ",") {
+		switch strings.TrimSpace(f) {
+		case "Single", "Maxi-Single":
+			return ReleaseTypeSingle
+		case "EP", "Mini-Album":
+			return ReleaseTypeEP
+		}
+	}
+	return ReleaseTypeAlbum
+}
+
+func releasePrefix(id string) string {
+	if strings.HasPrefix(id, discogsMasterPrefix) {
+		return discogsMasterPrefix
+	}
+	return discogsReleasePrefix
+}
+
+func primaryDiscogsImage(images []discogsImage) *discogsImage {
+	for i := range images {
+		if images[i].Type == "primary" {
+			return &images[i]
+		}
+	}
+	if len(images) > 0 {
+		return &images[0]
+	}
+	return nil
+}
+
+// discogsArtistCredit joins the credited names the way Discogs displays them.
+func discogsArtistCredit(artists []discogsArtist) string {
+	var b strings.Builder
+	for i, a := range artists {
+		name := a.Anv
+		if name == "" {
+			name = a.Name
+		}
+		b.WriteString(discogsNameSuffix.ReplaceAllString(name, ""))
+		if i < len(artists)-1 {
+			join := strings.TrimSpace(a.Join)
+			if join == "" || join == "," {
+				b.WriteString(join + " ")
+			} else {
+				b.WriteString(" " + join + " ")
+			}
+		}
+	}
+	return b.String()
+}
+
+// parseDiscogsDuration parses Discogs "m:ss" or "h:mm:ss" durations into seconds.
+func parseDiscogsDuration(d string) int64 {
+	var seconds int64
+	for _, part := range strings.Split(d, ":") {
+		n, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
+
+// parseDiscogsDisc returns the disc number encoded in a tracklist position, vinyl sides count as one disc.
+func parseDiscogsDisc(position string) int {
+	if m := discogsDiscPosition.FindStringSubmatch(position); m != nil {
+		disc, _ := strconv.Atoi(m[1])
+		return disc
+	}
+	return 1
+}
+
+// parseDiscogsTrack returns the track number within its disc encoded in a tracklist position, or 0 for
+// positions that don't carry one, such as the vinyl side positions "A1" and "B2".
+func parseDiscogsTrack(position string) int {
+	if m := discogsDiscPosition.FindStringSubmatch(position); m != nil {
+		track, _ := strconv.Atoi(m[2])
+		return track
+	}
+	track, err := strconv.Atoi(strings.TrimSpace(position))
+	if err != nil || track < 0 {
+		return 0
+	}
+	return track
+}
+
+// flattenDiscogsTracklist drops headings and expands index tracks into their sub tracks.
+func flattenDiscogsTracklist(tracks []discogsTrack) []discogsTrack {
+	flat := make([]discogsTrack, 0, len(tracks))
+	for _, t := range tracks {
+		switch t.Type {
+		case "heading":
+			continue
+		case "index":
+			flat = append(flat, flattenDiscogsTracklist(t.SubTracks)...)
+		default:
+			flat = append(flat, t)
+		}
+	}
+	return flat
+}
+
+func (p *DiscogsProvider) releaseSongs(release *discogsRelease, prefix string) []model.SubsonicSong {
+	album := p.DiscogsReleaseToSubsonicAlbum(release, prefix)
+	tracks := flattenDiscogsTracklist(release.Tracklist)
+	songs := make([]model.SubsonicSong, 0, len(tracks))
+	perDisc := make(map[int]int)
+	for i, t := range tracks {
+		song := p.DiscogsTrackToSubsonicSong(t, i+1, release, album)
+		perDisc[song.DiscNumber]++
+		if song.Track == 0 {
+			// Vinyl sides number their tracks separately, count them across the disc instead.
+			song.Track = perDisc[song.DiscNumber]
+		}
+		songs = append(songs, song)
+	}
+	return songs
+}
+
+func (p *DiscogsProvider) DiscogsTrackToSubsonicSong(rec discogsTrack, index int, release *discogsRelease, album model.SubsonicAlbum) model.SubsonicSong {
+	artist := album.Artist
+	if len(rec.Artists) > 0 {
+		artist = discogsArtistCredit(rec.Artists)
+	}
+	duration := parseDiscogsDuration(rec.Duration)
+	comment := "discogs"
+	if len(release.Labels) > 0 {
+		comment = fmt.Sprintf("discogs: %s %s", release.Labels[0].Name, release.Labels[0].CatNo)
+	}
+	trackID := fmt.Sprintf("%s-%d", strings.TrimPrefix(album.ID, "external-"), index)
+
+	return model.SubsonicSong{
+		ID:                    "external-" + trackID,
+		Parent:                album.ID,
+		Title:                 rec.Title + " (external)",
+		Artist:                artist,
+		ArtistID:              album.ArtistID,
+		Album:                 album.Name,
+		AlbumID:               album.ID,
+		Genre:                 album.Genre,
+		CoverArt:              album.CoverArt,
+		Track:                 parseDiscogsTrack(rec.Position),
+		DiscNumber:            parseDiscogsDisc(rec.Position),
+		Year:                  album.Year,
+		Duration:              duration,
+		Size:                  (duration * 160000) / 8,
+		IsDir:                 false,
+		IsVideo:               false,
+		Suffix:                "mp3",
+		ContentType:           "audio/mpeg",
+		TranscodedSuffix:      "mp3",
+		TranscodedContentType: "audio/mpeg",
+		Type:                  "music",
+		MediaType:             "song",
+		Created:               time.Now(),
+		Comment:               strings.TrimSpace(comment),
+		SortName:              rec.Title,
+		DisplayArtist:         artist,
+		DisplayAlbumArtist:    album.Artist,
+	}
+}
+
+func (p *DiscogsProvider) DiscogsReleaseToSubsonicAlbum(rec *discogsRelease, prefix string) model.SubsonicAlbum {
+	id := "external-" + prefix + strconv.FormatInt(rec.ID, 10)
+	genre := ""
+	if len(rec.Styles) > 0 {
+		genre = rec.Styles[0]
+	} else if len(rec.Genres) > 0 {
+		genre = rec.Genres[0]
+	}
+	artistID := ""
+	if len(rec.Artists) > 0 {
+		artistID = fmt.Sprintf("external-%s%d", discogsArtistPrefix, rec.Artists[0].ID)
+	}
+	tracks := flattenDiscogsTracklist(rec.Tracklist)
+	var duration int64
+	for _, t := range tracks {
+		duration += parseDiscogsDuration(t.Duration)
+	}
+
+	album := model.SubsonicAlbum{
+		ID:        id,
+		Parent:    artistID,
+		Album:     rec.Title,
+		Title:     rec.Title,
+		Name:      rec.Title,
+		IsDir:     true,
+		CoverArt:  id,
+		SongCount: int64(len(tracks)),
+		Created:   time.Now(),
+		Duration:  int(duration),
+		ArtistID:  artistID,
+		Artist:    discogsArtistCredit(rec.Artists),
+		Year:      rec.Year,
+		Genre:     genre,
+	}
+	for _, label := range rec.Labels {
+		album.RecordLabels = append(album.RecordLabels, model.RecordLabel{Name: label.Name})
+	}
+	return album
+}
@@ -0,0 +1,57 @@
+package metadata
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiscogsTrackNumbers(t *testing.T) {
+	tests := []struct {
+		name      string
+		positions []string
+		want      [][2]int
+	}{
+		{
+			name:      "single disc",
+			positions: []string{"1", "2", "3"},
+			want:      [][2]int{{1, 1}, {1, 2}, {1, 3}},
+		},
+		{
+			name:      "two discs",
+			positions: []string{"1-1", "1-2", "2-1", "2-2"},
+			want:      [][2]int{{1, 1}, {1, 2}, {2, 1}, {2, 2}},
+		},
+		{
+			name:      "prefixed discs",
+			positions: []string{"CD1-1", "CD2-1", "CD2.2"},
+			want:      [][2]int{{1, 1}, {2, 1}, {2, 2}},
+		},
+		{
+			name:      "vinyl sides",
+			positions: []string{"A1", "A2", "B1", "B2"},
+			want:      [][2]int{{1, 1}, {1, 2}, {1, 3}, {1, 4}},
+		},
+		{
+			name:      "missing positions",
+			positions: []string{"", ""},
+			want:      [][2]int{{1, 1}, {1, 2}},
+		},
+	}
+
+	p := NewDiscogsProvider("http://localhost", "token", 10)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := &discogsRelease{ID: 1, Title: "Album"}
+			for _, position := range tt.positions {
+				release.Tracklist = append(release.Tracklist, discogsTrack{Position: position, Title: "Track " + position})
+			}
+			var got [][2]int
+			for _, song := range p.releaseSongs(release, discogsReleasePrefix) {
+				got = append(got, [2]int{song.DiscNumber, song.Track})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got disc/track %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,37 +2,211 @@ package metadata
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/GerardPolloRebozado/navifetch/src/config"
 	"github.com/GerardPolloRebozado/navifetch/src/model"
 )
 
 type Provider interface {
 	SearchSongs(ctx context.Context, query string) ([]model.SubsonicSong, error)
 	SearchAlbums(ctx context.Context, query string) ([]model.SubsonicAlbum, error)
+	SearchArtists(ctx context.Context, query string) ([]model.SubsonicArtist, error)
 	GetAlbumSongs(ctx context.Context, albumID string) ([]model.SubsonicSong, error)
 	GetSong(ctx context.Context, id string) (*model.SubsonicSong, error)
 	GetAlbum(ctx context.Context, id string) (*model.SubsonicAlbum, error)
+	// GetArtist returns the artist with its discography in Album.
+	GetArtist(ctx context.Context, id string) (*model.SubsonicArtist, error)
 	GetCoverArt(ctx context.Context, id string, size int64) ([]byte, string, error)
 }
 
-var metadataProvider Provider
+// TrackCountMatcher is implemented by providers that know several editions of an album and can pick
+// the one matching the number of tracks of the local copy.
+type TrackCountMatcher interface {
+	GetAlbumSongsWithTrackCount(ctx context.Context, albumID string, trackCount int) ([]model.SubsonicSong, error)
+}
+
+// ReleaseProvider is implemented by providers that tell the editions of an album apart and can list the
+// tracks of one by its release MBID, the ID Navidrome stores for local albums.
+type ReleaseProvider interface {
+	GetReleaseSongs(ctx context.Context, releaseID string) ([]model.SubsonicSong, error)
+}
+
+// TopSongsProvider is implemented by providers that know the most popular songs of an artist.
+type TopSongsProvider interface {
+	GetTopSongs(ctx context.Context, artist string, count int) ([]model.SubsonicSong, error)
+}
+
+// SimilarSongsProvider is implemented by providers that can recommend songs similar to a given one.
+type SimilarSongsProvider interface {
+	GetSimilarSongs(ctx context.Context, artist string, title string, count int) ([]model.SubsonicSong, error)
+}
+
+// MBIDProvider is implemented by providers whose album and artist IDs are MusicBrainz IDs, so the MBIDs
+// Navidrome stores for local albums and artists can be looked up directly.
+type MBIDProvider interface {
+	UsesMBIDs() bool
+}
+
+// UsesMBIDs reports whether p looks albums and artists up by their MusicBrainz IDs.
+func UsesMBIDs(p Provider) bool {
+	m, ok := p.(MBIDProvider)
+	return ok && m.UsesMBIDs()
+}
+
+// ErrUnsupported is returned when the configured provider lacks an optional capability.
+var ErrUnsupported = errors.New("not supported by the metadata provider")
+
+// Release types reported in SubsonicAlbum.ReleaseTypes, in the order artist discographies list them.
+const (
+	ReleaseTypeAlbum  = "Album"
+	ReleaseTypeEP     = "EP"
+	ReleaseTypeSingle = "Single"
+)
+
+// NewProviders builds the configured metadata provider and the one used to fill in missing tracks of local
+// albums, which is the metadata provider unless ENRICHMENT_PROVIDER says otherwise. When the enrichment
+// provider differs, the metadata provider also routes lookups for IDs minted by it, so its songs can be
+// streamed like any other.
+func NewProviders(cfg *config.Config) (Provider, Provider, error) {
+	primary, err := newNamedProvider(cfg.Providers.Metadata, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Providers.Enrichment == "" || cfg.Providers.Enrichment == cfg.Providers.Metadata {
+		return primary, primary, nil
+	}
 
-func NewProvider(name string, country string, limit int, apiKey string) (Provider, error) {
-	if metadataProvider != nil {
-		return metadataProvider, nil
+	prefix := idPrefix(cfg.Providers.Enrichment)
+	if prefix == "" {
+		return nil, nil, fmt.Errorf("enrichment provider %s can't be combined with %s, its IDs are not namespaced", cfg.Providers.Enrichment, cfg.Providers.Metadata)
+	}
+	enrichment, err := newNamedProvider(cfg.Providers.Enrichment, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	routed := &routedProvider{
+		Provider: primary,
+		routes:   map[string]Provider{prefix: enrichment},
 	}
+	return routed, enrichment, nil
+}
+
+// NewProvider returns the configured metadata provider, see NewProviders.
+func NewProvider(cfg *config.Config) (Provider, error) {
+	p, _, err := NewProviders(cfg)
+	return p, err
+}
+
+// newNamedProvider builds the provider called name, instrumented for the metrics endpoint.
+func newNamedProvider(name string, cfg *config.Config) (Provider, error) {
+	p, err := newBareProvider(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return instrument(name, p), nil
+}
+
+func newBareProvider(name string, cfg *config.Config) (Provider, error) {
 	switch name {
 	case "itunes":
-		metadataProvider = NewItunesProvider(country, limit)
-		return metadataProvider, nil
+		return NewItunesProvider(cfg.Providers.Country, cfg.Providers.ResultsPerPage), nil
 	case "musicbrainz":
-		metadataProvider = NewMusicBrainzProvider(limit)
-		return metadataProvider, nil
+		return NewMusicBrainzProvider(cfg.Providers.Country, cfg.Providers.ResultsPerPage), nil
 	case "lastfm":
-		metadataProvider = NewLastFMProvider(apiKey, limit)
-		return metadataProvider, nil
+		return NewLastFMProvider(cfg.Providers.LastFMApiKey, cfg.Providers.ResultsPerPage), nil
+	case "deezer":
+		return NewDeezerProvider(DeezerAPIBase, cfg.Providers.ResultsPerPage), nil
+	case "discogs":
+		if cfg.Providers.DiscogsToken == "" {
+			return nil, fmt.Errorf("discogs provider requires DISCOGS_TOKEN")
+		}
+		return NewDiscogsProvider(DiscogsAPIBase, cfg.Providers.DiscogsToken, cfg.Providers.ResultsPerPage), nil
 	default:
 		return nil, fmt.Errorf("unsupported metadata provider: %s", name)
 	}
 }
+
+// idPrefix returns the prefix that identifies IDs minted by a provider, if it namespaces them.
+func idPrefix(name string) string {
+	switch name {
+	case "discogs":
+		return DiscogsIDPrefix
+	case "deezer":
+		return DeezerIDPrefix
+	default:
+		return ""
+	}
+}
+
+// routedProvider searches with the embedded provider and sends ID lookups to the provider that minted the ID.
+type routedProvider struct {
+	Provider
+	routes map[string]Provider
+}
+
+func (r *routedProvider) route(id string) Provider {
+	trimmed := strings.TrimPrefix(id, "external-")
+	for prefix, p := range r.routes {
+		if strings.HasPrefix(trimmed, prefix) {
+			return p
+		}
+	}
+	return r.Provider
+}
+
+func (r *routedProvider) GetAlbumSongs(ctx context.Context, albumID string) ([]model.SubsonicSong, error) {
+	return r.route(albumID).GetAlbumSongs(ctx, albumID)
+}
+
+func (r *routedProvider) GetAlbumSongsWithTrackCount(ctx context.Context, albumID string, trackCount int) ([]model.SubsonicSong, error) {
+	p := r.route(albumID)
+	if matcher, ok := p.(TrackCountMatcher); ok {
+		return matcher.GetAlbumSongsWithTrackCount(ctx, albumID, trackCount)
+	}
+	return p.GetAlbumSongs(ctx, albumID)
+}
+
+func (r *routedProvider) GetSong(ctx context.Context, id string) (*model.SubsonicSong, error) {
+	return r.route(id).GetSong(ctx, id)
+}
+
+func (r *routedProvider) GetAlbum(ctx context.Context, id string) (*model.SubsonicAlbum, error) {
+	return r.route(id).GetAlbum(ctx, id)
+}
+
+func (r *routedProvider) GetArtist(ctx context.Context, id string) (*model.SubsonicArtist, error) {
+	return r.route(id).GetArtist(ctx, id)
+}
+
+func (r *routedProvider) GetReleaseSongs(ctx context.Context, releaseID string) ([]model.SubsonicSong, error) {
+	if p, ok := r.Provider.(ReleaseProvider); ok {
+		return p.GetReleaseSongs(ctx, releaseID)
+	}
+	return nil, ErrUnsupported
+}
+
+// UsesMBIDs reports on the embedded provider, IDs without a routed prefix go to it.
+func (r *routedProvider) UsesMBIDs() bool {
+	return UsesMBIDs(r.Provider)
+}
+
+func (r *routedProvider) GetTopSongs(ctx context.Context, artist string, count int) ([]model.SubsonicSong, error) {
+	if p, ok := r.Provider.(TopSongsProvider); ok {
+		return p.GetTopSongs(ctx, artist, count)
+	}
+	return nil, ErrUnsupported
+}
+
+func (r *routedProvider) GetSimilarSongs(ctx context.Context, artist string, title string, count int) ([]model.SubsonicSong, error) {
+	if p, ok := r.Provider.(SimilarSongsProvider); ok {
+		return p.GetSimilarSongs(ctx, artist, title, count)
+	}
+	return nil, ErrUnsupported
+}
+
+func (r *routedProvider) GetCoverArt(ctx context.Context, id string, size int64) ([]byte, string, error) {
+	return r.route(id).GetCoverArt(ctx, id, size)
+}
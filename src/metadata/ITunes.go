@@ -3,9 +3,10 @@ package metadata
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/GerardPolloRebozado/navifetch/src/model"
@@ -28,7 +29,7 @@ func NewItunesProvider(country string, limit int) *ItunesProvider {
 }
 
 func (p *ItunesProvider) SearchSongs(ctx context.Context, query string) ([]model.SubsonicSong, error) {
-	log.Printf("Searching iTunes for %s", query)
+	slog.DebugContext(ctx, "Searching iTunes", "query", query)
 
 	res, err := p.client.Search(ctx, itunes.Term(query), itunes.Limit(p.limit), itunes.Media("music"),
 		itunes.Entity("song"), itunes.Country(p.country))
@@ -57,6 +58,37 @@ func (p *ItunesProvider) SearchAlbums(ctx context.Context, query string) ([]mode
 	return albums, nil
 }
 
+func (p *ItunesProvider) SearchArtists(ctx context.Context, query string) ([]model.SubsonicArtist, error) {
+	res, err := p.client.Search(ctx, itunes.Term(query), itunes.Limit(p.limit), itunes.Media("music"),
+		itunes.Entity("musicArtist"), itunes.Country(p.country))
+	if err != nil {
+		return nil, err
+	}
+	artists := make([]model.SubsonicArtist, 0)
+	for _, artist := range res.Results {
+		artists = append(artists, p.ItunesArtistToSubsonicArtist(artist))
+	}
+
+	return artists, nil
+}
+
+// GetTopSongs relies on iTunes ranking artist searches by popularity.
+func (p *ItunesProvider) GetTopSongs(ctx context.Context, artist string, count int) ([]model.SubsonicSong, error) {
+	res, err := p.client.Search(ctx, itunes.Term(artist), itunes.Limit(count), itunes.Media("music"),
+		itunes.Entity("song"), itunes.Attribute("artistTerm"), itunes.Country(p.country))
+	if err != nil {
+		return nil, err
+	}
+	songs := make([]model.SubsonicSong, 0)
+	for _, song := range res.Results {
+		if strings.EqualFold(song.ArtistName, artist) {
+			songs = append(songs, p.ItunesSongToSubsonicSong(song))
+		}
+	}
+
+	return songs, nil
+}
+
 func (p *ItunesProvider) GetAlbumSongs(ctx context.Context, albumID string) ([]model.SubsonicSong, error) {
 	parsedId, err := strconv.ParseInt(albumID, 10, 32)
 	if err != nil {
@@ -68,6 +100,10 @@ func (p *ItunesProvider) GetAlbumSongs(ctx context.Context, albumID string) ([]m
 	}
 	songs := make([]model.SubsonicSong, 0)
 	for _, song := range res.Results {
+		// The album itself comes back as the first result of the lookup.
+		if song.WrapperType != "track" {
+			continue
+		}
 		songs = append(songs, p.ItunesSongToSubsonicSong(song))
 	}
 	return songs, nil
@@ -122,6 +158,30 @@ func (p *ItunesProvider) GetAlbum(ctx context.Context, id string) (*model.Subson
 	return &album, nil
 }
 
+func (p *ItunesProvider) GetArtist(ctx context.Context, id string) (*model.SubsonicArtist, error) {
+	parsedId, err := strconv.ParseInt(strings.TrimPrefix(id, "external-"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.client.Lookup(ctx, itunes.ID(parsedId), itunes.Entity("album"), itunes.Limit(200), itunes.Country(p.country))
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Results) == 0 {
+		return nil, fmt.Errorf("artist not found")
+	}
+	// The artist itself comes back as the first result of the lookup, followed by its albums.
+	artist := p.ItunesArtistToSubsonicArtist(res.Results[0])
+	for _, rec := range res.Results[1:] {
+		if rec.WrapperType != "collection" {
+			continue
+		}
+		artist.Album = append(artist.Album, p.ItunesAlbumToSubsonicAlbum(rec))
+	}
+	artist.AlbumCount = int64(len(artist.Album))
+	return &artist, nil
+}
+
 func (p *ItunesProvider) ItunesSongToSubsonicSong(rec itunes.Result) model.SubsonicSong {
 	return model.SubsonicSong{
 		Parent:                rec.CollectionName,
@@ -133,6 +193,9 @@ func (p *ItunesProvider) ItunesSongToSubsonicSong(rec itunes.Result) model.Subso
 		AlbumID:               fmt.Sprintf("external-%d", rec.CollectionId),
 		Genre:                 rec.PrimaryGenreName,
 		CoverArt:              "external-" + url.QueryEscape(rec.ArtworkUrl100),
+		Track:                 int(rec.TrackNumber),
+		DiscNumber:            int(rec.DiscNumber),
+		Year:                  itunesYear(rec.ReleaseDate),
 		Duration:              rec.TrackTimeMillis / 1000,
 		Size:                  ((rec.TrackTimeMillis / 1000) * 160000) / 8,
 		IsDir:                 false,
@@ -160,17 +223,45 @@ func (p *ItunesProvider) ItunesSongToSubsonicSong(rec itunes.Result) model.Subso
 
 func (p *ItunesProvider) ItunesAlbumToSubsonicAlbum(rec itunes.Result) model.SubsonicAlbum {
 	return model.SubsonicAlbum{
-		ID:        fmt.Sprintf("external-%d", rec.CollectionId),
-		Parent:    fmt.Sprintf("external-%d", rec.ArtistId),
-		Album:     rec.CollectionName,
-		Title:     rec.CollectionName,
-		Name:      rec.CollectionName,
-		IsDir:     true,
-		CoverArt:  "external-" + url.QueryEscape(rec.ArtworkUrl100),
-		SongCount: int64(rec.TrackCount),
-		Created:   time.Now(),
-		ArtistID:  fmt.Sprintf("external-%d", rec.ArtistId),
-		Artist:    rec.ArtistName,
-		Genre:     rec.PrimaryGenreName,
+		ID:           fmt.Sprintf("external-%d", rec.CollectionId),
+		Parent:       fmt.Sprintf("external-%d", rec.ArtistId),
+		Album:        rec.CollectionName,
+		Title:        rec.CollectionName,
+		Name:         rec.CollectionName,
+		IsDir:        true,
+		CoverArt:     "external-" + url.QueryEscape(rec.ArtworkUrl100),
+		SongCount:    int64(rec.TrackCount),
+		Created:      time.Now(),
+		ArtistID:     fmt.Sprintf("external-%d", rec.ArtistId),
+		Artist:       rec.ArtistName,
+		Year:         itunesYear(rec.ReleaseDate),
+		Genre:        rec.PrimaryGenreName,
+		ReleaseTypes: []string{itunesReleaseType(rec.CollectionName)},
+	}
+}
+
+func (p *ItunesProvider) ItunesArtistToSubsonicArtist(rec itunes.Result) model.SubsonicArtist {
+	return model.SubsonicArtist{
+		ID:   fmt.Sprintf("external-%d", rec.ArtistId),
+		Name: rec.ArtistName,
+	}
+}
+
+// itunesReleaseType derives the release type from the suffix iTunes appends to single and EP names.
+func itunesReleaseType(collectionName string) string {
+	switch {
+	case strings.HasSuffix(collectionName, " - Single"):
+		return ReleaseTypeSingle
+	case strings.HasSuffix(collectionName, " - EP"):
+		return ReleaseTypeEP
+	default:
+		return ReleaseTypeAlbum
+	}
+}
+
+func itunesYear(date time.Time) int {
+	if date.IsZero() {
+		return 0
 	}
+	return date.Year()
 }
@@ -37,7 +37,7 @@ func (p *LastFMProvider) SearchSongs(_ context.Context, query string) ([]model.S
 
 	songs := make([]model.SubsonicSong, 0)
 	for _, t := range res.Tracks {
-		songs = append(songs, p.toSubsonicSong(t.Title, t.Artist, t.MBID, "", "", 0))
+		songs = append(songs, p.toSubsonicSong(t.Title, t.Artist, t.MBID, "", "", 0, 0))
 	}
 	return songs, nil
 }
@@ -72,6 +72,25 @@ func (p *LastFMProvider) SearchAlbums(_ context.Context, query string) ([]model.
 	return albums, nil
 }
 
+func (p *LastFMProvider) SearchArtists(_ context.Context, query string) ([]model.SubsonicArtist, error) {
+	res, err := p.client.Artist.Search(lastfm.ArtistSearchParams{
+		Artist: query,
+		Limit:  uint(p.limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	artists := make([]model.SubsonicArtist, 0)
+	for _, a := range res.Artists {
+		// Artists without an MBID can't be looked up again through GetArtist.
+		if a.MBID != "" {
+			artists = append(artists, p.toSubsonicArtist(a.Name, a.MBID, a.Image.URL()))
+		}
+	}
+	return artists, nil
+}
+
 func (p *LastFMProvider) GetAlbumSongs(_ context.Context, albumID string) ([]model.SubsonicSong, error) {
 	albumID = strings.TrimPrefix(albumID, "external-")
 	albumRes, err := p.client.Album.InfoByMBID(lastfm.AlbumInfoMBIDParams{MBID: albumID})
@@ -83,18 +102,18 @@ func (p *LastFMProvider) GetAlbumSongs(_ context.Context, albumID string) ([]mod
 	var wg sync.WaitGroup
 	for i, t := range albumRes.Tracks {
 		wg.Add(1)
-		go func(idx int, trackTitle, trackArtist string) {
+		go func(idx int, trackTitle, trackArtist string, trackNumber int) {
 			defer wg.Done()
 			trackRes, err := p.client.Track.Info(lastfm.TrackInfoParams{
 				Artist: trackArtist,
 				Track:  trackTitle,
 			})
 			if err == nil && trackRes != nil {
-				songs[idx] = p.toSubsonicSong(trackRes.Title, trackRes.Artist.Name, trackRes.MBID, trackRes.Album.Title, trackRes.Album.MBID, int64(trackRes.Duration.Unwrap().Seconds()))
+				songs[idx] = p.toSubsonicSong(trackRes.Title, trackRes.Artist.Name, trackRes.MBID, trackRes.Album.Title, trackRes.Album.MBID, int64(trackRes.Duration.Unwrap().Seconds()), trackNumber)
 			} else {
-				songs[idx] = p.toSubsonicSong(trackTitle, trackArtist, "", albumRes.Title, albumRes.MBID, 0)
+				songs[idx] = p.toSubsonicSong(trackTitle, trackArtist, "", albumRes.Title, albumRes.MBID, 0, trackNumber)
 			}
-		}(i, t.Title, t.Artist.Name)
+		}(i, t.Title, t.Artist.Name, t.Number)
 	}
 	wg.Wait()
 
@@ -108,7 +127,7 @@ func (p *LastFMProvider) GetSong(_ context.Context, id string) (*model.SubsonicS
 		return nil, err
 	}
 
-	song := p.toSubsonicSong(res.Title, res.Artist.Name, res.MBID, res.Album.Title, res.Album.MBID, int64(res.Duration.Unwrap().Seconds()))
+	song := p.toSubsonicSong(res.Title, res.Artist.Name, res.MBID, res.Album.Title, res.Album.MBID, int64(res.Duration.Unwrap().Seconds()), res.Album.Position)
 	return &song, nil
 }
 
@@ -123,6 +142,70 @@ func (p *LastFMProvider) GetAlbum(_ context.Context, id string) (*model.Subsonic
 	return &a, nil
 }
 
+// UsesMBIDs is true, Last.fm lookups go through the MusicBrainz IDs it reports.
+func (p *LastFMProvider) UsesMBIDs() bool {
+	return true
+}
+
+// GetArtist lists the artist's top albums, Last.fm has no notion of a discography or of release types.
+func (p *LastFMProvider) GetArtist(_ context.Context, id string) (*model.SubsonicArtist, error) {
+	id = strings.TrimPrefix(id, "external-")
+	info, err := p.client.Artist.InfoByMBID(lastfm.ArtistInfoMBIDParams{MBID: id})
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.client.Artist.TopAlbumsByMBID(lastfm.ArtistTopAlbumsMBIDParams{MBID: id, Limit: 50})
+	if err != nil {
+		return nil, err
+	}
+
+	artist := p.toSubsonicArtist(info.Name, info.MBID, info.Image.URL())
+	for _, a := range res.Albums {
+		if a.MBID == "" {
+			continue
+		}
+		album := p.toSubsonicAlbum(a.Title, info.Name, a.MBID, 0)
+		album.ArtistID = artist.ID
+		album.Parent = artist.ID
+		artist.Album = append(artist.Album, album)
+	}
+	artist.AlbumCount = int64(len(artist.Album))
+	return &artist, nil
+}
+
+func (p *LastFMProvider) GetTopSongs(_ context.Context, artist string, count int) ([]model.SubsonicSong, error) {
+	res, err := p.client.Artist.TopTracks(lastfm.ArtistTopTracksParams{
+		Artist: artist,
+		Limit:  uint(count),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	songs := make([]model.SubsonicSong, 0)
+	for _, t := range res.Tracks {
+		songs = append(songs, p.toSubsonicSong(t.Title, t.Artist.Name, t.MBID, "", "", 0, 0))
+	}
+	return songs, nil
+}
+
+func (p *LastFMProvider) GetSimilarSongs(_ context.Context, artist string, title string, count int) ([]model.SubsonicSong, error) {
+	res, err := p.client.Track.Similar(lastfm.TrackSimilarParams{
+		Artist: artist,
+		Track:  title,
+		Limit:  uint(count),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	songs := make([]model.SubsonicSong, 0)
+	for _, t := range res.Tracks {
+		songs = append(songs, p.toSubsonicSong(t.Title, t.Artist.Name, t.MBID, "", "", int64(t.Duration.Unwrap().Seconds()), 0))
+	}
+	return songs, nil
+}
+
 func (p *LastFMProvider) GetCoverArt(ctx context.Context, id string, _ int64) ([]byte, string, error) {
 	id = strings.TrimPrefix(id, "external-")
 	var imageURL string
@@ -144,7 +227,8 @@ func (p *LastFMProvider) GetCoverArt(ctx context.Context, id string, _ int64) ([
 	return body, contentType, err
 }
 
-func (p *LastFMProvider) toSubsonicSong(title, artist, mbid, album, albumMBID string, duration int64) model.SubsonicSong {
+// Last.fm has no release dates, so unlike the other providers the year is left empty.
+func (p *LastFMProvider) toSubsonicSong(title, artist, mbid, album, albumMBID string, duration int64, track int) model.SubsonicSong {
 	coverArtID := albumMBID
 	if coverArtID == "" {
 		coverArtID = mbid
@@ -158,6 +242,7 @@ func (p *LastFMProvider) toSubsonicSong(title, artist, mbid, album, albumMBID st
 		Album:                 album,
 		AlbumID:               "external-" + albumMBID,
 		CoverArt:              "external-" + coverArtID,
+		Track:                 track,
 		Duration:              duration,
 		IsDir:                 false,
 		ContentType:           "audio/mpeg",
@@ -184,3 +269,12 @@ func (p *LastFMProvider) toSubsonicAlbum(title, artist, mbid string, songCount i
 		Created:   time.Now(),
 	}
 }
+
+func (p *LastFMProvider) toSubsonicArtist(name, mbid, imageURL string) model.SubsonicArtist {
+	return model.SubsonicArtist{
+		ID:             "external-" + mbid,
+		Name:           name,
+		ArtistImageURL: imageURL,
+		MusicBrainzId:  mbid,
+	}
+}
@@ -2,6 +2,11 @@ package metadata
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/GerardPolloRebozado/navifetch/src/model"
@@ -13,20 +18,44 @@ import (
 type MusicBrainzProvider struct {
 	client    *musicbrainzws2.Client
 	paginator musicbrainzws2.Paginator
+	country   string
 }
 
 func (p *MusicBrainzProvider) GetAlbum(ctx context.Context, id string) (*model.SubsonicAlbum, error) {
+	id = strings.TrimPrefix(id, "external-")
 	res, err := p.client.LookupReleaseGroup(ctx, mbtypes.MBID(id), musicbrainzws2.IncludesFilter{
-		Includes: []string{"releases"},
+		Includes: []string{"releases", "media", "artist-credits", "genres", "tags"},
 	})
 	if err != nil {
 		return nil, err
 	}
 	album := MusicBrainzAlbumToSubsonicAlbum(res)
+
+	// Track count and duration come from the edition GetAlbumSongs will list.
+	if selected := p.selectRelease(res.Releases, 0); selected != nil {
+		release, err := p.client.LookupRelease(ctx, selected.ID, musicbrainzws2.IncludesFilter{
+			Includes: []string{"recordings"},
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "Error fetching MusicBrainz release", "release", selected.ID, "err", err)
+		} else {
+			var songCount int64
+			var duration time.Duration
+			for _, medium := range release.Media {
+				for _, track := range medium.Tracks {
+					songCount++
+					duration += track.Length.Duration
+				}
+			}
+			album.SongCount = songCount
+			album.Duration = int(duration.Seconds())
+			album.CoverArt = string("external-" + release.ID)
+		}
+	}
 	return &album, nil
 }
 
-func NewMusicBrainzProvider(limit int) *MusicBrainzProvider {
+func NewMusicBrainzProvider(country string, limit int) *MusicBrainzProvider {
 	return &MusicBrainzProvider{musicbrainzws2.NewClient(musicbrainzws2.AppInfo{
 		Name:    "navifetch",
 		Version: "0.10.1",
@@ -36,6 +65,7 @@ func NewMusicBrainzProvider(limit int) *MusicBrainzProvider {
 			Offset: 0,
 			Limit:  limit,
 		},
+		country,
 	}
 }
 
@@ -75,27 +105,249 @@ func (p *MusicBrainzProvider) SearchAlbums(ctx context.Context, query string) ([
 	return albums, nil
 }
 
+func (p *MusicBrainzProvider) SearchArtists(ctx context.Context, query string) ([]model.SubsonicArtist, error) {
+	res, err := p.client.SearchArtists(ctx, musicbrainzws2.SearchFilter{
+		Query:  query,
+		Dismax: true,
+	},
+		p.paginator,
+	)
+	if err != nil {
+		return nil, err
+	}
+	artists := make([]model.SubsonicArtist, 0)
+	for _, artist := range res.Artists {
+		artists = append(artists, MusicBrainzArtistToSubsonicArtist(artist))
+	}
+	return artists, nil
+}
+
+// UsesMBIDs is true, every ID the provider hands out is a MusicBrainz ID.
+func (p *MusicBrainzProvider) UsesMBIDs() bool {
+	return true
+}
+
+func (p *MusicBrainzProvider) GetArtist(ctx context.Context, id string) (*model.SubsonicArtist, error) {
+	id = strings.TrimPrefix(id, "external-")
+	res, err := p.client.LookupArtist(ctx, mbtypes.MBID(id), musicbrainzws2.IncludesFilter{
+		Includes: []string{"release-groups"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	artist := MusicBrainzArtistToSubsonicArtist(res)
+	for _, group := range res.ReleaseGroups {
+		album := MusicBrainzAlbumToSubsonicAlbum(group)
+		// Release groups nested in an artist lookup carry no artist credit of their own.
+		album.Artist = artist.Name
+		album.ArtistID = artist.ID
+		album.Parent = artist.ID
+		artist.Album = append(artist.Album, album)
+	}
+	artist.AlbumCount = int64(len(artist.Album))
+	return &artist, nil
+}
+
+// GetTopSongs ranks the artist's songs by their MusicBrainz rating, falling back to how many official
+// releases carry them when none of the songs has been rated.
+func (p *MusicBrainzProvider) GetTopSongs(ctx context.Context, artist string, count int) ([]model.SubsonicSong, error) {
+	res, err := p.client.SearchRecordings(ctx, musicbrainzws2.SearchFilter{
+		Query: fmt.Sprintf("artist:\"%s\" AND status:official", strings.ReplaceAll(artist, "\"", "")),
+	},
+		musicbrainzws2.Paginator{
+			Offset: 0,
+			Limit:  100,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	// The same song shows up once per recording of it, its releases add up.
+	candidates := make([]topRecording, 0)
+	index := make(map[string]int)
+	for _, recording := range res.Recordings {
+		title := strings.ToLower(recording.Title)
+		i, ok := index[title]
+		if !ok {
+			i = len(candidates)
+			index[title] = i
+			candidates = append(candidates, topRecording{recording: recording})
+		}
+		candidates[i].releases += max(1, len(recording.Releases))
+	}
+	rankTopRecordings(candidates)
+
+	// Search results carry no ratings, the most released songs are looked up for theirs.
+	for i := range candidates[:min(len(candidates), musicBrainzRatedCandidates)] {
+		rated, err := p.client.LookupRecording(ctx, candidates[i].recording.ID, musicbrainzws2.IncludesFilter{
+			Includes: []string{"ratings"},
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			slog.DebugContext(ctx, "Error fetching MusicBrainz rating", "recording", candidates[i].recording.ID, "err", err)
+			continue
+		}
+		candidates[i].recording.Rating = rated.Rating
+	}
+	rankTopRecordings(candidates)
+
+	recordings := candidates[:min(count, len(candidates))]
+	songs := make([]model.SubsonicSong, 0, len(recordings))
+	for _, top := range recordings {
+		songs = append(songs, MusicBrainzSongToSubsonicSong(top.recording))
+	}
+	return songs, nil
+}
+
+// musicBrainzRatedCandidates is how many songs GetTopSongs looks up for their rating, MusicBrainz allows
+// one request a second.
+const musicBrainzRatedCandidates = 20
+
+// topRecording is a GetTopSongs candidate with the number of releases of every recording of the song.
+type topRecording struct {
+	recording musicbrainzws2.Recording
+	releases  int
+}
+
+// rankTopRecordings sorts the best rated songs first, or the most released ones when none has been rated.
+// Unrated songs follow the rated ones.
+func rankTopRecordings(candidates []topRecording) {
+	rated := slices.ContainsFunc(candidates, func(c topRecording) bool { return c.recording.Rating.VotesCount > 0 })
+	rating := func(c topRecording) float64 {
+		if c.recording.Rating.VotesCount == 0 {
+			return -1
+		}
+		return float64(c.recording.Rating.Value)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if rated {
+			if ri, rj := rating(candidates[i]), rating(candidates[j]); ri != rj {
+				return ri > rj
+			}
+		}
+		return candidates[i].releases > candidates[j].releases
+	})
+}
+
 func (p *MusicBrainzProvider) GetAlbumSongs(ctx context.Context, albumID string) ([]model.SubsonicSong, error) {
-	res, err := p.client.LookupReleaseGroup(ctx, mbtypes.MBID(albumID), musicbrainzws2.IncludesFilter{
-		Includes: []string{"releases"},
+	return p.GetAlbumSongsWithTrackCount(ctx, albumID, 0)
+}
+
+// GetAlbumSongsWithTrackCount returns the tracklist of the release group albumID, from the edition selectRelease
+// picks for trackCount. GetReleaseSongs takes the release MBID of one exact edition instead.
+func (p *MusicBrainzProvider) GetAlbumSongsWithTrackCount(ctx context.Context, albumID string, trackCount int) ([]model.SubsonicSong, error) {
+	albumID = strings.TrimPrefix(albumID, "external-")
+	releaseID, err := p.resolveRelease(ctx, albumID, trackCount)
+	if err != nil {
+		return nil, err
+	}
+	return p.GetReleaseSongs(ctx, string(releaseID))
+}
+
+// GetReleaseSongs returns the tracklist of a single release, listed under its release group.
+func (p *MusicBrainzProvider) GetReleaseSongs(ctx context.Context, releaseID string) ([]model.SubsonicSong, error) {
+	releaseID = strings.TrimPrefix(releaseID, "external-")
+	release, err := p.client.LookupRelease(ctx, mbtypes.MBID(releaseID), musicbrainzws2.IncludesFilter{
+		Includes: []string{"recordings", "artist-credits", "release-groups", "genres", "tags",
+			"recording-level-rels", "work-rels", "work-level-rels", "artist-rels"},
 	})
 	if err != nil {
 		return nil, err
 	}
+
 	songs := make([]model.SubsonicSong, 0)
-	for _, release := range res.Releases {
-		for _, medium := range release.Media {
-			for _, track := range medium.Tracks {
-				songs = append(songs, MusicBrainzSongToSubsonicSong(track.Recording))
+	for _, medium := range release.Media {
+		for _, track := range medium.Tracks {
+			song := MusicBrainzSongToSubsonicSong(track.Recording)
+			song.Album = release.ReleaseGroup.Title + " (external)"
+			song.AlbumID = string("external-" + release.ReleaseGroup.ID)
+			song.Parent = song.AlbumID
+			song.CoverArt = string("external-" + release.ID)
+			if song.Genre == "" {
+				song.Genre = musicBrainzGenre(release.ReleaseGroup.Genres, release.ReleaseGroup.Tags)
+			}
+			if song.Year == 0 {
+				song.Year = musicBrainzYear(release.ReleaseGroup.FirstReleaseDate)
 			}
+			song.Track = track.Position
+			song.DiscNumber = medium.Position
+			songs = append(songs, song)
 		}
 	}
 	return songs, nil
 }
 
+// resolveRelease returns the release of the release group albumID that best matches trackCount.
+func (p *MusicBrainzProvider) resolveRelease(ctx context.Context, albumID string, trackCount int) (mbtypes.MBID, error) {
+	group, err := p.client.LookupReleaseGroup(ctx, mbtypes.MBID(albumID), musicbrainzws2.IncludesFilter{
+		Includes: []string{"releases", "media"},
+	})
+	if err != nil {
+		return "", err
+	}
+	release := p.selectRelease(group.Releases, trackCount)
+	if release == nil {
+		return "", fmt.Errorf("release group %s has no releases", albumID)
+	}
+	slog.DebugContext(ctx, "Selected release for release group", "release", release.ID, "country", release.Country, "date", musicBrainzDate(release.Date), "releaseGroup", albumID)
+	return release.ID, nil
+}
+
+// selectRelease picks the edition that best represents a release group: official releases first,
+// then ones matching the local track count, digital or CD media, the configured country and finally
+// the earliest release date.
+func (p *MusicBrainzProvider) selectRelease(releases []musicbrainzws2.Release, trackCount int) *musicbrainzws2.Release {
+	if len(releases) == 0 {
+		return nil
+	}
+	score := func(r musicbrainzws2.Release) int {
+		s := 0
+		if strings.EqualFold(string(r.Status), "official") {
+			s += 16
+		}
+		total := 0
+		for _, m := range r.Media {
+			total += m.TrackCount
+		}
+		if trackCount > 0 && total == trackCount {
+			s += 8
+		}
+		if len(r.Media) > 0 {
+			format := strings.ToLower(string(r.Media[0].Format))
+			if format == "digital media" || strings.Contains(format, "cd") {
+				s += 4
+			}
+		}
+		switch {
+		case p.country != "" && strings.EqualFold(r.Country, p.country):
+			s += 2
+		case r.Country == "XW":
+			s += 1
+		}
+		return s
+	}
+
+	sorted := slices.Clone(releases)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		si, sj := score(sorted[i]), score(sorted[j])
+		if si != sj {
+			return si > sj
+		}
+		di, dj := musicBrainzDate(sorted[i].Date), musicBrainzDate(sorted[j].Date)
+		if di == "" || dj == "" {
+			return dj == "" && di != ""
+		}
+		return di < dj
+	})
+	return &sorted[0]
+}
+
 func (p *MusicBrainzProvider) GetSong(ctx context.Context, id string) (*model.SubsonicSong, error) {
 	includes := musicbrainzws2.IncludesFilter{
-		Includes: []string{"releases", "artist-credits", "release-groups"},
+		Includes: []string{"releases", "artist-credits", "release-groups", "genres", "tags",
+			"work-rels", "work-level-rels", "artist-rels"},
 	}
 	res, err := p.client.LookupRecording(ctx, mbtypes.MBID(id), includes)
 	if err != nil {
@@ -130,44 +382,125 @@ func MusicBrainzSongToSubsonicSong(recording musicbrainzws2.Recording) model.Sub
 	}
 
 	return model.SubsonicSong{
-		ID:                 string("external-" + recording.ID),
-		Parent:             parent,
-		Title:              recording.Title,
-		Artist:             recording.ArtistCredit.String(),
-		DisplayArtist:      recording.ArtistCredit.String(),
-		DisplayAlbumArtist: recording.ArtistCredit.String(),
-		DisplayComposer:    "Display composer",
-		ArtistID:           string("external-" + recording.ArtistCreditID),
-		Album:              album,
-		AlbumID:            albumId,
-		Genre:              "",
-		CoverArt:           string("external-" + coverArt),
-		Duration:           int64(recording.Length.Duration.Seconds()),
-		Size:               ((recording.Length.Duration.Milliseconds() / 1000) * 160000) / 8,
-		IsDir:              false,
-		IsVideo:            false,
-		ContentType:        "song",
-		Suffix:             "",
+		ID:                    string("external-" + recording.ID),
+		Parent:                parent,
+		Title:                 recording.Title,
+		Artist:                recording.ArtistCredit.String(),
+		DisplayArtist:         recording.ArtistCredit.String(),
+		DisplayAlbumArtist:    recording.ArtistCredit.String(),
+		DisplayComposer:       musicBrainzComposers(recording.Relations),
+		ArtistID:              string("external-" + recording.ArtistCreditID),
+		Album:                 album,
+		AlbumID:               albumId,
+		Genre:                 musicBrainzGenre(recording.Genres, recording.Tags),
+		CoverArt:              string("external-" + coverArt),
+		Year:                  musicBrainzYear(recording.FirstReleaseDate),
+		Duration:              int64(recording.Length.Duration.Seconds()),
+		Size:                  ((recording.Length.Duration.Milliseconds() / 1000) * 160000) / 8,
+		IsDir:                 false,
+		IsVideo:               false,
+		Suffix:                "mp3",
+		ContentType:           "audio/mpeg",
+		TranscodedSuffix:      "mp3",
+		TranscodedContentType: "audio/mpeg",
+		Type:                  "music",
+		MediaType:             "song",
+		Created:               time.Now(),
+		MusicBrainzId:         string(recording.ID),
 	}
 }
 
 func MusicBrainzAlbumToSubsonicAlbum(album musicbrainzws2.ReleaseGroup) model.SubsonicAlbum {
 	return model.SubsonicAlbum{
-		ID:        string("external-" + album.ID),
-		Parent:    "",
-		Album:     album.Title,
-		Title:     album.Title,
-		Name:      album.Title,
-		IsDir:     true,
-		CoverArt:  string("external-" + album.ID),
-		SongCount: int64(len(album.Releases)),
-		Created:   time.Now(),
-		Duration:  1,
-		PlayCount: 1,
-		ArtistID:  string("external-" + album.ArtistCreditID),
-		Artist:    album.ArtistCredit.String(),
-		Year:      0,
-		Genre:     "hello",
-		Song:      []model.SubsonicSong{},
+		ID:            string("external-" + album.ID),
+		Parent:        "",
+		Album:         album.Title,
+		Title:         album.Title,
+		Name:          album.Title,
+		IsDir:         true,
+		CoverArt:      string("external-" + album.ID),
+		Created:       time.Now(),
+		ArtistID:      string("external-" + album.ArtistCreditID),
+		Artist:        album.ArtistCredit.String(),
+		Year:          musicBrainzYear(album.FirstReleaseDate),
+		Genre:         musicBrainzGenre(album.Genres, album.Tags),
+		MusicBrainzId: string(album.ID),
+		ReleaseTypes:  musicBrainzReleaseTypes(album),
+		Song:          []model.SubsonicSong{},
+	}
+}
+
+func MusicBrainzArtistToSubsonicArtist(artist musicbrainzws2.Artist) model.SubsonicArtist {
+	return model.SubsonicArtist{
+		ID:            string("external-" + artist.ID),
+		Name:          artist.Name,
+		MusicBrainzId: string(artist.ID),
+	}
+}
+
+func musicBrainzReleaseTypes(album musicbrainzws2.ReleaseGroup) []string {
+	types := make([]string, 0, len(album.SecondaryTypes)+1)
+	if album.PrimaryType != "" {
+		types = append(types, string(album.PrimaryType))
+	}
+	for _, t := range album.SecondaryTypes {
+		types = append(types, string(t))
+	}
+	return types
+}
+
+// musicBrainzGenre returns the most voted genre, falling back to the most voted tag when none is set.
+func musicBrainzGenre(genres []musicbrainzws2.Genre, tags []musicbrainzws2.Tag) string {
+	best, bestCount := "", -1
+	for _, g := range genres {
+		if g.Count > bestCount {
+			best, bestCount = g.Name, g.Count
+		}
+	}
+	if best != "" {
+		return best
+	}
+	for _, t := range tags {
+		if t.Count > bestCount {
+			best, bestCount = t.Name, t.Count
+		}
+	}
+	return best
+}
+
+// musicBrainzComposers collects the composers of the works a recording is a performance of.
+func musicBrainzComposers(relations []musicbrainzws2.Relation) string {
+	composers := make([]string, 0)
+	for _, rel := range relations {
+		if rel.Work == nil {
+			continue
+		}
+		for _, workRel := range rel.Work.Relations {
+			if workRel.Type == "composer" && workRel.Artist != nil && !slices.Contains(composers, workRel.Artist.Name) {
+				composers = append(composers, workRel.Artist.Name)
+			}
+		}
+	}
+	return strings.Join(composers, ", ")
+}
+
+// musicBrainzDate normalizes a MusicBrainz date, which may be partial (YYYY, YYYY-MM or YYYY-MM-DD),
+// and returns an empty string when it is unknown.
+func musicBrainzDate(date musicbrainzws2.Date) string {
+	if musicBrainzYear(date) == 0 {
+		return ""
+	}
+	s := fmt.Sprint(date)
+	if len(s) > 10 {
+		s = s[:10]
+	}
+	return s
+}
+
+// musicBrainzYear returns the year of a MusicBrainz date, 0 when it is unknown.
+func musicBrainzYear(date musicbrainzws2.Date) int {
+	if date.IsZero() || date.Year() <= 1 {
+		return 0
 	}
+	return date.Year()
 }
@@ -0,0 +1,309 @@
+package metadata
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"go.uploadedlobster.com/musicbrainzws2"
+)
+
+func mbDate(year int, month time.Month, day int) musicbrainzws2.Date {
+	return musicbrainzws2.Date{Time: time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestSelectRelease(t *testing.T) {
+	cd := func(tracks int) []musicbrainzws2.Medium {
+		return []musicbrainzws2.Medium{{Format: "CD", TrackCount: tracks}}
+	}
+	tests := []struct {
+		name       string
+		releases   []musicbrainzws2.Release
+		trackCount int
+		want       string
+	}{
+		{
+			name: "official over bootleg",
+			releases: []musicbrainzws2.Release{
+				{ID: "bootleg", Status: "Bootleg", Country: "GB", Media: cd(12), Date: mbDate(1990, 1, 1)},
+				{ID: "official", Status: "Official", Media: []musicbrainzws2.Medium{{Format: "Vinyl", TrackCount: 10}}},
+			},
+			trackCount: 12,
+			want:       "official",
+		},
+		{
+			name: "matching track count",
+			releases: []musicbrainzws2.Release{
+				{ID: "deluxe", Status: "Official", Media: cd(18), Date: mbDate(2001, 3, 7)},
+				{ID: "standard", Status: "Official", Media: cd(14), Date: mbDate(2005, 1, 1)},
+			},
+			trackCount: 14,
+			want:       "standard",
+		},
+		{
+			name: "track count ignored when unknown",
+			releases: []musicbrainzws2.Release{
+				{ID: "later", Status: "Official", Media: cd(18), Date: mbDate(2005, 1, 1)},
+				{ID: "earlier", Status: "Official", Media: cd(14), Date: mbDate(2001, 3, 7)},
+			},
+			want: "earlier",
+		},
+		{
+			name: "digital over vinyl",
+			releases: []musicbrainzws2.Release{
+				{ID: "vinyl", Status: "Official", Media: []musicbrainzws2.Medium{{Format: "12\" Vinyl"}}, Date: mbDate(2001, 1, 1)},
+				{ID: "digital", Status: "Official", Media: []musicbrainzws2.Medium{{Format: "Digital Media"}}, Date: mbDate(2010, 1, 1)},
+			},
+			want: "digital",
+		},
+		{
+			name: "configured country over worldwide",
+			releases: []musicbrainzws2.Release{
+				{ID: "us", Status: "Official", Country: "US", Media: cd(10), Date: mbDate(2001, 1, 1)},
+				{ID: "xw", Status: "Official", Country: "XW", Media: cd(10), Date: mbDate(2001, 1, 1)},
+				{ID: "gb", Status: "Official", Country: "GB", Media: cd(10), Date: mbDate(2002, 1, 1)},
+			},
+			want: "gb",
+		},
+		{
+			name: "worldwide over other countries",
+			releases: []musicbrainzws2.Release{
+				{ID: "us", Status: "Official", Country: "US", Media: cd(10), Date: mbDate(2001, 1, 1)},
+				{ID: "xw", Status: "Official", Country: "XW", Media: cd(10), Date: mbDate(2002, 1, 1)},
+			},
+			want: "xw",
+		},
+		{
+			name: "undated releases last",
+			releases: []musicbrainzws2.Release{
+				{ID: "undated", Status: "Official", Media: cd(10)},
+				{ID: "dated", Status: "Official", Media: cd(10), Date: mbDate(2020, 6, 1)},
+			},
+			want: "dated",
+		},
+	}
+
+	p := &MusicBrainzProvider{country: "GB"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.selectRelease(tt.releases, tt.trackCount)
+			if got == nil || string(got.ID) != tt.want {
+				t.Errorf("got %v, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if got := p.selectRelease(nil, 10); got != nil {
+		t.Errorf("got %v for no releases", got)
+	}
+}
+
+func TestMusicBrainzGenre(t *testing.T) {
+	tests := []struct {
+		name   string
+		genres []musicbrainzws2.Genre
+		tags   []musicbrainzws2.Tag
+		want   string
+	}{
+		{name: "nothing"},
+		{
+			name:   "most voted genre",
+			genres: []musicbrainzws2.Genre{{Name: "house", Count: 2}, {Name: "french house", Count: 5}},
+			tags:   []musicbrainzws2.Tag{{Name: "dance", Count: 9}},
+			want:   "french house",
+		},
+		{
+			name: "tags without genres",
+			tags: []musicbrainzws2.Tag{{Name: "electronic", Count: 1}, {Name: "dance", Count: 3}},
+			want: "dance",
+		},
+	}
+	for _, tt := range tests {
+		if got := musicBrainzGenre(tt.genres, tt.tags); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMusicBrainzComposers(t *testing.T) {
+	composer := func(name string) musicbrainzws2.Relation {
+		return musicbrainzws2.Relation{Type: "composer", Artist: &musicbrainzws2.Artist{Name: name}}
+	}
+	tests := []struct {
+		name      string
+		relations []musicbrainzws2.Relation
+		want      string
+	}{
+		{name: "no works"},
+		{
+			name: "composers of every work, once",
+			relations: []musicbrainzws2.Relation{
+				{Type: "performance", Work: &musicbrainzws2.Work{Relations: []musicbrainzws2.Relation{
+					composer("Thomas Bangalter"), composer("Guy-Manuel de Homem-Christo"),
+					{Type: "lyricist", Artist: &musicbrainzws2.Artist{Name: "Edwin Birdsong"}},
+				}}},
+				{Type: "performance", Work: &musicbrainzws2.Work{Relations: []musicbrainzws2.Relation{
+					composer("Thomas Bangalter"),
+				}}},
+				{Type: "producer", Artist: &musicbrainzws2.Artist{Name: "Daft Punk"}},
+			},
+			want: "Thomas Bangalter, Guy-Manuel de Homem-Christo",
+		},
+	}
+	for _, tt := range tests {
+		if got := musicBrainzComposers(tt.relations); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMusicBrainzDates(t *testing.T) {
+	tests := []struct {
+		name     string
+		date     musicbrainzws2.Date
+		wantYear int
+		wantDate string
+	}{
+		{name: "unknown"},
+		{name: "full date", date: mbDate(2001, 3, 12), wantYear: 2001, wantDate: "2001-03-12"},
+		{name: "year only", date: mbDate(1997, 1, 1), wantYear: 1997, wantDate: "1997-01-01"},
+	}
+	for _, tt := range tests {
+		if got := musicBrainzYear(tt.date); got != tt.wantYear {
+			t.Errorf("%s: got year %d, want %d", tt.name, got, tt.wantYear)
+		}
+		if got := musicBrainzDate(tt.date); got != tt.wantDate {
+			t.Errorf("%s: got date %q, want %q", tt.name, got, tt.wantDate)
+		}
+	}
+}
+
+func TestMusicBrainzSongToSubsonicSong(t *testing.T) {
+	tests := []struct {
+		name          string
+		recording     musicbrainzws2.Recording
+		wantAlbum     string
+		wantAlbumID   string
+		wantCoverArt  string
+		wantYear      int
+		wantDuration  int64
+		wantGenre     string
+		wantComposers string
+	}{
+		{
+			name: "standalone recording",
+			recording: musicbrainzws2.Recording{
+				ID:     "rec",
+				Title:  "Da Funk",
+				Length: musicbrainzws2.Duration{Duration: 328 * time.Second},
+			},
+			wantAlbum:    "Single",
+			wantCoverArt: "external-rec",
+			wantDuration: 328,
+		},
+		{
+			name: "recording on a release",
+			recording: musicbrainzws2.Recording{
+				ID:               "rec",
+				Title:            "Digital Love",
+				Length:           musicbrainzws2.Duration{Duration: 301500 * time.Millisecond},
+				FirstReleaseDate: mbDate(2001, 3, 12),
+				Genres:           []musicbrainzws2.Genre{{Name: "french house", Count: 3}},
+				Releases: []musicbrainzws2.Release{{
+					ID:           "release",
+					ReleaseGroup: musicbrainzws2.ReleaseGroup{ID: "group", Title: "Discovery"},
+				}},
+				Relations: []musicbrainzws2.Relation{{Work: &musicbrainzws2.Work{Relations: []musicbrainzws2.Relation{
+					{Type: "composer", Artist: &musicbrainzws2.Artist{Name: "Thomas Bangalter"}},
+				}}}},
+			},
+			wantAlbum:     "Discovery (external)",
+			wantAlbumID:   "external-group",
+			wantCoverArt:  "external-release",
+			wantYear:      2001,
+			wantDuration:  301,
+			wantGenre:     "french house",
+			wantComposers: "Thomas Bangalter",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			song := MusicBrainzSongToSubsonicSong(tt.recording)
+			if song.ID != "external-rec" || song.MusicBrainzId != "rec" || song.Title != tt.recording.Title {
+				t.Errorf("got ID %q, MBID %q, title %q", song.ID, song.MusicBrainzId, song.Title)
+			}
+			if song.Album != tt.wantAlbum || song.AlbumID != tt.wantAlbumID || song.CoverArt != tt.wantCoverArt {
+				t.Errorf("got album %q (%q), cover art %q", song.Album, song.AlbumID, song.CoverArt)
+			}
+			if song.Year != tt.wantYear || song.Duration != tt.wantDuration || song.Genre != tt.wantGenre {
+				t.Errorf("got year %d, duration %d, genre %q", song.Year, song.Duration, song.Genre)
+			}
+			if song.DisplayComposer != tt.wantComposers {
+				t.Errorf("got composers %q", song.DisplayComposer)
+			}
+		})
+	}
+}
+
+func TestMusicBrainzReleaseTypes(t *testing.T) {
+	tests := []struct {
+		group musicbrainzws2.ReleaseGroup
+		want  []string
+	}{
+		{musicbrainzws2.ReleaseGroup{}, []string{}},
+		{musicbrainzws2.ReleaseGroup{PrimaryType: "Album"}, []string{"Album"}},
+		{musicbrainzws2.ReleaseGroup{PrimaryType: "Album", SecondaryTypes: []string{"Compilation", "Live"}}, []string{"Album", "Compilation", "Live"}},
+	}
+	for _, tt := range tests {
+		if got := musicBrainzReleaseTypes(tt.group); !slices.Equal(got, tt.want) {
+			t.Errorf("got %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestRankTopRecordings(t *testing.T) {
+	top := func(title string, releases int, rating float64, votes int) topRecording {
+		return topRecording{
+			recording: musicbrainzws2.Recording{Title: title, Rating: musicbrainzws2.Rating{Value: rating, VotesCount: votes}},
+			releases:  releases,
+		}
+	}
+	tests := []struct {
+		name       string
+		candidates []topRecording
+		want       []string
+	}{
+		{
+			name:       "by rating",
+			candidates: []topRecording{top("Compiled", 40, 3, 2), top("Loved", 2, 4.5, 10), top("Liked", 5, 4, 1)},
+			want:       []string{"Loved", "Liked", "Compiled"},
+		},
+		{
+			name:       "unrated after rated",
+			candidates: []topRecording{top("Unrated", 40, 0, 0), top("Disliked", 1, 1, 3)},
+			want:       []string{"Disliked", "Unrated"},
+		},
+		{
+			name:       "equal ratings by releases",
+			candidates: []topRecording{top("Few", 2, 4, 1), top("Many", 9, 4, 7)},
+			want:       []string{"Many", "Few"},
+		},
+		{
+			name:       "by releases without ratings",
+			candidates: []topRecording{top("Rare", 1, 0, 0), top("Hit", 12, 0, 0), top("Known", 4, 0, 0)},
+			want:       []string{"Hit", "Known", "Rare"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rankTopRecordings(tt.candidates)
+			var got []string
+			for _, c := range tt.candidates {
+				got = append(got, c.recording.Title)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/health"
+	"github.com/GerardPolloRebozado/navifetch/src/metrics"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+// instrumented records the latency and errors of every call to a provider, labelled with its name and
+// the method. Optional capabilities are forwarded when the provider has them.
+type instrumented struct {
+	name string
+	p    Provider
+}
+
+func instrument(name string, p Provider) Provider {
+	return &instrumented{name: name, p: p}
+}
+
+// observe records a call that started at start. ErrUnsupported is not the provider failing.
+func (i *instrumented) observe(method string, start time.Time, err error) {
+	metrics.ProviderDuration.Since(start, i.name, method)
+	if errors.Is(err, ErrUnsupported) {
+		err = nil
+	}
+	if err != nil {
+		metrics.ProviderErrors.Inc(i.name, method)
+	}
+	health.Record(i.name, time.Since(start), err)
+}
+
+func (i *instrumented) SearchSongs(ctx context.Context, query string) ([]model.SubsonicSong, error) {
+	start := time.Now()
+	songs, err := i.p.SearchSongs(ctx, query)
+	i.observe("SearchSongs", start, err)
+	return songs, err
+}
+
+func (i *instrumented) SearchAlbums(ctx context.Context, query string) ([]model.SubsonicAlbum, error) {
+	start := time.Now()
+	albums, err := i.p.SearchAlbums(ctx, query)
+	i.observe("SearchAlbums", start, err)
+	return albums, err
+}
+
+func (i *instrumented) SearchArtists(ctx context.Context, query string) ([]model.SubsonicArtist, error) {
+	start := time.Now()
+	artists, err := i.p.SearchArtists(ctx, query)
+	i.observe("SearchArtists", start, err)
+	return artists, err
+}
+
+func (i *instrumented) GetAlbumSongs(ctx context.Context, albumID string) ([]model.SubsonicSong, error) {
+	start := time.Now()
+	songs, err := i.p.GetAlbumSongs(ctx, albumID)
+	i.observe("GetAlbumSongs", start, err)
+	return songs, err
+}
+
+func (i *instrumented) GetSong(ctx context.Context, id string) (*model.SubsonicSong, error) {
+	start := time.Now()
+	song, err := i.p.GetSong(ctx, id)
+	i.observe("GetSong", start, err)
+	return song, err
+}
+
+func (i *instrumented) GetAlbum(ctx context.Context, id string) (*model.SubsonicAlbum, error) {
+	start := time.Now()
+	album, err := i.p.GetAlbum(ctx, id)
+	i.observe("GetAlbum", start, err)
+	return album, err
+}
+
+func (i *instrumented) GetArtist(ctx context.Context, id string) (*model.SubsonicArtist, error) {
+	start := time.Now()
+	artist, err := i.p.GetArtist(ctx, id)
+	i.observe("GetArtist", start, err)
+	return artist, err
+}
+
+func (i *instrumented) GetCoverArt(ctx context.Context, id string, size int64) ([]byte, string, error) {
+	start := time.Now()
+	data, contentType, err := i.p.GetCoverArt(ctx, id, size)
+	i.observe("GetCoverArt", start, err)
+	return data, contentType, err
+}
+
+func (i *instrumented) GetAlbumSongsWithTrackCount(ctx context.Context, albumID string, trackCount int) ([]model.SubsonicSong, error) {
+	matcher, ok := i.p.(TrackCountMatcher)
+	if !ok {
+		return i.GetAlbumSongs(ctx, albumID)
+	}
+	start := time.Now()
+	songs, err := matcher.GetAlbumSongsWithTrackCount(ctx, albumID, trackCount)
+	i.observe("GetAlbumSongsWithTrackCount", start, err)
+	return songs, err
+}
+
+func (i *instrumented) GetReleaseSongs(ctx context.Context, releaseID string) ([]model.SubsonicSong, error) {
+	p, ok := i.p.(ReleaseProvider)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	start := time.Now()
+	songs, err := p.GetReleaseSongs(ctx, releaseID)
+	i.observe("GetReleaseSongs", start, err)
+	return songs, err
+}
+
+func (i *instrumented) UsesMBIDs() bool {
+	return UsesMBIDs(i.p)
+}
+
+func (i *instrumented) GetTopSongs(ctx context.Context, artist string, count int) ([]model.SubsonicSong, error) {
+	p, ok := i.p.(TopSongsProvider)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	start := time.Now()
+	songs, err := p.GetTopSongs(ctx, artist, count)
+	i.observe("GetTopSongs", start, err)
+	return songs, err
+}
+
+func (i *instrumented) GetSimilarSongs(ctx context.Context, artist string, title string, count int) ([]model.SubsonicSong, error) {
+	p, ok := i.p.(SimilarSongsProvider)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	start := time.Now()
+	songs, err := p.GetSimilarSongs(ctx, artist, title, count)
+	i.observe("GetSimilarSongs", start, err)
+	return songs, err
+}
@@ -0,0 +1,101 @@
+package metadata
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+// Swappable is a Provider whose underlying provider can be replaced while it's in use, so a configuration
+// reload reaches every service holding it. Optional capabilities are forwarded when the current provider
+// has them and report ErrUnsupported otherwise.
+type Swappable struct {
+	current atomic.Pointer[providerRef]
+}
+
+// providerRef boxes the interface so providers of different types can share one atomic.Pointer.
+type providerRef struct {
+	Provider
+}
+
+func NewSwappable(p Provider) *Swappable {
+	s := &Swappable{}
+	s.Swap(p)
+	return s
+}
+
+// Swap makes p the provider every following call goes to. Calls already running finish with the old one.
+func (s *Swappable) Swap(p Provider) {
+	s.current.Store(&providerRef{Provider: p})
+}
+
+// Current returns the provider calls are currently sent to.
+func (s *Swappable) Current() Provider {
+	return s.current.Load().Provider
+}
+
+func (s *Swappable) SearchSongs(ctx context.Context, query string) ([]model.SubsonicSong, error) {
+	return s.Current().SearchSongs(ctx, query)
+}
+
+func (s *Swappable) SearchAlbums(ctx context.Context, query string) ([]model.SubsonicAlbum, error) {
+	return s.Current().SearchAlbums(ctx, query)
+}
+
+func (s *Swappable) SearchArtists(ctx context.Context, query string) ([]model.SubsonicArtist, error) {
+	return s.Current().SearchArtists(ctx, query)
+}
+
+func (s *Swappable) GetAlbumSongs(ctx context.Context, albumID string) ([]model.SubsonicSong, error) {
+	return s.Current().GetAlbumSongs(ctx, albumID)
+}
+
+func (s *Swappable) GetSong(ctx context.Context, id string) (*model.SubsonicSong, error) {
+	return s.Current().GetSong(ctx, id)
+}
+
+func (s *Swappable) GetAlbum(ctx context.Context, id string) (*model.SubsonicAlbum, error) {
+	return s.Current().GetAlbum(ctx, id)
+}
+
+func (s *Swappable) GetArtist(ctx context.Context, id string) (*model.SubsonicArtist, error) {
+	return s.Current().GetArtist(ctx, id)
+}
+
+func (s *Swappable) GetCoverArt(ctx context.Context, id string, size int64) ([]byte, string, error) {
+	return s.Current().GetCoverArt(ctx, id, size)
+}
+
+func (s *Swappable) GetAlbumSongsWithTrackCount(ctx context.Context, albumID string, trackCount int) ([]model.SubsonicSong, error) {
+	p := s.Current()
+	if matcher, ok := p.(TrackCountMatcher); ok {
+		return matcher.GetAlbumSongsWithTrackCount(ctx, albumID, trackCount)
+	}
+	return p.GetAlbumSongs(ctx, albumID)
+}
+
+func (s *Swappable) GetReleaseSongs(ctx context.Context, releaseID string) ([]model.SubsonicSong, error) {
+	if p, ok := s.Current().(ReleaseProvider); ok {
+		return p.GetReleaseSongs(ctx, releaseID)
+	}
+	return nil, ErrUnsupported
+}
+
+func (s *Swappable) UsesMBIDs() bool {
+	return UsesMBIDs(s.Current())
+}
+
+func (s *Swappable) GetTopSongs(ctx context.Context, artist string, count int) ([]model.SubsonicSong, error) {
+	if p, ok := s.Current().(TopSongsProvider); ok {
+		return p.GetTopSongs(ctx, artist, count)
+	}
+	return nil, ErrUnsupported
+}
+
+func (s *Swappable) GetSimilarSongs(ctx context.Context, artist string, title string, count int) ([]model.SubsonicSong, error) {
+	if p, ok := s.Current().(SimilarSongsProvider); ok {
+		return p.GetSimilarSongs(ctx, artist, title, count)
+	}
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,53 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+// namedProvider answers GetSong with its name and has no optional capabilities. Unimplemented methods panic
+// through the nil embedded Provider.
+type namedProvider struct {
+	Provider
+	name string
+}
+
+func (p namedProvider) GetSong(context.Context, string) (*model.SubsonicSong, error) {
+	return &model.SubsonicSong{Title: p.name}, nil
+}
+
+// topSongsProvider adds GetTopSongs to a namedProvider.
+type topSongsProvider struct {
+	namedProvider
+}
+
+func (p topSongsProvider) GetTopSongs(context.Context, string, int) ([]model.SubsonicSong, error) {
+	return []model.SubsonicSong{{Title: p.name}}, nil
+}
+
+func TestSwappable(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		wantTop  error
+	}{
+		{name: "plain", provider: namedProvider{name: "plain"}, wantTop: ErrUnsupported},
+		{name: "top songs", provider: topSongsProvider{namedProvider{name: "top songs"}}},
+	}
+	s := NewSwappable(namedProvider{name: "initial"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.Swap(tt.provider)
+			song, err := s.GetSong(context.Background(), "1")
+			if err != nil || song.Title != tt.name {
+				t.Errorf("got %+v, %v from GetSong", song, err)
+			}
+			if _, err := s.GetTopSongs(context.Background(), "Daft Punk", 5); !errors.Is(err, tt.wantTop) {
+				t.Errorf("got %v from GetTopSongs, want %v", err, tt.wantTop)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+// Package metrics keeps counters, gauges and histograms in memory and serves them in the Prometheus text
+// format, so Navifetch can be scraped without pulling in a client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// collector is a metric family that can write itself in the text exposition format.
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, c)
+}
+
+// Handler serves every registered metric.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		registryMu.Lock()
+		collectors := append([]collector(nil), registry...)
+		registryMu.Unlock()
+		for _, c := range collectors {
+			c.write(w)
+		}
+	})
+}
+
+// family holds what all metric types share: the name, help text, label names and one series per
+// combination of label values.
+type family[S any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*S
+	// values keeps the label values of each series, keyed like series.
+	values map[string][]string
+	create func() *S
+}
+
+func newFamily[S any](name, help, kind string, labels []string, create func() *S) *family[S] {
+	return &family[S]{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*S),
+		values: make(map[string][]string),
+		create: create,
+	}
+}
+
+// with returns the series for labelValues, creating it on first use. Callers hold f.mu.
+func (f *family[S]) with(labelValues []string) *S {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = f.create()
+		f.series[key] = s
+		f.values[key] = append([]string(nil), labelValues...)
+	}
+	return s
+}
+
+// each calls fn for every series in a stable order, with f.mu held.
+func (f *family[S]) each(fn func(labels string, s *S)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fn(formatLabels(f.labels, f.values[key]), f.series[key])
+	}
+}
+
+func (f *family[S]) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+}
+
+// Counter is a value that only goes up, such as a number of requests.
+type Counter struct {
+	*family[float64]
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newFamily(name, help, "counter", labels, func() *float64 { return new(float64) })}
+	register(c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.with(labelValues) += v
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.header(w)
+	c.each(func(labels string, v *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labels, formatFloat(*v))
+	})
+}
+
+// Gauge is a value that goes up and down, such as a queue depth.
+type Gauge struct {
+	*family[float64]
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newFamily(name, help, "gauge", labels, func() *float64 { return new(float64) })}
+	register(g)
+	return g
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.with(labelValues) = v
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.with(labelValues) += v
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.header(w)
+	g.each(func(labels string, v *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labels, formatFloat(*v))
+	})
+}
+
+// Histogram counts observations, such as durations in seconds, into cumulative buckets.
+type Histogram struct {
+	*family[histogramSeries]
+	buckets []float64
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// DefaultBuckets suit request latencies in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{buckets: buckets}
+	h.family = newFamily(name, help, "histogram", labels, func() *histogramSeries {
+		return &histogramSeries{counts: make([]uint64, len(buckets))}
+	})
+	register(h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.with(labelValues)
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Since observes the seconds elapsed since start.
+func (h *Histogram) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.header(w)
+	h.each(func(labels string, s *histogramSeries) {
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLabel(labels, "le", formatFloat(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLabel(labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, s.count)
+	})
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel appends one label to an already formatted label set.
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + value + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The metrics Navifetch exposes on /metrics.
+var (
+	HTTPRequests = NewCounter("navifetch_http_requests_total",
+		"Requests served, by endpoint and status code.", "endpoint", "code")
+	HTTPDuration = NewHistogram("navifetch_http_request_duration_seconds",
+		"Time taken to serve requests, by endpoint.", DefaultBuckets, "endpoint")
+
+	Searches = NewCounter("navifetch_searches_total",
+		"Searches answered from the Navidrome library (local) or the metadata provider (external).", "source")
+
+	ProviderDuration = NewHistogram("navifetch_provider_request_duration_seconds",
+		"Time taken by metadata and lyrics provider calls, by provider and method.", DefaultBuckets, "provider", "method")
+	ProviderErrors = NewCounter("navifetch_provider_errors_total",
+		"Failed metadata and lyrics provider calls, by provider and method.", "provider", "method")
+
+	DownloadsInProgress = NewGauge("navifetch_downloads_in_progress",
+		"Downloads currently running.")
+	WantedPending = NewGauge("navifetch_wanted_pending",
+		"Items waiting on the wanted list.")
+	DownloadDuration = NewHistogram("navifetch_download_duration_seconds",
+		"Time taken by downloads, by result.", []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300}, "result")
+	DownloadFailures = NewCounter("navifetch_download_failures_total",
+		"Failed downloads, by the step that failed: lookup, staging, ytdlp, verify, move, shutdown or cancelled.", "reason")
+
+	RescanWait = NewHistogram("navifetch_rescan_wait_seconds",
+		"Time spent waiting for Navidrome to index a downloaded track.", []float64{0.5, 1, 2, 5, 10, 20, 30, 60}, "result")
+
+	CacheBytes = NewGauge("navifetch_cache_bytes",
+		"Size of the tracks downloaded for streaming only, as of the last cleanup or download.")
+	CacheFiles = NewGauge("navifetch_cache_files",
+		"Number of tracks downloaded for streaming only, as of the last cleanup or download.")
+	CacheEvictions = NewCounter("navifetch_cache_evictions_total",
+		"Streaming-only tracks removed by the cleanup job.")
+
+	UpstreamRequests = NewCounter("navifetch_upstream_requests_total",
+		"Requests sent to Navidrome, by endpoint and result: the status class (2xx, 4xx, 5xx) or error.", "endpoint", "result")
+)
+
+// StatusClass returns "2xx", "4xx" and so on for an HTTP status code.
+func StatusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// passthroughMethods are the Subsonic and OpenSubsonic methods clients call through Navifetch to Navidrome.
+var passthroughMethods = []string{
+	"ping", "getLicense", "getMusicFolders", "getIndexes", "getMusicDirectory", "getGenres", "getArtists",
+	"getArtist", "getAlbum", "getSong", "getVideos", "getVideoInfo", "getArtistInfo", "getArtistInfo2",
+	"getAlbumInfo", "getAlbumInfo2", "getSimilarSongs", "getSimilarSongs2", "getTopSongs", "getAlbumList",
+	"getAlbumList2", "getRandomSongs", "getSongsByGenre", "getNowPlaying", "getStarred", "getStarred2",
+	"search", "search2", "search3", "getPlaylists", "getPlaylist", "createPlaylist", "updatePlaylist",
+	"deletePlaylist", "stream", "download", "hls", "getCaptions", "getCoverArt", "getLyrics", "getAvatar",
+	"star", "unstar", "setRating", "scrobble", "getShares", "createShare", "updateShare", "deleteShare",
+	"getPodcasts", "getNewestPodcasts", "refreshPodcasts", "createPodcastChannel", "deletePodcastChannel",
+	"deletePodcastEpisode", "downloadPodcastEpisode", "jukeboxControl", "getInternetRadioStations",
+	"createInternetRadioStation", "updateInternetRadioStation", "deleteInternetRadioStation",
+	"getChatMessages", "addChatMessage", "getUser", "getUsers", "createUser", "updateUser", "deleteUser",
+	"changePassword", "getBookmarks", "createBookmark", "deleteBookmark", "getPlayQueue", "savePlayQueue",
+	"getScanStatus", "startScan",
+	"getOpenSubsonicExtensions", "getLyricsBySongId", "getPlayQueueByIndex", "savePlayQueueByIndex", "tokenInfo",
+}
+
+var (
+	subsonicMethodsMu sync.RWMutex
+	subsonicMethods   = make(map[string]bool)
+)
+
+func init() {
+	RegisterSubsonicMethods(passthroughMethods...)
+}
+
+// RegisterSubsonicMethods adds methods SubsonicMethod reports by name, for endpoints Navifetch serves itself.
+func RegisterSubsonicMethods(names ...string) {
+	subsonicMethodsMu.Lock()
+	defer subsonicMethodsMu.Unlock()
+	for _, name := range names {
+		subsonicMethods[name] = true
+	}
+}
+
+// SubsonicMethod returns the Subsonic method a /rest/ path calls, such as "getSong" for /rest/getSong.view,
+// or "other" for unknown methods, to keep the number of series bounded.
+func SubsonicMethod(path string) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(path, "/rest/"), ".view")
+	subsonicMethodsMu.RLock()
+	defer subsonicMethodsMu.RUnlock()
+	if !subsonicMethods[name] {
+		return "other"
+	}
+	return name
+}
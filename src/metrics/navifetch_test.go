@@ -0,0 +1,43 @@
+package metrics
+
+import "testing"
+
+func TestSubsonicMethod(t *testing.T) {
+	RegisterSubsonicMethods("getNavifetchThing")
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/rest/getSong", "getSong"},
+		{"/rest/getSong.view", "getSong"},
+		{"/rest/getOpenSubsonicExtensions", "getOpenSubsonicExtensions"},
+		{"/rest/getNavifetchThing.view", "getNavifetchThing"},
+		{"/rest/", "other"},
+		{"/rest/getSongs", "other"},
+		{"/rest/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "other"},
+		{"/rest/getSong/extra", "other"},
+		{"/rest/GETSONG", "other"},
+	}
+	for _, tt := range tests {
+		if got := SubsonicMethod(tt.path); got != tt.want {
+			t.Errorf("SubsonicMethod(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{200, "2xx"},
+		{204, "2xx"},
+		{404, "4xx"},
+		{502, "5xx"},
+	}
+	for _, tt := range tests {
+		if got := StatusClass(tt.code); got != tt.want {
+			t.Errorf("StatusClass(%d) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
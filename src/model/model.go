@@ -23,21 +23,116 @@ type ExternalItem struct {
 	Size          int64   `json:"size,omitempty"`
 }
 
+const (
+	// ServerName and ServerVersion identify Navifetch in the OpenSubsonic type and serverVersion fields.
+	ServerName    = "navifetch"
+	ServerVersion = "0.10.1"
+	// APIVersion is the Subsonic API version Navifetch speaks.
+	APIVersion = "1.16.1"
+)
+
+// Envelope holds the fields every subsonic-response carries. It is embedded without a tag so they are inlined.
+type Envelope struct {
+	Status        string `json:"status"`
+	Version       string `json:"version"`
+	Type          string `json:"type,omitempty"`
+	ServerVersion string `json:"serverVersion,omitempty"`
+	OpenSubsonic  bool   `json:"openSubsonic,omitempty"`
+}
+
+// OKEnvelope is the envelope of a successful response synthesized by Navifetch.
+func OKEnvelope() Envelope {
+	return Envelope{
+		Status:        "ok",
+		Version:       APIVersion,
+		Type:          ServerName,
+		ServerVersion: ServerVersion,
+		OpenSubsonic:  true,
+	}
+}
+
+// SubsonicOKResponse is an empty successful response, the answer to calls such as star or scrobble.
+type SubsonicOKResponse struct {
+	Subsonic Envelope `json:"subsonic-response"`
+}
+
+func OKResponse() SubsonicOKResponse {
+	return SubsonicOKResponse{Subsonic: OKEnvelope()}
+}
+
+// SubsonicErrorResponse is a failed response. Subsonic reports errors with status 200 and this envelope.
+type SubsonicErrorResponse struct {
+	Subsonic struct {
+		Envelope
+		Error SubsonicError `json:"error"`
+	} `json:"subsonic-response"`
+}
+
+// SubsonicError carries one of the error codes of the Subsonic API, such as 10 for a missing parameter
+// or 70 for data that wasn't found.
+type SubsonicError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func ErrorResponse(code int, message string) SubsonicErrorResponse {
+	var resp SubsonicErrorResponse
+	resp.Subsonic.Envelope = OKEnvelope()
+	resp.Subsonic.Status = "failed"
+	resp.Subsonic.Error = SubsonicError{Code: code, Message: message}
+	return resp
+}
+
+// WrapResponse builds a successful subsonic-response holding value under key, for responses without a typed model.
+func WrapResponse(key string, value any) map[string]any {
+	fields := envelopeFields(OKEnvelope())
+	fields[key] = value
+	return map[string]any{"subsonic-response": fields}
+}
+
+// envelopeFields returns the JSON fields of env, so untyped responses carry the same ones as typed responses.
+func envelopeFields(env Envelope) map[string]any {
+	fields := make(map[string]any)
+	// An envelope only holds strings and a bool, it always encodes.
+	raw, _ := json.Marshal(env)
+	_ = json.Unmarshal(raw, &fields)
+	return fields
+}
+
 // SubsonicSearchResponse is the top-level wrapper for Subsonic API responses
 type SubsonicSearchResponse struct {
 	Subsonic struct {
-		Status        string         `json:"status"`
-		Version       string         `json:"version"`
+		Envelope
 		SearchResult3 *SearchResult3 `json:"searchResult3,omitempty"`
 		Song          []SubsonicSong `json:"song,omitempty"`
 	} `json:"subsonic-response"`
 }
 
+type SubsonicSongResponse struct {
+	Subsonic struct {
+		Envelope
+		Song *SubsonicSong `json:"song,omitempty"`
+	} `json:"subsonic-response"`
+}
+
 type SubsonicAlbumResponse struct {
 	Subsonic struct {
-		Status  string         `json:"status"`
-		Version string         `json:"version"`
-		Album   *SubsonicAlbum `json:"album,omitempty"`
+		Envelope
+		Album *SubsonicAlbum `json:"album,omitempty"`
+	} `json:"subsonic-response"`
+}
+
+type SubsonicArtistResponse struct {
+	Subsonic struct {
+		Envelope
+		Artist *SubsonicArtist `json:"artist,omitempty"`
+	} `json:"subsonic-response"`
+}
+
+type SubsonicArtistInfoResponse struct {
+	Subsonic struct {
+		Envelope
+		ArtistInfo2 *SubsonicArtistInfo `json:"artistInfo2,omitempty"`
 	} `json:"subsonic-response"`
 }
 
@@ -58,9 +153,35 @@ type SubsonicAlbum struct {
 	Year          int            `json:"year"`
 	Genre         string         `json:"genre"`
 	MusicBrainzId string         `json:"musicBrainzId"`
+	RecordLabels  []RecordLabel  `json:"recordLabels,omitempty"`
+	ReleaseTypes  []string       `json:"releaseTypes,omitempty"`
 	Song          []SubsonicSong `json:"song"`
 }
 
+type RecordLabel struct {
+	Name string `json:"name"`
+}
+
+type SubsonicArtist struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	CoverArt       string          `json:"coverArt,omitempty"`
+	ArtistImageURL string          `json:"artistImageUrl,omitempty"`
+	AlbumCount     int64           `json:"albumCount"`
+	MusicBrainzId  string          `json:"musicBrainzId,omitempty"`
+	Album          []SubsonicAlbum `json:"album,omitempty"`
+}
+
+type SubsonicArtistInfo struct {
+	Biography      string           `json:"biography,omitempty"`
+	MusicBrainzId  string           `json:"musicBrainzId,omitempty"`
+	LastFmUrl      string           `json:"lastFmUrl,omitempty"`
+	SmallImageUrl  string           `json:"smallImageUrl,omitempty"`
+	MediumImageUrl string           `json:"mediumImageUrl,omitempty"`
+	LargeImageUrl  string           `json:"largeImageUrl,omitempty"`
+	SimilarArtist  []SubsonicArtist `json:"similarArtist,omitempty"`
+}
+
 type SearchResult3 struct {
 	Song []SubsonicSong `json:"song"`
 }
@@ -99,44 +220,212 @@ func (s *SearchResult3) UnmarshalJSON(data []byte) error {
 }
 
 type SubsonicSong struct {
-	ID                    string    `json:"id"`
-	Parent                string    `json:"parent,omitempty"`
-	Title                 string    `json:"title"`
-	Artist                string    `json:"artist"`
-	ArtistID              string    `json:"artistId,omitempty"`
-	Album                 string    `json:"album"`
-	AlbumID               string    `json:"albumId,omitempty"`
-	Genre                 string    `json:"genre,omitempty"`
-	CoverArt              string    `json:"coverArt,omitempty"`
-	Duration              int64     `json:"duration"`
-	Size                  int64     `json:"size"`
-	IsDir                 bool      `json:"isDir"`
-	IsVideo               bool      `json:"isVideo"`
-	Suffix                string    `json:"suffix"`
-	ContentType           string    `json:"contentType"`
-	TranscodedSuffix      string    `json:"transcodedSuffix,omitempty"`
-	TranscodedContentType string    `json:"transcodedContentType,omitempty"`
-	Type                  string    `json:"type,omitempty"`
-	MediaType             string    `json:"mediaType,omitempty"`
-	Created               time.Time `json:"created,omitempty"`
-	Path                  string    `json:"path,omitempty"`
-	ChannelCount          int       `json:"channelCount,omitempty"`
-	BitDepth              int       `json:"bitDepth,omitempty"`
-	SamplingRate          int       `json:"samplingRate,omitempty"`
-	Bpm                   int       `json:"bpm,omitempty"`
-	Comment               string    `json:"comment,omitempty"`
-	SortName              string    `json:"sortName,omitempty"`
-	MusicBrainzId         string    `json:"musicBrainzId,omitempty"`
-	DisplayArtist         string    `json:"displayArtist,omitempty"`
-	DisplayAlbumArtist    string    `json:"displayAlbumArtist,omitempty"`
-	DisplayComposer       string    `json:"displayComposer,omitempty"`
-	ExplicitStatus        string    `json:"explicitStatus,omitempty"`
+	ID                    string        `json:"id"`
+	Parent                string        `json:"parent,omitempty"`
+	Title                 string        `json:"title"`
+	Artist                string        `json:"artist"`
+	ArtistID              string        `json:"artistId,omitempty"`
+	Album                 string        `json:"album"`
+	AlbumID               string        `json:"albumId,omitempty"`
+	Genre                 string        `json:"genre,omitempty"`
+	CoverArt              string        `json:"coverArt,omitempty"`
+	Track                 int           `json:"track,omitempty"`
+	DiscNumber            int           `json:"discNumber,omitempty"`
+	Year                  int           `json:"year,omitempty"`
+	Duration              int64         `json:"duration"`
+	Size                  int64         `json:"size"`
+	IsDir                 bool          `json:"isDir"`
+	IsVideo               bool          `json:"isVideo"`
+	Suffix                string        `json:"suffix"`
+	ContentType           string        `json:"contentType"`
+	TranscodedSuffix      string        `json:"transcodedSuffix,omitempty"`
+	TranscodedContentType string        `json:"transcodedContentType,omitempty"`
+	Type                  string        `json:"type,omitempty"`
+	MediaType             string        `json:"mediaType,omitempty"`
+	Created               time.Time     `json:"created,omitempty"`
+	Path                  string        `json:"path,omitempty"`
+	ChannelCount          int           `json:"channelCount,omitempty"`
+	BitDepth              int           `json:"bitDepth,omitempty"`
+	SamplingRate          int           `json:"samplingRate,omitempty"`
+	Bpm                   int           `json:"bpm,omitempty"`
+	Comment               string        `json:"comment,omitempty"`
+	SortName              string        `json:"sortName,omitempty"`
+	MusicBrainzId         string        `json:"musicBrainzId,omitempty"`
+	DisplayArtist         string        `json:"displayArtist,omitempty"`
+	DisplayAlbumArtist    string        `json:"displayAlbumArtist,omitempty"`
+	DisplayComposer       string        `json:"displayComposer,omitempty"`
+	ExplicitStatus        string        `json:"explicitStatus,omitempty"`
+	ReplayGain            *ReplayGain   `json:"replayGain,omitempty"`
+	Genres                []ItemGenre   `json:"genres,omitempty"`
+	Artists               []ArtistID3   `json:"artists,omitempty"`
+	Contributors          []Contributor `json:"contributors,omitempty"`
+	ISRC                  []string      `json:"isrc,omitempty"`
+}
+
+// ReplayGain holds the OpenSubsonic replay gain values of a song, in dB.
+type ReplayGain struct {
+	TrackGain    float64 `json:"trackGain,omitempty"`
+	AlbumGain    float64 `json:"albumGain,omitempty"`
+	TrackPeak    float64 `json:"trackPeak,omitempty"`
+	AlbumPeak    float64 `json:"albumPeak,omitempty"`
+	BaseGain     float64 `json:"baseGain,omitempty"`
+	FallbackGain float64 `json:"fallbackGain,omitempty"`
+}
+
+type ItemGenre struct {
+	Name string `json:"name"`
+}
+
+// ArtistID3 is the short artist reference used by the OpenSubsonic artists and contributors lists.
+type ArtistID3 struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Contributor struct {
+	Role    string    `json:"role"`
+	SubRole string    `json:"subRole,omitempty"`
+	Artist  ArtistID3 `json:"artist"`
+}
+
+// OpenSubsonicExtension is one entry of getOpenSubsonicExtensions.
+type OpenSubsonicExtension struct {
+	Name     string `json:"name"`
+	Versions []int  `json:"versions"`
+}
+
+type OpenSubsonicExtensionsResponse struct {
+	Subsonic struct {
+		Envelope
+		OpenSubsonicExtensions []OpenSubsonicExtension `json:"openSubsonicExtensions"`
+	} `json:"subsonic-response"`
+}
+
+type SubsonicDirectoryResponse struct {
+	Subsonic struct {
+		Envelope
+		Directory *SubsonicDirectory `json:"directory,omitempty"`
+	} `json:"subsonic-response"`
+}
+
+// SubsonicDirectory is the folder view of getMusicDirectory, an artist's children are its albums with IsDir set.
+type SubsonicDirectory struct {
+	ID     string         `json:"id"`
+	Parent string         `json:"parent,omitempty"`
+	Name   string         `json:"name"`
+	Child  []SubsonicSong `json:"child"`
+}
+
+type SubsonicAlbumInfoResponse struct {
+	Subsonic struct {
+		Envelope
+		AlbumInfo *SubsonicAlbumInfo `json:"albumInfo,omitempty"`
+	} `json:"subsonic-response"`
+}
+
+type SubsonicAlbumInfo struct {
+	Notes          string `json:"notes,omitempty"`
+	MusicBrainzId  string `json:"musicBrainzId,omitempty"`
+	SmallImageUrl  string `json:"smallImageUrl,omitempty"`
+	MediumImageUrl string `json:"mediumImageUrl,omitempty"`
+	LargeImageUrl  string `json:"largeImageUrl,omitempty"`
+}
+
+type SubsonicLyricsResponse struct {
+	Subsonic struct {
+		Envelope
+		Lyrics *SubsonicLyrics `json:"lyrics,omitempty"`
+	} `json:"subsonic-response"`
+}
+
+// SubsonicLyrics is the plain text answer of getLyrics.
+type SubsonicLyrics struct {
+	Artist string `json:"artist,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Value  string `json:"value"`
+}
+
+type SubsonicLyricsListResponse struct {
+	Subsonic struct {
+		Envelope
+		LyricsList *LyricsList `json:"lyricsList,omitempty"`
+	} `json:"subsonic-response"`
+}
+
+type LyricsList struct {
+	StructuredLyrics []StructuredLyrics `json:"structuredLyrics"`
+}
+
+// StructuredLyrics is the OpenSubsonic getLyricsBySongId entry, Start is in milliseconds and only set when Synced.
+type StructuredLyrics struct {
+	DisplayArtist string      `json:"displayArtist,omitempty"`
+	DisplayTitle  string      `json:"displayTitle,omitempty"`
+	Lang          string      `json:"lang"`
+	Offset        int64       `json:"offset,omitempty"`
+	Synced        bool        `json:"synced"`
+	Line          []LyricLine `json:"line"`
+}
+
+type LyricLine struct {
+	Start *int64 `json:"start,omitempty"`
+	Value string `json:"value"`
+}
+
+type SubsonicPlaylistResponse struct {
+	Subsonic struct {
+		Envelope
+		Playlist *SubsonicPlaylist `json:"playlist,omitempty"`
+	} `json:"subsonic-response"`
+}
+
+type SubsonicPlaylistsResponse struct {
+	Subsonic struct {
+		Envelope
+		Playlists struct {
+			Playlist []SubsonicPlaylist `json:"playlist"`
+		} `json:"playlists"`
+	} `json:"subsonic-response"`
+}
+
+// SubsonicPlaylist is a playlist of getPlaylist, Entry is left empty by getPlaylists.
+type SubsonicPlaylist struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Comment   string         `json:"comment,omitempty"`
+	Owner     string         `json:"owner,omitempty"`
+	Public    bool           `json:"public"`
+	SongCount int            `json:"songCount"`
+	Duration  int64          `json:"duration"`
+	Entry     []SubsonicSong `json:"entry,omitempty"`
+}
+
+type SubsonicUserResponse struct {
+	Subsonic struct {
+		Envelope
+		User *SubsonicUser `json:"user,omitempty"`
+	} `json:"subsonic-response"`
+}
+
+type SubsonicUser struct {
+	Username  string `json:"username"`
+	AdminRole bool   `json:"adminRole"`
+}
+
+type SubsonicArtistsResponse struct {
+	Subsonic struct {
+		Envelope
+		Artists struct {
+			Index []struct {
+				Name   string           `json:"name"`
+				Artist []SubsonicArtist `json:"artist"`
+			} `json:"index"`
+		} `json:"artists"`
+	} `json:"subsonic-response"`
 }
 
 type SubsonicIndexResponse struct {
 	Subsonic struct {
-		Status        string         `json:"status"`
-		Version       string         `json:"version"`
+		Envelope
 		SearchResult3 *SearchResult3 `json:"searchResult3,omitempty"`
 		Indexes       struct {
 			Index []struct {
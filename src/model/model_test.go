@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWrapResponse(t *testing.T) {
+	var typed map[string]map[string]any
+	raw, err := json.Marshal(OKResponse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		t.Fatal(err)
+	}
+	envelope := typed["subsonic-response"]
+
+	tests := []struct {
+		key   string
+		value any
+	}{
+		{"song", map[string]any{"id": "external-1"}},
+		{"searchResult3", map[string]any{"song": []any{}}},
+		{"topSongs", nil},
+	}
+	for _, tt := range tests {
+		var wrapped map[string]map[string]any
+		raw, err := json.Marshal(WrapResponse(tt.key, tt.value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(raw, &wrapped); err != nil {
+			t.Fatal(err)
+		}
+		fields := wrapped["subsonic-response"]
+		if !reflect.DeepEqual(fields[tt.key], tt.value) {
+			t.Errorf("%s: got %v, want %v", tt.key, fields[tt.key], tt.value)
+		}
+		delete(fields, tt.key)
+		if !reflect.DeepEqual(fields, envelope) {
+			t.Errorf("%s: got envelope %v, want the typed one %v", tt.key, fields, envelope)
+		}
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	resp := ErrorResponse(70, "Song not found")
+	want := OKEnvelope()
+	want.Status = "failed"
+	if resp.Subsonic.Envelope != want || resp.Subsonic.Error != (SubsonicError{Code: 70, Message: "Song not found"}) {
+		t.Errorf("got %+v", resp.Subsonic)
+	}
+}
@@ -0,0 +1,77 @@
+package playlist
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+)
+
+// csvColumns maps the header names of common exports, such as Exportify's, to entry fields.
+var csvColumns = map[string]string{
+	"artist":         "artist",
+	"artists":        "artist",
+	"artist name":    "artist",
+	"artist name(s)": "artist",
+	"title":          "title",
+	"track":          "title",
+	"track name":     "title",
+	"name":           "title",
+	"album":          "album",
+	"album name":     "album",
+	"isrc":           "isrc",
+}
+
+// parseCSV reads artist,title,album,isrc rows. A header row, when present, may name the columns in any
+// order.
+func parseCSV(data []byte) (*Playlist, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{"artist": 0, "title": 1, "album": 2, "isrc": 3}
+	if len(rows) > 0 {
+		if header, ok := csvHeader(rows[0]); ok {
+			columns = header
+			rows = rows[1:]
+		}
+	}
+
+	pl := &Playlist{Entries: make([]Entry, 0, len(rows))}
+	for _, row := range rows {
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		entry := Entry{
+			// Multi-artist exports separate the artists with commas, the first one is the main artist.
+			Artist: strings.TrimSpace(strings.Split(field("artist"), ",")[0]),
+			Title:  field("title"),
+			Album:  field("album"),
+			ISRC:   strings.ToUpper(field("isrc")),
+		}
+		if entry.Title != "" {
+			pl.Entries = append(pl.Entries, entry)
+		}
+	}
+	return pl, nil
+}
+
+func csvHeader(row []string) (map[string]int, bool) {
+	columns := make(map[string]int)
+	for i, name := range row {
+		if field, ok := csvColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+	_, hasTitle := columns["title"]
+	return columns, hasTitle
+}
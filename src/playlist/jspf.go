@@ -0,0 +1,99 @@
+package playlist
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type jspfDocument struct {
+	Playlist struct {
+		Title string      `json:"title"`
+		Track []jspfTrack `json:"track"`
+	} `json:"playlist"`
+}
+
+type jspfTrack struct {
+	Title   string `json:"title"`
+	Creator string `json:"creator,omitempty"`
+	Album   string `json:"album,omitempty"`
+	// Duration is in milliseconds.
+	Duration int64 `json:"duration,omitempty"`
+	// Identifier is a single URI in the JSPF draft and a list in ListenBrainz exports.
+	Identifier json.RawMessage `json:"identifier,omitempty"`
+	Location   []string        `json:"location,omitempty"`
+}
+
+func parseJSPF(data []byte) (*Playlist, error) {
+	var doc jspfDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	pl := &Playlist{Name: strings.TrimSpace(doc.Playlist.Title), Entries: make([]Entry, 0, len(doc.Playlist.Track))}
+	for _, track := range doc.Playlist.Track {
+		entry := Entry{
+			Artist:   strings.TrimSpace(track.Creator),
+			Title:    strings.TrimSpace(track.Title),
+			Album:    strings.TrimSpace(track.Album),
+			Duration: track.Duration / 1000,
+		}
+		applyIdentifiers(&entry, jspfIdentifiers(track.Identifier))
+		if len(track.Location) > 0 {
+			entry.Location = track.Location[0]
+		}
+		if entry.Title != "" {
+			pl.Entries = append(pl.Entries, entry)
+		}
+	}
+	return pl, nil
+}
+
+func jspfIdentifiers(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}
+	}
+	return nil
+}
+
+// writeJSPF writes the playlist the way ListenBrainz exports it, with MusicBrainz recording URIs as the
+// identifiers.
+func writeJSPF(pl *Playlist) ([]byte, error) {
+	var doc jspfDocument
+	doc.Playlist.Title = pl.Name
+	doc.Playlist.Track = make([]jspfTrack, 0, len(pl.Entries))
+	for _, entry := range pl.Entries {
+		track := jspfTrack{
+			Title:    entry.Title,
+			Creator:  entry.Artist,
+			Album:    entry.Album,
+			Duration: entry.Duration * 1000,
+		}
+		identifiers := make([]string, 0, 2)
+		if entry.MBID != "" {
+			identifiers = append(identifiers, musicBrainzRecordingURL+entry.MBID)
+		}
+		if entry.ISRC != "" {
+			identifiers = append(identifiers, "isrc:"+entry.ISRC)
+		}
+		if len(identifiers) > 0 {
+			raw, err := json.Marshal(identifiers)
+			if err != nil {
+				return nil, err
+			}
+			track.Identifier = raw
+		}
+		if entry.Location != "" {
+			track.Location = []string{entry.Location}
+		}
+		doc.Playlist.Track = append(doc.Playlist.Track, track)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,116 @@
+package playlist
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// parseM3U reads plain and extended M3U. Tracks without an #EXTINF line are named after their file, which
+// exports usually call "Artist - Title.ext".
+func parseM3U(data []byte) (*Playlist, error) {
+	pl := &Playlist{Entries: make([]Entry, 0)}
+
+	var pending Entry
+	var hasInfo bool
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			pl.Name = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := parseExtInf(strings.TrimPrefix(line, "#EXTINF:"))
+			// #EXTALB and #EXTART may come before #EXTINF too.
+			info.Album = pending.Album
+			if pending.Artist != "" {
+				info.Artist = pending.Artist
+			}
+			pending, hasInfo = info, true
+		case strings.HasPrefix(line, "#EXTALB:"):
+			pending.Album = strings.TrimSpace(strings.TrimPrefix(line, "#EXTALB:"))
+		case strings.HasPrefix(line, "#EXTART:"):
+			pending.Artist = strings.TrimSpace(strings.TrimPrefix(line, "#EXTART:"))
+		case strings.HasPrefix(line, "#"):
+		default:
+			entry := pending
+			entry.Location = line
+			if !hasInfo || entry.Title == "" {
+				entry.Artist, entry.Title = splitArtistTitle(locationName(line))
+				if pending.Artist != "" {
+					entry.Artist = pending.Artist
+				}
+			}
+			if entry.Title != "" {
+				pl.Entries = append(pl.Entries, entry)
+			}
+			pending, hasInfo = Entry{}, false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pl, nil
+}
+
+// parseExtInf reads "duration attributes,Artist - Title".
+func parseExtInf(info string) Entry {
+	var entry Entry
+	header, display, found := strings.Cut(info, ",")
+	if !found {
+		display = header
+		header = ""
+	}
+	if fields := strings.Fields(header); len(fields) > 0 {
+		if seconds, err := strconv.ParseInt(fields[0], 10, 64); err == nil && seconds > 0 {
+			entry.Duration = seconds
+		}
+	}
+	entry.Artist, entry.Title = splitArtistTitle(display)
+	return entry
+}
+
+// locationName returns the file name of a path or URL without its extension.
+func locationName(location string) string {
+	if u, err := url.Parse(location); err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		location = u.Path
+	}
+	location = strings.ReplaceAll(location, "\\", "/")
+	name := path.Base(location)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// writeM3U writes an extended M3U8 playlist. Entries without a location are skipped, players ignore them.
+func writeM3U(pl *Playlist) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	if pl.Name != "" {
+		fmt.Fprintf(&buf, "#PLAYLIST:%s\n", pl.Name)
+	}
+	for _, entry := range pl.Entries {
+		if entry.Location == "" {
+			continue
+		}
+		display := entry.Title
+		if entry.Artist != "" {
+			display = entry.Artist + " - " + entry.Title
+		}
+		duration := entry.Duration
+		if duration == 0 {
+			duration = -1
+		}
+		fmt.Fprintf(&buf, "#EXTINF:%d,%s\n", duration, display)
+		if entry.Album != "" {
+			fmt.Fprintf(&buf, "#EXTALB:%s\n", entry.Album)
+		}
+		buf.WriteString(entry.Location)
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
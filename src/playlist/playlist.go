@@ -0,0 +1,118 @@
+package playlist
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Entry is one track of an imported playlist. Only Title is guaranteed, the rest depends on the format.
+type Entry struct {
+	Artist string `json:"artist,omitempty"`
+	Title  string `json:"title"`
+	Album  string `json:"album,omitempty"`
+	ISRC   string `json:"isrc,omitempty"`
+	// MBID is the MusicBrainz recording ID, ListenBrainz JSPF exports carry it.
+	MBID string `json:"mbid,omitempty"`
+	// Duration is in seconds.
+	Duration int64 `json:"duration,omitempty"`
+	// Location is the file path or URL the playlist points at, exports use paths relative to the library.
+	Location string `json:"location,omitempty"`
+}
+
+type Playlist struct {
+	Name    string
+	Entries []Entry
+}
+
+const (
+	FormatM3U  = "m3u"
+	FormatXSPF = "xspf"
+	FormatJSPF = "jspf"
+	FormatCSV  = "csv"
+)
+
+// Parse reads a playlist file. The format is taken from the file extension and sniffed from the content
+// when the extension is unknown. The playlist name defaults to the file name.
+func Parse(filename string, data []byte) (*Playlist, error) {
+	format := DetectFormat(filename, data)
+
+	var pl *Playlist
+	var err error
+	switch format {
+	case FormatM3U:
+		pl, err = parseM3U(data)
+	case FormatXSPF:
+		pl, err = parseXSPF(data)
+	case FormatJSPF:
+		pl, err = parseJSPF(data)
+	case FormatCSV:
+		pl, err = parseCSV(data)
+	default:
+		return nil, fmt.Errorf("unknown playlist format of %s", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s playlist: %w", format, err)
+	}
+
+	if pl.Name == "" && filename != "" {
+		pl.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	return pl, nil
+}
+
+// Encode writes the playlist in one of the export formats, M3U (as M3U8) or JSPF.
+func Encode(pl *Playlist, format string) ([]byte, error) {
+	switch format {
+	case FormatM3U, "m3u8":
+		return writeM3U(pl), nil
+	case FormatJSPF:
+		return writeJSPF(pl)
+	default:
+		return nil, fmt.Errorf("can't export playlists as %q, use m3u8 or jspf", format)
+	}
+}
+
+// ContentType returns the MIME type and file extension of an export format.
+func ContentType(format string) (string, string) {
+	if format == FormatJSPF {
+		return "application/jspf+json", ".jspf"
+	}
+	return "audio/x-mpegurl; charset=utf-8", ".m3u8"
+}
+
+func DetectFormat(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".m3u", ".m3u8":
+		return FormatM3U
+	case ".xspf":
+		return FormatXSPF
+	case ".jspf", ".json":
+		return FormatJSPF
+	case ".csv":
+		return FormatCSV
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("#EXTM3U")):
+		return FormatM3U
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return FormatXSPF
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatJSPF
+	case len(trimmed) > 0:
+		return FormatCSV
+	}
+	return ""
+}
+
+// splitArtistTitle splits the "Artist - Title" form used by M3U titles and file names.
+func splitArtistTitle(s string) (string, string) {
+	artist, title, found := strings.Cut(s, " - ")
+	if !found {
+		return "", strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(artist), strings.TrimSpace(title)
+}
@@ -0,0 +1,232 @@
+package playlist
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		data     string
+		want     string
+	}{
+		{"mix.m3u", "", FormatM3U},
+		{"mix.M3U8", "", FormatM3U},
+		{"mix.xspf", "", FormatXSPF},
+		{"mix.jspf", "", FormatJSPF},
+		{"mix.json", "", FormatJSPF},
+		{"mix.csv", "", FormatCSV},
+		{"mix.txt", "\xef\xbb\xbf#EXTM3U\n", FormatM3U},
+		{"mix", "  <?xml version=\"1.0\"?>", FormatXSPF},
+		{"", "{\"playlist\": {}}", FormatJSPF},
+		{"mix.txt", "Daft Punk,One More Time", FormatCSV},
+		{"mix.txt", " \n", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.filename, []byte(tt.data)); got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %q, want %q", tt.filename, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		wantName string
+		want     []Entry
+	}{
+		{
+			name:     "extended m3u",
+			filename: "road trip.m3u8",
+			data: `#EXTM3U
+#PLAYLIST:Road Trip
+#EXTINF:224,Daft Punk - Harder, Better, Faster, Stronger
+#EXTALB:Discovery
+Daft Punk/Discovery/04 Harder, Better, Faster, Stronger.flac
+
+#EXTART:Justice
+#EXTINF:-1,D.A.N.C.E.
+http://example.com/stream/dance.mp3
+`,
+			wantName: "Road Trip",
+			want: []Entry{
+				{Artist: "Daft Punk", Title: "Harder, Better, Faster, Stronger", Album: "Discovery", Duration: 224,
+					Location: "Daft Punk/Discovery/04 Harder, Better, Faster, Stronger.flac"},
+				{Artist: "Justice", Title: "D.A.N.C.E.", Location: "http://example.com/stream/dance.mp3"},
+			},
+		},
+		{
+			name:     "plain m3u",
+			filename: "favourites.m3u",
+			data:     "C:\\Music\\Air - La Femme d'Argent.mp3\n# comment\nhttps://example.com/Phoenix%20-%201901.ogg\n",
+			wantName: "favourites",
+			want: []Entry{
+				{Artist: "Air", Title: "La Femme d'Argent", Location: "C:\\Music\\Air - La Femme d'Argent.mp3"},
+				{Artist: "Phoenix", Title: "1901", Location: "https://example.com/Phoenix%20-%201901.ogg"},
+			},
+		},
+		{
+			name:     "csv without header",
+			filename: "list.csv",
+			data:     "Daft Punk,One More Time,Discovery,gbduw0000053\nAir,Sexy Boy\n,\n",
+			wantName: "list",
+			want: []Entry{
+				{Artist: "Daft Punk", Title: "One More Time", Album: "Discovery", ISRC: "GBDUW0000053"},
+				{Artist: "Air", Title: "Sexy Boy"},
+			},
+		},
+		{
+			name:     "exportify csv",
+			filename: "liked.csv",
+			data: "\xef\xbb\xbfTrack URI,Track Name,Artist Name(s),Album Name,ISRC\n" +
+				"spotify:track:1,Get Lucky,\"Daft Punk, Pharrell Williams\",Random Access Memories,USQX91300108\n",
+			wantName: "liked",
+			want: []Entry{
+				{Artist: "Daft Punk", Title: "Get Lucky", Album: "Random Access Memories", ISRC: "USQX91300108"},
+			},
+		},
+		{
+			name:     "listenbrainz jspf",
+			filename: "export.jspf",
+			data: `{"playlist": {"title": "Weekly Jams", "track": [
+				{"title": "Digital Love", "creator": "Daft Punk", "album": "Discovery", "duration": 301000,
+				 "identifier": ["https://musicbrainz.org/recording/8f8a3a3a-1b2c-4d5e-8f90-0123456789ab"]},
+				{"title": "", "creator": "Nobody"}
+			]}}`,
+			wantName: "Weekly Jams",
+			want: []Entry{
+				{Artist: "Daft Punk", Title: "Digital Love", Album: "Discovery", Duration: 301,
+					MBID: "8f8a3a3a-1b2c-4d5e-8f90-0123456789ab"},
+			},
+		},
+		{
+			name:     "draft jspf",
+			filename: "draft.json",
+			data: `{"playlist": {"track": [
+				{"title": "Aerodynamic", "creator": "Daft Punk", "identifier": "isrc:gbduw0000054",
+				 "location": ["file:///music/aerodynamic.flac"]}
+			]}}`,
+			wantName: "draft",
+			want: []Entry{
+				{Artist: "Daft Punk", Title: "Aerodynamic", ISRC: "GBDUW0000054", Location: "file:///music/aerodynamic.flac"},
+			},
+		},
+		{
+			name:     "xspf",
+			filename: "mix.xspf",
+			data: `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <title>Mix</title>
+  <trackList>
+    <track>
+      <location>file:///music/Daft%20Punk%20-%20Veridis%20Quo.flac</location>
+      <identifier>https://musicbrainz.org/recording/0f0e0d0c-0b0a-0908-0706-050403020100/</identifier>
+      <duration>345000</duration>
+    </track>
+    <track>
+      <title>Crescendolls</title>
+      <creator>Daft Punk</creator>
+      <album>Discovery</album>
+    </track>
+    <track>
+      <annotation>nothing to go on</annotation>
+    </track>
+  </trackList>
+</playlist>`,
+			wantName: "Mix",
+			want: []Entry{
+				{Artist: "Daft Punk", Title: "Veridis Quo", Duration: 345, MBID: "0f0e0d0c-0b0a-0908-0706-050403020100",
+					Location: "file:///music/Daft%20Punk%20-%20Veridis%20Quo.flac"},
+				{Artist: "Daft Punk", Title: "Crescendolls", Album: "Discovery"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl, err := Parse(tt.filename, []byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pl.Name != tt.wantName {
+				t.Errorf("got name %q, want %q", pl.Name, tt.wantName)
+			}
+			if !slices.Equal(pl.Entries, tt.want) {
+				t.Errorf("got entries\n%+v\nwant\n%+v", pl.Entries, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		filename string
+		data     string
+	}{
+		{"empty.txt", ""},
+		{"broken.jspf", `{"playlist": [`},
+		{"broken.xspf", `<playlist><trackList><track>`},
+		{"broken.csv", "\"unterminated,quote\n"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.filename, []byte(tt.data)); err == nil {
+			t.Errorf("Parse(%s) succeeded", tt.filename)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	pl := &Playlist{
+		Name: "Road Trip",
+		Entries: []Entry{
+			{Artist: "Daft Punk", Title: "Digital Love", Album: "Discovery", Duration: 301, MBID: "c1b5c9d6-7f54-4c61-8b5c-2c0e4c6a3b1f",
+				ISRC: "GBDUW0000059", Location: "downloads/Daft Punk/Discovery/Digital Love.mp3"},
+			{Artist: "Justice", Title: "D.A.N.C.E.", Location: "Justice/Cross/D.A.N.C.E..flac"},
+		},
+	}
+	tests := []struct {
+		format string
+		want   []Entry
+	}{
+		// M3U has no room for identifiers.
+		{format: FormatM3U, want: []Entry{
+			{Artist: "Daft Punk", Title: "Digital Love", Album: "Discovery", Duration: 301, Location: "downloads/Daft Punk/Discovery/Digital Love.mp3"},
+			{Artist: "Justice", Title: "D.A.N.C.E.", Location: "Justice/Cross/D.A.N.C.E..flac"},
+		}},
+		{format: FormatJSPF, want: pl.Entries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data, err := Encode(pl, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, ext := ContentType(tt.format)
+			parsed, err := Parse("export"+ext, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Name != pl.Name || !slices.Equal(parsed.Entries, tt.want) {
+				t.Errorf("got %q %+v, want %q %+v", parsed.Name, parsed.Entries, pl.Name, tt.want)
+			}
+		})
+	}
+
+	if _, err := Encode(pl, FormatXSPF); err == nil {
+		t.Error("want an error for an unsupported export format")
+	}
+}
+
+func TestEncodeM3USkipsEntriesWithoutLocation(t *testing.T) {
+	pl := &Playlist{Entries: []Entry{{Title: "Nowhere"}, {Title: "Somewhere", Location: "a.mp3"}}}
+	data, err := Encode(pl, "m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#EXTM3U\n#EXTINF:-1,Somewhere\na.mp3\n"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
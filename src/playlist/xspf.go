@@ -0,0 +1,66 @@
+package playlist
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+type xspfPlaylist struct {
+	Title  string      `xml:"title"`
+	Tracks []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location   []string `xml:"location"`
+	Identifier []string `xml:"identifier"`
+	Title      string   `xml:"title"`
+	Creator    string   `xml:"creator"`
+	Album      string   `xml:"album"`
+	// Duration is in milliseconds.
+	Duration int64 `xml:"duration"`
+}
+
+func parseXSPF(data []byte) (*Playlist, error) {
+	var doc xspfPlaylist
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	pl := &Playlist{Name: strings.TrimSpace(doc.Title), Entries: make([]Entry, 0, len(doc.Tracks))}
+	for _, track := range doc.Tracks {
+		entry := Entry{
+			Artist:   strings.TrimSpace(track.Creator),
+			Title:    strings.TrimSpace(track.Title),
+			Album:    strings.TrimSpace(track.Album),
+			Duration: track.Duration / 1000,
+		}
+		applyIdentifiers(&entry, track.Identifier)
+		if len(track.Location) > 0 {
+			entry.Location = track.Location[0]
+			if entry.Title == "" {
+				entry.Artist, entry.Title = splitArtistTitle(locationName(entry.Location))
+			}
+		}
+		if entry.Title != "" {
+			pl.Entries = append(pl.Entries, entry)
+		}
+	}
+	return pl, nil
+}
+
+const musicBrainzRecordingURL = "https://musicbrainz.org/recording/"
+
+// applyIdentifiers picks the MusicBrainz recording ID and ISRC out of XSPF/JSPF identifier URIs.
+func applyIdentifiers(entry *Entry, identifiers []string) {
+	for _, identifier := range identifiers {
+		identifier = strings.TrimSpace(identifier)
+		lower := strings.ToLower(identifier)
+		switch {
+		case strings.Contains(lower, "musicbrainz.org/recording/"):
+			_, mbid, _ := strings.Cut(identifier, "/recording/")
+			entry.MBID = strings.Trim(mbid, "/")
+		case strings.HasPrefix(lower, "isrc:"):
+			entry.ISRC = strings.ToUpper(identifier[len("isrc:"):])
+		}
+	}
+}
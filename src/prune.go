@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/GerardPolloRebozado/navifetch/src/service"
+)
+
+// runPrune implements "navifetch prune [-dry-run]", which runs the cleanup job once instead of waiting for
+// CACHE_CLEANUP_INTERVAL.
+func runPrune(args []string) error {
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the files that would be removed without removing them")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: navifetch prune [flags]")
+		fmt.Fprintln(flags.Output(), "Removes streamed-only tracks older than CACHE_MAX_AGE and leftovers of interrupted downloads.")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		files := service.ExpiredFiles(cfg)
+		for _, file := range files {
+			fmt.Println(file)
+		}
+		fmt.Printf("%d files and staging folders would be removed\n", len(files))
+		return nil
+	}
+	// The server keeps its own copy of the track index, it drops the removed files when it next lists them.
+	fmt.Printf("Removed %d cached files\n", service.CleanupJob(cfg, service.NewTrackIndex(cfg)))
+	return nil
+}
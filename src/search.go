@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+)
+
+// runSearch implements "navifetch search [-type song|album|artist] QUERY", which lists what the configured
+// metadata provider finds, with the external IDs download and the wanted list take.
+func runSearch(args []string) error {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	kind := flags.String("type", "song", "what to search for, song, album or artist")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: navifetch search [flags] QUERY")
+		fmt.Fprintln(flags.Output(), "Searches the configured metadata provider.")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 || (*kind != "song" && *kind != "album" && *kind != "artist") {
+		flags.Usage()
+		os.Exit(2)
+	}
+	query := strings.Join(flags.Args(), " ")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	p, err := metadata.NewProvider(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	switch *kind {
+	case "song":
+		songs, err := p.SearchSongs(ctx, query)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "ID\tARTIST\tTITLE\tALBUM\tLENGTH")
+		for _, song := range songs {
+			length := time.Duration(song.Duration) * time.Second
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n", song.ID, trimExternal(song.Artist), trimExternal(song.Title), trimExternal(song.Album), length)
+		}
+	case "album":
+		albums, err := p.SearchAlbums(ctx, query)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "ID\tARTIST\tALBUM\tYEAR\tTRACKS")
+		for _, album := range albums {
+			fmt.Fprintf(out, "%s\t%s\t%s\t%d\t%d\n", album.ID, trimExternal(album.Artist), trimExternal(album.Name), album.Year, album.SongCount)
+		}
+	case "artist":
+		artists, err := p.SearchArtists(ctx, query)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "ID\tARTIST")
+		for _, artist := range artists {
+			fmt.Fprintf(out, "%s\t%s\n", artist.ID, trimExternal(artist.Name))
+		}
+	}
+	return out.Flush()
+}
+
+// trimExternal drops the marker providers append to the titles of external songs.
+func trimExternal(name string) string {
+	return strings.TrimSuffix(name, " (external)")
+}
@@ -3,7 +3,10 @@ package service
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
+	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/GerardPolloRebozado/navifetch/src/metadata"
@@ -12,14 +15,16 @@ import (
 )
 
 type AlbumService struct {
-	upstream NavidromeClient
-	metadata metadata.Provider
+	upstream   NavidromeClient
+	metadata   metadata.Provider
+	enrichment metadata.Provider
 }
 
-func NewAlbumService(upstream NavidromeClient, metadata metadata.Provider) *AlbumService {
+func NewAlbumService(upstream NavidromeClient, metadata metadata.Provider, enrichment metadata.Provider) *AlbumService {
 	return &AlbumService{
-		upstream: upstream,
-		metadata: metadata,
+		upstream:   upstream,
+		metadata:   metadata,
+		enrichment: enrichment,
 	}
 }
 
@@ -37,11 +42,10 @@ func (s *AlbumService) GetAlbum(ctx context.Context, albumID string, path string
 
 		songs, err := s.metadata.GetAlbumSongs(ctx, albumTrimmedID)
 		if err != nil {
-			log.Printf("Error fetching external album songs %s: %v", albumID, err)
+			slog.ErrorContext(ctx, "Error fetching external album songs", "id", albumID, "err", err)
 		}
 
-		subsonicAlbumResponse.Subsonic.Status = "ok"
-		subsonicAlbumResponse.Subsonic.Version = "1.16.1"
+		subsonicAlbumResponse.Subsonic.Envelope = model.OKEnvelope()
 		subsonicAlbumResponse.Subsonic.Album = album
 		subsonicAlbumResponse.Subsonic.Album.Song = songs
 	} else {
@@ -58,10 +62,18 @@ func (s *AlbumService) GetAlbum(ctx context.Context, albumID string, path string
 			return &subsonicAlbumResponse, nil
 		}
 
-		// Try to use MBID if Navidrome has it
-		if subsonicAlbumResponse.Subsonic.Album.MusicBrainzId != "" {
-			log.Printf("Enriching local album using MBID: %s", subsonicAlbumResponse.Subsonic.Album.MusicBrainzId)
-			externalSongs, err = s.metadata.GetAlbumSongs(ctx, subsonicAlbumResponse.Subsonic.Album.MusicBrainzId)
+		// Use the release MBID Navidrome has, or the edition the client names with releaseId, when the
+		// enrichment provider understands MBIDs. Others go straight to the search below.
+		releaseID := subsonicAlbumResponse.Subsonic.Album.MusicBrainzId
+		if query, err := url.ParseQuery(rawQuery); err == nil && query.Get("releaseId") != "" {
+			releaseID = query.Get("releaseId")
+		}
+		if releaseID != "" && metadata.UsesMBIDs(s.enrichment) {
+			slog.DebugContext(ctx, "Enriching local album using its MBID", "mbid", releaseID)
+			externalSongs, err = s.releaseSongs(ctx, releaseID, len(subsonicAlbumResponse.Subsonic.Album.Song))
+			if err != nil {
+				slog.InfoContext(ctx, "Error fetching songs for release", "mbid", releaseID, "err", err)
+			}
 		}
 
 		if len(externalSongs) == 0 {
@@ -77,40 +89,156 @@ func (s *AlbumService) GetAlbum(ctx context.Context, albumID string, path string
 			if artistName != "" {
 				searchQuery = artistName + " - " + albumName
 			}
-			log.Printf("Enriching local album via search. ID: %s, Name: '%s', Album: '%s', Title: '%s', Artist: '%s'. Query: '%s'",
-				albumID, subsonicAlbumResponse.Subsonic.Album.Name, subsonicAlbumResponse.Subsonic.Album.Album,
-				subsonicAlbumResponse.Subsonic.Album.Title, artistName, searchQuery)
+			slog.DebugContext(ctx, "Enriching local album via search", "id", albumID,
+				"name", subsonicAlbumResponse.Subsonic.Album.Name, "album", subsonicAlbumResponse.Subsonic.Album.Album,
+				"title", subsonicAlbumResponse.Subsonic.Album.Title, "artist", artistName, "query", searchQuery)
 
-			externalAlbums, err := s.metadata.SearchAlbums(ctx, searchQuery)
+			externalAlbums, err := s.enrichment.SearchAlbums(ctx, searchQuery)
 			if err == nil && len(externalAlbums) > 0 {
-				log.Printf("Found %d external albums for query '%s'. Taking first: %s", len(externalAlbums), searchQuery, externalAlbums[0].ID)
-				externalSongs, err = s.metadata.GetAlbumSongs(ctx, externalAlbums[0].ID)
+				slog.DebugContext(ctx, "Found external albums, taking the first", "count", len(externalAlbums), "query", searchQuery, "id", externalAlbums[0].ID)
+				externalSongs, err = s.enrichmentSongs(ctx, externalAlbums[0].ID, len(subsonicAlbumResponse.Subsonic.Album.Song))
 				if err != nil {
-					log.Printf("Error fetching songs for external album %s: %v", externalAlbums[0].ID, err)
+					slog.ErrorContext(ctx, "Error fetching songs for external album", "id", externalAlbums[0].ID, "err", err)
 				}
 			} else if err != nil {
-				log.Printf("Error searching external albums for query '%s': %v", searchQuery, err)
+				slog.ErrorContext(ctx, "Error searching external albums", "query", searchQuery, "err", err)
 			} else {
-				log.Printf("No external albums found for query '%s'", searchQuery)
+				slog.DebugContext(ctx, "No external albums found", "query", searchQuery)
 			}
 		}
 	}
 
-	log.Printf("External songs found: %d for album %s", len(externalSongs), albumID)
+	slog.DebugContext(ctx, "External songs found", "count", len(externalSongs), "id", albumID)
 	// Merge logic (common to both if external songs were found)
 	if subsonicAlbumResponse.Subsonic.Album != nil && len(externalSongs) > 0 {
 		existingSongs := subsonicAlbumResponse.Subsonic.Album.Song
-		log.Printf("Local album has %d songs. Merging with %d external songs.", len(existingSongs), len(externalSongs))
+		slog.DebugContext(ctx, "Merging external songs into local album", "local", len(existingSongs), "external", len(externalSongs))
 		for _, song := range externalSongs {
 			if !util.IsSongInSubsonicSongList(strings.TrimSpace(song.Title), existingSongs) {
-				log.Printf("Adding missing song to album: %s", song.Title)
-				subsonicAlbumResponse.Subsonic.Album.Song = append(subsonicAlbumResponse.Subsonic.Album.Song, song)
+				slog.DebugContext(ctx, "Adding missing song to album", "title", song.Title, "disc", song.DiscNumber, "track", song.Track)
+				subsonicAlbumResponse.Subsonic.Album.Song = insertSongByPosition(subsonicAlbumResponse.Subsonic.Album.Song, song)
 			}
 		}
 		newCount := len(subsonicAlbumResponse.Subsonic.Album.Song)
-		log.Printf("Album %s enrichment complete: %d -> %d songs", albumID, len(existingSongs), newCount)
+		slog.DebugContext(ctx, "Album enrichment complete", "id", albumID, "before", len(existingSongs), "after", newCount)
 		subsonicAlbumResponse.Subsonic.Album.SongCount = int64(newCount)
 	}
 
 	return &subsonicAlbumResponse, nil
 }
+
+// GetMusicDirectory builds getMusicDirectory for an external ID, which names either an album, listed with
+// its songs, or an artist, listed with its albums. Navidrome answers it for local IDs.
+func (s *AlbumService) GetMusicDirectory(ctx context.Context, id string) (*model.SubsonicDirectoryResponse, error) {
+	trimmedID := strings.TrimPrefix(id, "external-")
+	var resp model.SubsonicDirectoryResponse
+	resp.Subsonic.Envelope = model.OKEnvelope()
+
+	if album, err := s.metadata.GetAlbum(ctx, trimmedID); err == nil {
+		songs, err := s.metadata.GetAlbumSongs(ctx, trimmedID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error fetching external album songs", "id", id, "err", err)
+		}
+		for i := range songs {
+			songs[i].Parent = id
+		}
+		resp.Subsonic.Directory = &model.SubsonicDirectory{
+			ID:     id,
+			Parent: album.ArtistID,
+			Name:   album.Name,
+			Child:  songs,
+		}
+		return &resp, nil
+	}
+
+	artist, err := s.metadata.GetArtist(ctx, trimmedID)
+	if err != nil {
+		return nil, err
+	}
+	children := make([]model.SubsonicSong, 0, len(artist.Album))
+	for _, album := range artist.Album {
+		children = append(children, model.SubsonicSong{
+			ID:       album.ID,
+			Parent:   id,
+			Title:    album.Name,
+			Artist:   artist.Name,
+			ArtistID: id,
+			Album:    album.Name,
+			CoverArt: album.CoverArt,
+			Year:     album.Year,
+			IsDir:    true,
+		})
+	}
+	resp.Subsonic.Directory = &model.SubsonicDirectory{
+		ID:    id,
+		Name:  artist.Name,
+		Child: children,
+	}
+	return &resp, nil
+}
+
+// GetAlbumInfo builds getAlbumInfo2 for an external album, Navidrome answers it for local ones.
+func (s *AlbumService) GetAlbumInfo(ctx context.Context, albumID string) (*model.SubsonicAlbumInfoResponse, error) {
+	album, err := s.metadata.GetAlbum(ctx, strings.TrimPrefix(albumID, "external-"))
+	if err != nil {
+		return nil, err
+	}
+
+	info := &model.SubsonicAlbumInfo{MusicBrainzId: album.MusicBrainzId}
+	// Some providers mint cover art IDs rather than URLs, clients fetch those through getCoverArt.
+	if strings.HasPrefix(album.CoverArt, "http") {
+		info.SmallImageUrl = album.CoverArt
+		info.MediumImageUrl = album.CoverArt
+		info.LargeImageUrl = album.CoverArt
+	}
+
+	var resp model.SubsonicAlbumInfoResponse
+	resp.Subsonic.Envelope = model.OKEnvelope()
+	resp.Subsonic.AlbumInfo = info
+	return &resp, nil
+}
+
+// enrichmentSongs fetches the external tracklist of an album, letting providers that know several editions
+// pick the one matching the local track count.
+func (s *AlbumService) enrichmentSongs(ctx context.Context, albumID string, trackCount int) ([]model.SubsonicSong, error) {
+	if matcher, ok := s.enrichment.(metadata.TrackCountMatcher); ok {
+		return matcher.GetAlbumSongsWithTrackCount(ctx, albumID, trackCount)
+	}
+	return s.enrichment.GetAlbumSongs(ctx, albumID)
+}
+
+// releaseSongs fetches the tracklist of the exact edition a release MBID names from providers that tell
+// editions apart, other providers look it up like any album ID.
+func (s *AlbumService) releaseSongs(ctx context.Context, releaseID string, trackCount int) ([]model.SubsonicSong, error) {
+	if p, ok := s.enrichment.(metadata.ReleaseProvider); ok {
+		songs, err := p.GetReleaseSongs(ctx, releaseID)
+		if !errors.Is(err, metadata.ErrUnsupported) {
+			return songs, err
+		}
+	}
+	return s.enrichmentSongs(ctx, releaseID, trackCount)
+}
+
+// insertSongByPosition places song before the first song that comes after it on the album.
+// Songs without a track number can't be placed and go to the end.
+func insertSongByPosition(songs []model.SubsonicSong, song model.SubsonicSong) []model.SubsonicSong {
+	if song.Track == 0 {
+		return append(songs, song)
+	}
+	for i, existing := range songs {
+		if existing.Track == 0 {
+			continue
+		}
+		if discOf(song) < discOf(existing) || (discOf(song) == discOf(existing) && song.Track < existing.Track) {
+			return slices.Insert(songs, i, song)
+		}
+	}
+	return append(songs, song)
+}
+
+func discOf(song model.SubsonicSong) int {
+	if song.DiscNumber == 0 {
+		return 1
+	}
+	return song.DiscNumber
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+func TestInsertSongByPosition(t *testing.T) {
+	song := func(id string, disc, track int) model.SubsonicSong {
+		return model.SubsonicSong{ID: id, DiscNumber: disc, Track: track}
+	}
+	tests := []struct {
+		name  string
+		songs []model.SubsonicSong
+		song  model.SubsonicSong
+		want  []string
+	}{
+		{
+			name: "empty album",
+			song: song("a", 1, 1),
+			want: []string{"a"},
+		},
+		{
+			name:  "middle of a disc",
+			songs: []model.SubsonicSong{song("1", 1, 1), song("3", 1, 3)},
+			song:  song("2", 1, 2),
+			want:  []string{"1", "2", "3"},
+		},
+		{
+			name:  "end of the album",
+			songs: []model.SubsonicSong{song("1", 1, 1), song("2", 1, 2)},
+			song:  song("3", 1, 3),
+			want:  []string{"1", "2", "3"},
+		},
+		{
+			name:  "first track of the second disc",
+			songs: []model.SubsonicSong{song("1-1", 1, 1), song("1-2", 1, 2), song("2-2", 2, 2)},
+			song:  song("2-1", 2, 1),
+			want:  []string{"1-1", "1-2", "2-1", "2-2"},
+		},
+		{
+			name:  "missing disc number is disc one",
+			songs: []model.SubsonicSong{song("1", 0, 1), song("2-1", 2, 1)},
+			song:  song("2", 0, 2),
+			want:  []string{"1", "2", "2-1"},
+		},
+		{
+			name:  "unnumbered songs are skipped",
+			songs: []model.SubsonicSong{song("1", 1, 1), song("x", 1, 0), song("3", 1, 3)},
+			song:  song("2", 1, 2),
+			want:  []string{"1", "x", "2", "3"},
+		},
+		{
+			name:  "unnumbered song goes last",
+			songs: []model.SubsonicSong{song("1", 1, 1), song("2", 1, 2)},
+			song:  song("x", 1, 0),
+			want:  []string{"1", "2", "x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, s := range insertSongByPosition(tt.songs, tt.song) {
+				got = append(got, s.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
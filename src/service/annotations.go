@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
+)
+
+// annotationReplayInterval is how often pending annotations are checked against the library, on top of the
+// immediate replay when Navifetch imports the song itself.
+const annotationReplayInterval = 15 * time.Minute
+
+const (
+	AnnotationRating   = "rating"
+	AnnotationScrobble = "scrobble"
+)
+
+// PendingAnnotation is a rating or scrobble of an external song that isn't in Navidrome yet.
+type PendingAnnotation struct {
+	Kind   string `json:"kind"`
+	SongID string `json:"songId"`
+	Artist string `json:"artist"`
+	Title  string `json:"title"`
+	MBID   string `json:"mbid,omitempty"`
+	Rating int    `json:"rating,omitempty"`
+	// Time is the scrobble time in milliseconds since the epoch, kept so replayed scrobbles aren't dated
+	// to the moment of the import.
+	Time int64 `json:"time,omitempty"`
+	// Auth holds the Subsonic auth parameters of the client, so the annotation lands on the right user.
+	// Plain passwords are turned into a token before they are stored.
+	Auth     url.Values `json:"auth"`
+	QueuedAt time.Time  `json:"queuedAt"`
+}
+
+// AnnotationService records ratings and scrobbles of external songs and replays them onto Navidrome once
+// the songs are imported.
+type AnnotationService struct {
+	upstream NavidromeClient
+	metadata metadata.Provider
+	path     string
+
+	mu      sync.Mutex
+	pending []PendingAnnotation
+}
+
+func NewAnnotationService(cfg *config.Config, upstream NavidromeClient, metadata metadata.Provider) *AnnotationService {
+	s := &AnnotationService{
+		upstream: upstream,
+		metadata: metadata,
+		path:     filepath.Join(cfg.Server.DataPath, "annotations.json"),
+		pending:  make([]PendingAnnotation, 0),
+	}
+	if err := util.ReadJSONFile(s.path, &s.pending); err != nil {
+		slog.Error("Error reading pending annotations", "path", s.path, "err", err)
+	}
+	return s
+}
+
+// Start replays the queued annotations periodically until ctx is done.
+func (s *AnnotationService) Start(ctx context.Context) {
+	ticker := time.NewTicker(annotationReplayInterval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.Replay(ctx)
+			}
+		}
+	}()
+}
+
+// Resolve returns the Navidrome ID of an external song when it's already in the library, or "" otherwise.
+func (s *AnnotationService) Resolve(ctx context.Context, id string, authQuery url.Values) (string, error) {
+	a, err := s.describe(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	song, err := LookupNavidromeSong(ctx, s.upstream, a.Artist, a.Title, a.MBID, authQuery)
+	if err != nil || song == nil {
+		return "", err
+	}
+	return song.ID, nil
+}
+
+// ResolveOrQueue returns the Navidrome ID of the external song a refers to when it's already in the library.
+// Otherwise a is stored for replay and "" is returned.
+func (s *AnnotationService) ResolveOrQueue(ctx context.Context, a PendingAnnotation, authQuery url.Values) (string, error) {
+	described, err := s.describe(ctx, a.SongID)
+	if err != nil {
+		return "", err
+	}
+	song, err := LookupNavidromeSong(ctx, s.upstream, described.Artist, described.Title, described.MBID, authQuery)
+	if err == nil && song != nil {
+		return song.ID, nil
+	}
+
+	a.Artist = described.Artist
+	a.Title = described.Title
+	a.MBID = described.MBID
+	a.Auth = ClientAuthQuery(authQuery)
+	a.QueuedAt = time.Now()
+	if a.Kind == AnnotationScrobble && a.Time == 0 {
+		a.Time = a.QueuedAt.UnixMilli()
+	}
+
+	s.mu.Lock()
+	s.pending = append(s.pending, a)
+	s.mu.Unlock()
+	s.save()
+	slog.InfoContext(ctx, "Queued annotation until the song is imported", "kind", a.Kind, "artist", a.Artist, "title", a.Title)
+	return "", nil
+}
+
+// Imported replays the annotations queued for an external song that was just imported as navidromeID.
+func (s *AnnotationService) Imported(ctx context.Context, externalID string, navidromeID string) {
+	s.replayWhere(ctx, func(a PendingAnnotation) string {
+		if a.SongID == externalID {
+			return navidromeID
+		}
+		return ""
+	})
+}
+
+// Replay looks every song with pending annotations up in Navidrome and replays the annotations of those
+// that made it into the library, for songs imported by something other than Navifetch.
+func (s *AnnotationService) Replay(ctx context.Context) {
+	resolved := make(map[string]string)
+	s.replayWhere(ctx, func(a PendingAnnotation) string {
+		if id, ok := resolved[a.SongID]; ok {
+			return id
+		}
+		song, err := LookupNavidromeSong(ctx, s.upstream, a.Artist, a.Title, a.MBID, a.Auth)
+		if err != nil || song == nil {
+			resolved[a.SongID] = ""
+			return ""
+		}
+		resolved[a.SongID] = song.ID
+		return song.ID
+	})
+}
+
+// replayWhere sends every pending annotation for which resolve returns a Navidrome ID, keeping the rest and
+// those Navidrome rejected.
+func (s *AnnotationService) replayWhere(ctx context.Context, resolve func(PendingAnnotation) string) {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make([]PendingAnnotation, 0)
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	keep := make([]PendingAnnotation, 0, len(pending))
+	for _, a := range pending {
+		id := resolve(a)
+		if id == "" || !s.send(ctx, a, id) {
+			keep = append(keep, a)
+		}
+	}
+
+	s.mu.Lock()
+	s.pending = append(keep, s.pending...)
+	s.mu.Unlock()
+	if len(keep) != len(pending) {
+		s.save()
+	}
+}
+
+func (s *AnnotationService) send(ctx context.Context, a PendingAnnotation, navidromeID string) bool {
+	params := cloneQuery(a.Auth)
+	params.Set("id", navidromeID)
+	params.Set("f", "json")
+	path := "/rest/setRating"
+	switch a.Kind {
+	case AnnotationRating:
+		params.Set("rating", strconv.Itoa(a.Rating))
+	case AnnotationScrobble:
+		path = "/rest/scrobble"
+		params.Set("time", strconv.FormatInt(a.Time, 10))
+		params.Set("submission", "true")
+	default:
+		slog.WarnContext(ctx, "Dropping pending annotation of unknown kind", "kind", a.Kind)
+		return true
+	}
+
+	_, status, _, err := s.upstream.SendNavidromeRequest(ctx, path, params.Encode())
+	if err != nil || status != 200 {
+		slog.ErrorContext(ctx, "Failed to replay annotation", "kind", a.Kind, "artist", a.Artist, "title", a.Title, "status", status, "err", err)
+		return false
+	}
+	slog.InfoContext(ctx, "Replayed annotation", "kind", a.Kind, "artist", a.Artist, "title", a.Title, "id", navidromeID)
+	return true
+}
+
+// describe fills in the artist, title and MBID Navidrome will know an external song by.
+func (s *AnnotationService) describe(ctx context.Context, id string) (PendingAnnotation, error) {
+	trackID := strings.TrimPrefix(id, "external-")
+	song, err := s.metadata.GetSong(ctx, trackID)
+	if err != nil {
+		return PendingAnnotation{}, err
+	}
+	mbid := song.MusicBrainzId
+	if mbid == "" {
+		mbid = trackID
+	}
+	return PendingAnnotation{
+		SongID: id,
+		Artist: strings.TrimSuffix(song.Artist, " (external)"),
+		Title:  strings.TrimSuffix(song.Title, " (external)"),
+		MBID:   mbid,
+	}, nil
+}
+
+func (s *AnnotationService) save() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := util.WriteJSONFile(s.path, s.pending); err != nil {
+		slog.Error("Error saving pending annotations", "path", s.path, "err", err)
+	}
+}
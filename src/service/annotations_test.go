@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+func TestClientAuthQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantKeys  []string
+		wantToken bool
+	}{
+		{name: "token auth kept", query: "u=alice&t=abc&s=def&v=1.16.1&c=app&id=1&f=json", wantKeys: []string{"u", "t", "s", "v", "c"}},
+		{name: "api key kept", query: "apiKey=key&id=1", wantKeys: []string{"apiKey"}},
+		{name: "plain password", query: "u=alice&p=secret", wantKeys: []string{"u", "t", "s"}, wantToken: true},
+		{name: "hex password", query: "u=alice&p=enc:736563726574", wantKeys: []string{"u", "t", "s"}, wantToken: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			auth := ClientAuthQuery(query)
+			if len(auth) != len(tt.wantKeys) {
+				t.Errorf("got %v, want keys %v", auth, tt.wantKeys)
+			}
+			for _, key := range tt.wantKeys {
+				if auth.Get(key) == "" {
+					t.Errorf("missing %s in %v", key, auth)
+				}
+			}
+			if auth.Has("p") || strings.Contains(auth.Encode(), "secret") || strings.Contains(auth.Encode(), "736563726574") {
+				t.Errorf("password kept in %v", auth)
+			}
+			if tt.wantToken {
+				token, salt := auth.Get("t"), auth.Get("s")
+				if want := md5Hex("secret" + salt); token != want {
+					t.Errorf("got token %s, want %s", token, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAnnotationQueue(t *testing.T) {
+	song := &model.SubsonicSong{ID: "external-1", Artist: "Daft Punk", Title: "Digital Love (external)"}
+	tests := []struct {
+		name       string
+		library    []model.SubsonicSong
+		annotation PendingAnnotation
+		wantID     string
+		wantQueued int
+		wantSent   string
+	}{
+		{
+			name:       "song in library",
+			library:    []model.SubsonicSong{{ID: "nd-1", Artist: "daft punk", Title: "digital love"}},
+			annotation: PendingAnnotation{Kind: AnnotationRating, SongID: "external-1", Rating: 4},
+			wantID:     "nd-1",
+		},
+		{
+			name:       "rating queued and replayed",
+			annotation: PendingAnnotation{Kind: AnnotationRating, SongID: "external-1", Rating: 4},
+			wantQueued: 1,
+			wantSent:   "/rest/setRating?",
+		},
+		{
+			name:       "scrobble queued with its time",
+			annotation: PendingAnnotation{Kind: AnnotationScrobble, SongID: "external-1", Time: 1700000000000},
+			wantQueued: 1,
+			wantSent:   "/rest/scrobble?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Server.DataPath = t.TempDir()
+			navidrome := &fakeNavidrome{songs: tt.library}
+			provider := &fakeProvider{songs: map[string]*model.SubsonicSong{"1": song}}
+			s := NewAnnotationService(cfg, navidrome, provider)
+			auth := url.Values{"u": {"alice"}, "p": {"secret"}}
+
+			id, err := s.ResolveOrQueue(context.Background(), tt.annotation, auth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != tt.wantID || len(s.pending) != tt.wantQueued {
+				t.Fatalf("got ID %q with %d queued, want %q with %d", id, len(s.pending), tt.wantID, tt.wantQueued)
+			}
+			if tt.wantQueued == 0 {
+				return
+			}
+			queued := s.pending[0]
+			if queued.Title != "Digital Love" || queued.Auth.Has("p") || queued.Time == 0 && tt.annotation.Kind == AnnotationScrobble {
+				t.Errorf("got queued %+v", queued)
+			}
+
+			s.Imported(context.Background(), "external-1", "nd-1")
+			if len(s.pending) != 0 || len(navidrome.requests) != 1 {
+				t.Fatalf("got %d pending after import and requests %v", len(s.pending), navidrome.requests)
+			}
+			sent := navidrome.requests[0]
+			if !strings.HasPrefix(sent, tt.wantSent) || !strings.Contains(sent, "id=nd-1") {
+				t.Errorf("sent %s", sent)
+			}
+			if tt.annotation.Kind == AnnotationScrobble && !strings.Contains(sent, "time=1700000000000") {
+				t.Errorf("scrobble time lost in %s", sent)
+			}
+		})
+	}
+}
+
+func TestResolveOrQueueUnknownSong(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.DataPath = t.TempDir()
+	s := NewAnnotationService(cfg, &fakeNavidrome{}, &fakeProvider{})
+	_, err := s.ResolveOrQueue(context.Background(), PendingAnnotation{Kind: AnnotationRating, SongID: "external-404"}, url.Values{})
+	if err == nil || len(s.pending) != 0 {
+		t.Errorf("got %v with %d queued", err, len(s.pending))
+	}
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
+)
+
+type ArtistService struct {
+	upstream NavidromeClient
+	metadata metadata.Provider
+}
+
+func NewArtistService(upstream NavidromeClient, metadata metadata.Provider) *ArtistService {
+	return &ArtistService{
+		upstream: upstream,
+		metadata: metadata,
+	}
+}
+
+// GetArtist builds getArtist for an external artist, Navidrome answers it for local ones.
+func (s *ArtistService) GetArtist(ctx context.Context, artistID string) (*model.SubsonicArtistResponse, error) {
+	artist, err := s.metadata.GetArtist(ctx, strings.TrimPrefix(artistID, "external-"))
+	if err != nil {
+		return nil, err
+	}
+	sortAlbumsByReleaseType(artist.Album)
+
+	var resp model.SubsonicArtistResponse
+	resp.Subsonic.Envelope = model.OKEnvelope()
+	resp.Subsonic.Artist = artist
+	return &resp, nil
+}
+
+// InjectMissingAlbums appends the albums of a local artist the library lacks to a Navidrome getArtist JSON
+// response, keeping every field Navidrome sent untouched. Failed responses pass through as they are.
+func (s *ArtistService) InjectMissingAlbums(ctx context.Context, body []byte) ([]byte, error) {
+	var resp map[string]any
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	subsonic, ok := resp["subsonic-response"].(map[string]any)
+	if !ok || subsonic["status"] != "ok" {
+		return body, nil
+	}
+	artist, ok := subsonic["artist"].(map[string]any)
+	if !ok {
+		return body, nil
+	}
+	// The typed artist only serves the lookup and the comparison, the response keeps Navidrome's fields.
+	var local model.SubsonicArtist
+	raw, err := json.Marshal(artist)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &local); err != nil {
+		return nil, err
+	}
+
+	externalArtist, err := s.FindExternalArtist(ctx, &local)
+	if err != nil {
+		slog.InfoContext(ctx, "No external discography for artist", "artist", local.Name, "id", local.ID, "err", err)
+		return body, nil
+	}
+
+	missing := make([]model.SubsonicAlbum, 0)
+	for _, album := range externalArtist.Album {
+		if !util.IsAlbumInSubsonicAlbumList(album.Name, local.Album) {
+			missing = append(missing, album)
+		}
+	}
+	sortAlbumsByReleaseType(missing)
+	slog.DebugContext(ctx, "Artist enrichment complete", "artist", local.Name, "local_albums", len(local.Album), "external_albums", len(missing))
+	if len(missing) == 0 {
+		return body, nil
+	}
+
+	albums, _ := artist["album"].([]any)
+	for _, album := range missing {
+		albums = append(albums, album)
+	}
+	artist["album"] = albums
+	artist["albumCount"] = len(albums)
+	return json.Marshal(resp)
+}
+
+// GetArtistInfo builds getArtistInfo2 for an external artist, Navidrome answers it for local ones.
+func (s *ArtistService) GetArtistInfo(ctx context.Context, artistID string) (*model.SubsonicArtistInfoResponse, error) {
+	artist, err := s.metadata.GetArtist(ctx, strings.TrimPrefix(artistID, "external-"))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp model.SubsonicArtistInfoResponse
+	resp.Subsonic.Envelope = model.OKEnvelope()
+	resp.Subsonic.ArtistInfo2 = &model.SubsonicArtistInfo{
+		MusicBrainzId:  artist.MusicBrainzId,
+		SmallImageUrl:  artist.ArtistImageURL,
+		MediumImageUrl: artist.ArtistImageURL,
+		LargeImageUrl:  artist.ArtistImageURL,
+	}
+	return &resp, nil
+}
+
+// FindExternalArtist looks the local artist up by MBID when the provider understands them, then by an
+// exact name match.
+func (s *ArtistService) FindExternalArtist(ctx context.Context, local *model.SubsonicArtist) (*model.SubsonicArtist, error) {
+	if local.MusicBrainzId != "" && metadata.UsesMBIDs(s.metadata) {
+		artist, err := s.metadata.GetArtist(ctx, local.MusicBrainzId)
+		if err == nil {
+			return artist, nil
+		}
+	}
+
+	candidates, err := s.metadata.SearchArtists(ctx, local.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range candidates {
+		if strings.EqualFold(strings.TrimSpace(candidate.Name), strings.TrimSpace(local.Name)) {
+			return s.metadata.GetArtist(ctx, strings.TrimPrefix(candidate.ID, "external-"))
+		}
+	}
+	return nil, fmt.Errorf("no external artist named %q", local.Name)
+}
+
+// sortAlbumsByReleaseType groups albums, EPs and singles, oldest first within each group.
+func sortAlbumsByReleaseType(albums []model.SubsonicAlbum) {
+	rank := func(album model.SubsonicAlbum) int {
+		if len(album.ReleaseTypes) == 0 {
+			return 3
+		}
+		switch album.ReleaseTypes[0] {
+		case metadata.ReleaseTypeAlbum:
+			return 0
+		case metadata.ReleaseTypeEP:
+			return 1
+		case metadata.ReleaseTypeSingle:
+			return 2
+		default:
+			return 3
+		}
+	}
+	sort.SliceStable(albums, func(i, j int) bool {
+		if rank(albums[i]) != rank(albums[j]) {
+			return rank(albums[i]) < rank(albums[j])
+		}
+		return albums[i].Year < albums[j].Year
+	})
+}
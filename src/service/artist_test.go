@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+// fakeProvider answers artist and song lookups from fixed data, everything else is unsupported.
+type fakeProvider struct {
+	artists map[string]*model.SubsonicArtist
+	songs   map[string]*model.SubsonicSong
+	mbids   bool
+}
+
+func (f *fakeProvider) SearchSongs(context.Context, string) ([]model.SubsonicSong, error) {
+	return nil, metadata.ErrUnsupported
+}
+
+func (f *fakeProvider) SearchAlbums(context.Context, string) ([]model.SubsonicAlbum, error) {
+	return nil, metadata.ErrUnsupported
+}
+
+func (f *fakeProvider) SearchArtists(_ context.Context, query string) ([]model.SubsonicArtist, error) {
+	artists := make([]model.SubsonicArtist, 0)
+	for _, artist := range f.artists {
+		if artist.Name == query {
+			artists = append(artists, *artist)
+		}
+	}
+	return artists, nil
+}
+
+func (f *fakeProvider) GetAlbumSongs(context.Context, string) ([]model.SubsonicSong, error) {
+	return nil, metadata.ErrUnsupported
+}
+
+func (f *fakeProvider) GetSong(_ context.Context, id string) (*model.SubsonicSong, error) {
+	if song, ok := f.songs[id]; ok {
+		return song, nil
+	}
+	return nil, errors.New("song not found")
+}
+
+func (f *fakeProvider) GetAlbum(context.Context, string) (*model.SubsonicAlbum, error) {
+	return nil, metadata.ErrUnsupported
+}
+
+func (f *fakeProvider) GetArtist(_ context.Context, id string) (*model.SubsonicArtist, error) {
+	for key, artist := range f.artists {
+		if key == id || artist.ID == "external-"+id {
+			return artist, nil
+		}
+	}
+	return nil, errors.New("artist not found")
+}
+
+func (f *fakeProvider) GetCoverArt(context.Context, string, int64) ([]byte, string, error) {
+	return nil, "", metadata.ErrUnsupported
+}
+
+func (f *fakeProvider) UsesMBIDs() bool { return f.mbids }
+
+func TestSortAlbumsByReleaseType(t *testing.T) {
+	albums := []model.SubsonicAlbum{
+		{Name: "Single 2001", Year: 2001, ReleaseTypes: []string{metadata.ReleaseTypeSingle}},
+		{Name: "Untyped", Year: 1990},
+		{Name: "Album 2005", Year: 2005, ReleaseTypes: []string{metadata.ReleaseTypeAlbum}},
+		{Name: "EP 1999", Year: 1999, ReleaseTypes: []string{metadata.ReleaseTypeEP}},
+		{Name: "Album 1997", Year: 1997, ReleaseTypes: []string{metadata.ReleaseTypeAlbum, "Compilation"}},
+	}
+	sortAlbumsByReleaseType(albums)
+	var got []string
+	for _, album := range albums {
+		got = append(got, album.Name)
+	}
+	want := []string{"Album 1997", "Album 2005", "EP 1999", "Single 2001", "Untyped"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInjectMissingAlbums(t *testing.T) {
+	daftPunk := &model.SubsonicArtist{
+		ID:   "external-dp",
+		Name: "Daft Punk",
+		Album: []model.SubsonicAlbum{
+			{ID: "external-ram", Name: "Random Access Memories", Year: 2013, ReleaseTypes: []string{metadata.ReleaseTypeAlbum}},
+			{ID: "external-discovery", Name: "Discovery", Year: 2001, ReleaseTypes: []string{metadata.ReleaseTypeAlbum}},
+			{ID: "external-da-funk", Name: "Da Funk - Single", Year: 1995, ReleaseTypes: []string{metadata.ReleaseTypeSingle}},
+		},
+	}
+	tests := []struct {
+		name     string
+		provider *fakeProvider
+		body     string
+		// want lists the album names of the result, nil when the body must pass through untouched.
+		want []string
+	}{
+		{
+			name:     "missing albums appended",
+			provider: &fakeProvider{artists: map[string]*model.SubsonicArtist{"dp": daftPunk}},
+			body: `{"subsonic-response":{"status":"ok","version":"1.16.1","type":"navidrome","artist":{"id":"1","name":"Daft Punk",
+				"starred":"2024-01-01T00:00:00Z","albumCount":1,"album":[{"id":"a1","name":"Discovery","playCount":7}]}}}`,
+			want: []string{"Discovery", "Random Access Memories", "Da Funk - Single"},
+		},
+		{
+			name:     "found by MBID",
+			provider: &fakeProvider{artists: map[string]*model.SubsonicArtist{"mbid-dp": daftPunk}, mbids: true},
+			body:     `{"subsonic-response":{"status":"ok","artist":{"id":"1","name":"Daft Punk (duo)","musicBrainzId":"mbid-dp","album":[]}}}`,
+			want:     []string{"Discovery", "Random Access Memories", "Da Funk - Single"},
+		},
+		{
+			name:     "MBIDs ignored by other providers",
+			provider: &fakeProvider{artists: map[string]*model.SubsonicArtist{"mbid-dp": daftPunk}},
+			body:     `{"subsonic-response":{"status":"ok","artist":{"id":"1","name":"Daft Punk (duo)","musicBrainzId":"mbid-dp","album":[]}}}`,
+		},
+		{
+			name:     "nothing missing",
+			provider: &fakeProvider{artists: map[string]*model.SubsonicArtist{"dp": {ID: "external-dp", Name: "Daft Punk", Album: daftPunk.Album[1:2]}}},
+			body:     `{"subsonic-response":{"status":"ok","artist":{"id":"1","name":"Daft Punk","album":[{"id":"a1","name":"discovery"}]}}}`,
+		},
+		{
+			name:     "failed response",
+			provider: &fakeProvider{artists: map[string]*model.SubsonicArtist{"dp": daftPunk}},
+			body:     `{"subsonic-response":{"status":"failed","error":{"code":70,"message":"Artist not found"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewArtistService(nil, tt.provider)
+			got, err := s.InjectMissingAlbums(context.Background(), []byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if string(got) != tt.body {
+					t.Errorf("body changed to %s", got)
+				}
+				return
+			}
+
+			var resp struct {
+				Subsonic struct {
+					Status string `json:"status"`
+					Type   string `json:"type"`
+					Artist struct {
+						Starred    string `json:"starred"`
+						AlbumCount int    `json:"albumCount"`
+						Album      []struct {
+							Name      string `json:"name"`
+							PlayCount int    `json:"playCount"`
+						} `json:"album"`
+					} `json:"artist"`
+				} `json:"subsonic-response"`
+			}
+			if err := json.Unmarshal(got, &resp); err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, album := range resp.Subsonic.Artist.Album {
+				names = append(names, album.Name)
+			}
+			if !slices.Equal(names, tt.want) || resp.Subsonic.Artist.AlbumCount != len(tt.want) {
+				t.Errorf("got %d albums %v, want %v", resp.Subsonic.Artist.AlbumCount, names, tt.want)
+			}
+			if tt.name == "missing albums appended" {
+				// Navidrome's own fields are kept.
+				if resp.Subsonic.Type != "navidrome" || resp.Subsonic.Artist.Starred == "" || resp.Subsonic.Artist.Album[0].PlayCount != 7 {
+					t.Errorf("lost Navidrome fields: %s", got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/metrics"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
+)
+
+// ErrNotCached is returned for cache paths that don't name a track in the streaming-only cache.
+var ErrNotCached = errors.New("track is not in the cache")
+
+// ErrTrackNotFound is returned for external IDs Navifetch has no downloaded file for.
+var ErrTrackNotFound = errors.New("track was not downloaded")
+
+// CacheUsage describes the streaming-only cache, the tracks saved in MUSIC_LIBRARY_PATH/cached that the
+// cleanup job removes after CACHE_MAX_AGE.
+type CacheUsage struct {
+	Bytes  int64         `json:"bytes"`
+	Files  int           `json:"files"`
+	MaxAge string        `json:"maxAge"`
+	Tracks []CachedTrack `json:"tracks"`
+}
+
+// CachedTrack is a file in the cache. Path is relative to the cache folder, ExternalID and the names are
+// known for tracks in the TrackIndex.
+type CachedTrack struct {
+	Path       string    `json:"path"`
+	ExternalID string    `json:"externalId,omitempty"`
+	Artist     string    `json:"artist,omitempty"`
+	Album      string    `json:"album,omitempty"`
+	Title      string    `json:"title,omitempty"`
+	Bytes      int64     `json:"bytes"`
+	ModifiedAt time.Time `json:"modifiedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+func cacheDir(cfg *config.Config) string {
+	return filepath.Join(cfg.Downloader.MusicLibraryPath, "cached")
+}
+
+func downloadsDir(cfg *config.Config) string {
+	return filepath.Join(cfg.Downloader.MusicLibraryPath, "downloads")
+}
+
+// Cache lists the cached tracks, those expiring first at the top.
+func (s *StreamService) Cache() (*CacheUsage, error) {
+	cfg := s.cfg.Get()
+	root := cacheDir(cfg)
+	usage := &CacheUsage{MaxAge: cfg.Cache.MaxAge.String(), Tracks: make([]CachedTrack, 0)}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		usage.Bytes += info.Size()
+		usage.Files++
+		if strings.HasSuffix(path, ".lrc") {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		track := CachedTrack{
+			Path:       filepath.ToSlash(rel),
+			Bytes:      info.Size(),
+			ModifiedAt: info.ModTime(),
+			ExpiresAt:  info.ModTime().Add(cfg.Cache.MaxAge),
+		}
+		if indexed, ok := s.tracks.ByPath(path); ok {
+			track.ExternalID = indexed.ExternalID
+			track.Artist, track.Album, track.Title = indexed.Artist, indexed.Album, indexed.Title
+		}
+		usage.Tracks = append(usage.Tracks, track)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(usage.Tracks, func(i, j int) bool {
+		return usage.Tracks[i].ExpiresAt.Before(usage.Tracks[j].ExpiresAt)
+	})
+	return usage, nil
+}
+
+// cachedFile resolves a path relative to the cache folder, refusing paths that leave it.
+func cachedFile(cfg *config.Config, rel string) (string, error) {
+	rel = filepath.FromSlash(rel)
+	if rel == "" || !filepath.IsLocal(rel) {
+		return "", ErrNotCached
+	}
+	path := filepath.Join(cacheDir(cfg), rel)
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", ErrNotCached
+	}
+	return path, nil
+}
+
+// Evict removes a cached track and its lyrics before the cleanup job would.
+func (s *StreamService) Evict(rel string) error {
+	path, err := cachedFile(s.cfg.Get(), rel)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	_ = os.Remove(lyricsSidecarPath(path))
+	metrics.CacheEvictions.Inc()
+	measureCache(s.cfg.Get())
+	return nil
+}
+
+// Promote moves a cached track and its lyrics into the downloads folder, where the cleanup job leaves it
+// alone, and returns its new path. Navidrome only notices the move after a scan.
+func (s *StreamService) Promote(rel string) (string, error) {
+	cfg := s.cfg.Get()
+	path, err := cachedFile(cfg, rel)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(downloadsDir(cfg), filepath.FromSlash(rel))
+	if _, err := os.Stat(target); err == nil {
+		// Downloaded for good since, the cached copy is redundant.
+		if err := os.Remove(path); err != nil {
+			return "", err
+		}
+		s.tracks.Moved(path, target, true)
+		measureCache(cfg)
+		return target, nil
+	}
+	if err := s.promoteFile(path, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// promoteFile moves the cached track at path and its lyrics to target in the downloads folder, tags and
+// all, and updates the TrackIndex.
+func (s *StreamService) promoteFile(path string, target string) error {
+	if err := util.MoveFile(path, target); err != nil {
+		return err
+	}
+	if _, err := os.Stat(lyricsSidecarPath(path)); err == nil {
+		_ = util.MoveFile(lyricsSidecarPath(path), lyricsSidecarPath(target))
+	}
+	s.tracks.Moved(path, target, true)
+	measureCache(s.cfg.Get())
+	return nil
+}
+
+// Played counts a play of a track, promoting cached tracks played cache.promote_after_plays times within
+// cache.max_age.
+func (s *StreamService) Played(ctx context.Context, navidromeID string) {
+	cfg := s.cfg.Get()
+	if cfg.Cache.PromoteAfterPlays == 0 {
+		return
+	}
+	track, ok := s.tracks.Played(navidromeID, time.Now().Add(-cfg.Cache.MaxAge))
+	if !ok || len(track.Plays) < cfg.Cache.PromoteAfterPlays {
+		return
+	}
+	rel, err := filepath.Rel(cacheDir(cfg), track.Path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return
+	}
+	target, err := s.Promote(rel)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to promote a cached track", "path", track.Path, "err", err)
+		return
+	}
+	slog.InfoContext(ctx, "Promoted cached track after repeated plays", "plays", len(track.Plays), "path", target)
+	s.rescan(ctx)
+}
+
+// rescan asks Navidrome to pick up tracks Navifetch moved on its own, with the NAVIDROME_USER credentials.
+// Without them the move shows up at Navidrome's next scan.
+func (s *StreamService) rescan(ctx context.Context) {
+	cfg := s.cfg.Get()
+	if cfg.Auth.NavidromeUser == "" {
+		slog.DebugContext(ctx, "Not rescanning without NAVIDROME_USER")
+		return
+	}
+	if err := StartScan(ctx, s.upstream, ServiceAuthQuery(cfg)); err != nil {
+		slog.WarnContext(ctx, "Rescan after promotion failed", "err", err)
+	}
+}
+
+// PromoteTrack promotes the cached file downloaded for an external ID, like Promote.
+func (s *StreamService) PromoteTrack(externalID string) (string, error) {
+	track, ok := s.tracks.Get(externalID)
+	if !ok {
+		return "", ErrTrackNotFound
+	}
+	rel, err := filepath.Rel(cacheDir(s.cfg.Get()), track.Path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", ErrNotCached
+	}
+	return s.Promote(filepath.ToSlash(rel))
+}
+
+// DeleteTrack removes the file downloaded for an external ID, cached or kept for good, along with its
+// lyrics. Navidrome only forgets the track after a scan.
+func (s *StreamService) DeleteTrack(externalID string) (DownloadedTrack, error) {
+	track, ok := s.tracks.Get(externalID)
+	if !ok {
+		return DownloadedTrack{}, ErrTrackNotFound
+	}
+	if err := os.Remove(track.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return DownloadedTrack{}, err
+	}
+	_ = os.Remove(lyricsSidecarPath(track.Path))
+	s.tracks.Remove(externalID)
+	if !track.Permanent {
+		measureCache(s.cfg.Get())
+	}
+	return track, nil
+}
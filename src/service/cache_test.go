@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cacheTrack writes a cached track, with lyrics, and indexes it under externalID.
+func cacheTrack(t *testing.T, s *StreamService, rel string, externalID string) string {
+	t.Helper()
+	path := filepath.Join(cacheDir(s.cfg.Get()), filepath.FromSlash(rel))
+	writeFile(t, path, "cached")
+	writeFile(t, lyricsSidecarPath(path), "[00:01.00]lyrics")
+	s.tracks.Add(DownloadedTrack{ExternalID: externalID, NavidromeID: "nd-" + externalID, Path: path})
+	return path
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPromote(t *testing.T) {
+	tests := []struct {
+		name string
+		// downloaded is the content of a permanent copy already in the downloads folder, if any.
+		downloaded string
+		rel        string
+		wantErr    error
+		want       string
+	}{
+		{name: "moved", rel: "Daft Punk/Discovery/Digital Love.mp3", want: "cached"},
+		{name: "already downloaded", downloaded: "permanent", rel: "Daft Punk/Discovery/Digital Love.mp3", want: "permanent"},
+		{name: "not cached", rel: "Daft Punk/Discovery/Aerodynamic.mp3", wantErr: ErrNotCached},
+		{name: "outside the cache", rel: "../downloads/Daft Punk/Discovery/Digital Love.mp3", wantErr: ErrNotCached},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStreamService(t)
+			path := cacheTrack(t, s, "Daft Punk/Discovery/Digital Love.mp3", "1")
+			wantTarget := filepath.Join(downloadsDir(s.cfg.Get()), "Daft Punk", "Discovery", "Digital Love.mp3")
+			if tt.downloaded != "" {
+				writeFile(t, wantTarget, tt.downloaded)
+			}
+
+			target, err := s.Promote(tt.rel)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("cached track gone: %v", err)
+				}
+				return
+			}
+			if target != wantTarget {
+				t.Errorf("got target %s, want %s", target, wantTarget)
+			}
+			if data, _ := os.ReadFile(target); string(data) != tt.want {
+				t.Errorf("got %q at the target, want %q", data, tt.want)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("cached copy still there: %v", err)
+			}
+			track, _ := s.tracks.Get("1")
+			if track.Path != target || !track.Permanent || track.NavidromeID != "" {
+				t.Errorf("index not updated: %+v", track)
+			}
+		})
+	}
+}
+
+func TestPlayedPromotes(t *testing.T) {
+	tests := []struct {
+		name         string
+		promoteAfter int
+		plays        int
+		wantPromoted bool
+	}{
+		{name: "disabled", promoteAfter: 0, plays: 5},
+		{name: "not yet", promoteAfter: 3, plays: 2},
+		{name: "promoted", promoteAfter: 3, plays: 3, wantPromoted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStreamService(t)
+			s.cfg.Get().Cache.PromoteAfterPlays = tt.promoteAfter
+			cacheTrack(t, s, "Justice/Cross/D.A.N.C.E..mp3", "2")
+
+			for range tt.plays {
+				s.Played(context.Background(), "nd-2")
+			}
+			track, _ := s.tracks.Get("2")
+			if track.Permanent != tt.wantPromoted {
+				t.Errorf("got %+v", track)
+			}
+			if tt.wantPromoted {
+				if _, err := os.Stat(lyricsSidecarPath(track.Path)); err != nil {
+					t.Errorf("lyrics not moved along: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestPromoteTrack(t *testing.T) {
+	s := newTestStreamService(t)
+	cacheTrack(t, s, "Justice/Cross/Genesis.mp3", "3")
+	permanent := filepath.Join(downloadsDir(s.cfg.Get()), "Justice", "Cross", "Phantom.mp3")
+	writeFile(t, permanent, "permanent")
+	s.tracks.Add(DownloadedTrack{ExternalID: "4", Path: permanent, Permanent: true})
+
+	tests := []struct {
+		id      string
+		wantErr error
+	}{
+		{id: "3"},
+		{id: "4", wantErr: ErrNotCached},
+		{id: "5", wantErr: ErrTrackNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := s.PromoteTrack(tt.id); !errors.Is(err, tt.wantErr) {
+			t.Errorf("PromoteTrack(%s) = %v, want %v", tt.id, err, tt.wantErr)
+		}
+	}
+}
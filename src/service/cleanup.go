@@ -1,22 +1,29 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/metrics"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
 )
 
-func StartCleanupCron(cfg *config.Config) {
-	ticker := time.NewTicker(24 * time.Hour)
-
-	go func() {
-		for range ticker.C {
-			CleanupJob(cfg)
-		}
-	}()
+// StartCleanupCron removes expired streamed-only tracks every cache.cleanup_interval, following reloads,
+// until ctx is done.
+func StartCleanupCron(ctx context.Context, cfg *config.Holder, tracks *TrackIndex) {
+	measureCache(cfg.Get())
+	every(ctx, cfg, func(c *config.Config) time.Duration {
+		return c.Cache.CleanupInterval
+	}, func() {
+		CleanupJob(cfg.Get(), tracks)
+	})
 }
 
 func IsFolderEmpty(name string) (bool, error) {
@@ -33,11 +40,14 @@ func IsFolderEmpty(name string) (bool, error) {
 	return false, err
 }
 
-func CleanFile(path string) {
+// CleanFile removes the files under path older than maxAge and the empty folders, returning the files it
+// removed.
+func CleanFile(path string, maxAge time.Duration) []string {
+	var removed []string
 	files, err := os.ReadDir(path)
 	if err != nil {
-		slog.Error("Error reading dir: " + path)
-		return
+		slog.Error("Error reading dir", "path", path, "err", err)
+		return nil
 	}
 	for _, file := range files {
 		currFilePath := path + "/" + file.Name()
@@ -47,24 +57,94 @@ func CleanFile(path string) {
 				os.Remove(currFilePath)
 				continue
 			}
-			CleanFile(currFilePath)
+			removed = append(removed, CleanFile(currFilePath, maxAge)...)
 			continue
 		}
 		meta, err := file.Info()
 		if err != nil {
-			slog.Error("Error reading file info: " + currFilePath)
+			slog.Error("Error reading file info", "path", currFilePath, "err", err)
 			continue
 		}
-		if time.Now().Unix()-meta.ModTime().Unix() > 86400 {
-			os.Remove(currFilePath)
+		if time.Since(meta.ModTime()) > maxAge {
+			if os.Remove(currFilePath) == nil {
+				removed = append(removed, currFilePath)
+			}
 		}
 	}
+	return removed
 }
 
-func CleanupJob(cfg *config.Config) {
+// CleanupJob removes the cached tracks older than cache.max_age, along with their entries in tracks, and
+// stale staging folders, returning how many cached files it removed.
+func CleanupJob(cfg *config.Config, tracks *TrackIndex) int {
 	slog.Info("Running cleanup job")
 
-	CleanFile(cfg.MusicLibraryPath + "/" + "cached")
+	removed := CleanFile(cfg.Downloader.MusicLibraryPath+"/"+"cached", cfg.Cache.MaxAge)
+	tracks.RemovePaths(removed)
+	evicted := len(removed)
+	metrics.CacheEvictions.Add(float64(evicted))
+	// Downloads interrupted by a crash leave their staging folders behind.
+	for _, path := range staleStaging(cfg) {
+		if err := os.RemoveAll(path); err != nil {
+			slog.Error("Error removing staging folder", "path", path, "err", err)
+		}
+	}
+	measureCache(cfg)
+
+	slog.Info("Cleanup job finished", "evicted", evicted)
+	return evicted
+}
+
+// ExpiredFiles lists what CleanupJob would remove: cached tracks older than cache.max_age and the staging
+// folders interrupted downloads left behind.
+func ExpiredFiles(cfg *config.Config) []string {
+	var expired []string
+	_ = filepath.WalkDir(cacheDir(cfg), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > cfg.Cache.MaxAge {
+			expired = append(expired, path)
+		}
+		return nil
+	})
+	return append(expired, staleStaging(cfg)...)
+}
+
+// staleStaging lists the entries of the staging folder untouched for cache.max_age. Younger ones, even
+// empty, may belong to a download in progress.
+func staleStaging(cfg *config.Config) []string {
+	root := util.StagingDir(cfg)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("Error reading dir", "path", root, "err", err)
+		}
+		return nil
+	}
+	var stale []string
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > cfg.Cache.MaxAge {
+			stale = append(stale, filepath.Join(root, entry.Name()))
+		}
+	}
+	return stale
+}
 
-	slog.Info("Cleanup job finished.")
+// measureCache publishes the size of the streaming-only cache, which downloads then keep up to date.
+func measureCache(cfg *config.Config) {
+	var size int64
+	files := 0
+	_ = filepath.WalkDir(cacheDir(cfg), func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	metrics.CacheBytes.Set(float64(size))
+	metrics.CacheFiles.Set(float64(files))
 }
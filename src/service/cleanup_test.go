@@ -0,0 +1,101 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+)
+
+func TestStaleStaging(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.DataPath = t.TempDir()
+	cfg.Cache.MaxAge = time.Hour
+	staging := filepath.Join(cfg.Server.DataPath, "staging")
+
+	if got := staleStaging(cfg); got != nil {
+		t.Fatalf("got %v without a staging folder", got)
+	}
+
+	tests := []struct {
+		name  string
+		age   time.Duration
+		stale bool
+	}{
+		{name: "in progress", age: time.Minute},
+		{name: "empty but young", age: 0},
+		{name: "interrupted", age: 2 * time.Hour, stale: true},
+	}
+	var want []string
+	for _, tt := range tests {
+		path := filepath.Join(staging, tt.name)
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(-tt.age)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		if tt.stale {
+			want = append(want, path)
+		}
+	}
+	if got := staleStaging(cfg); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCleanupJob(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.DataPath = t.TempDir()
+	cfg.Downloader.MusicLibraryPath = t.TempDir()
+	cfg.Cache.MaxAge = time.Hour
+	tracks := NewTrackIndex(cfg)
+
+	old := time.Now().Add(-2 * time.Hour)
+	expired := filepath.Join(cacheDir(cfg), "Daft Punk", "Discovery", "Digital Love.mp3")
+	fresh := filepath.Join(cacheDir(cfg), "Justice", "Cross", "Genesis.mp3")
+	kept := filepath.Join(downloadsDir(cfg), "Justice", "Cross", "Phantom.mp3")
+	staged := filepath.Join(cfg.Server.DataPath, "staging", "interrupted")
+	for _, path := range []string{expired, fresh, kept, filepath.Join(staged, "track.mp3.part")} {
+		writeFile(t, path, "audio")
+	}
+	for _, path := range []string{expired, kept, staged} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tracks.Add(DownloadedTrack{ExternalID: "1", Path: expired})
+	tracks.Add(DownloadedTrack{ExternalID: "2", Path: fresh})
+
+	if got, want := ExpiredFiles(cfg), []string{expired, staged}; !slices.Equal(got, want) {
+		t.Errorf("ExpiredFiles = %v, want %v", got, want)
+	}
+	if evicted := CleanupJob(cfg, tracks); evicted != 1 {
+		t.Errorf("evicted %d files, want 1", evicted)
+	}
+
+	tests := []struct {
+		path   string
+		exists bool
+	}{
+		{path: expired},
+		{path: staged},
+		{path: fresh, exists: true},
+		{path: kept, exists: true},
+	}
+	for _, tt := range tests {
+		if _, err := os.Stat(tt.path); (err == nil) != tt.exists {
+			t.Errorf("%s: got %v, want it to exist: %v", tt.path, err, tt.exists)
+		}
+	}
+	if _, ok := tracks.Get("1"); ok {
+		t.Error("evicted track still indexed")
+	}
+	if _, ok := tracks.Get("2"); !ok {
+		t.Error("fresh track dropped from the index")
+	}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+// navifetchExtensions are the OpenSubsonic extensions Navifetch implements itself, on top of whatever
+// Navidrome supports. transcodeOffset holds for external songs too since their streams end up in Navidrome.
+var navifetchExtensions = []model.OpenSubsonicExtension{
+	{Name: "songLyrics", Versions: []int{1}},
+	{Name: "transcodeOffset", Versions: []int{1}},
+}
+
+// OpenSubsonicExtensions merges Navidrome's extension list with Navifetch's own. When Navidrome can't be
+// reached only Navifetch's extensions are advertised.
+func OpenSubsonicExtensions(ctx context.Context, upstream NavidromeClient, rawQuery string) *model.OpenSubsonicExtensionsResponse {
+	var upstreamResp model.OpenSubsonicExtensionsResponse
+	body, status, _, err := upstream.SendNavidromeRequest(ctx, "/rest/getOpenSubsonicExtensions", rawQuery)
+	if err == nil && status == 200 {
+		if err := json.Unmarshal(body, &upstreamResp); err != nil {
+			slog.ErrorContext(ctx, "Error decoding Navidrome OpenSubsonic extensions", "err", err)
+		}
+	} else {
+		slog.ErrorContext(ctx, "Error fetching Navidrome OpenSubsonic extensions", "status", status, "err", err)
+	}
+
+	var resp model.OpenSubsonicExtensionsResponse
+	resp.Subsonic.Envelope = model.OKEnvelope()
+	resp.Subsonic.OpenSubsonicExtensions = mergeExtensions(upstreamResp.Subsonic.OpenSubsonicExtensions, navifetchExtensions)
+	return &resp
+}
+
+func mergeExtensions(lists ...[]model.OpenSubsonicExtension) []model.OpenSubsonicExtension {
+	merged := make([]model.OpenSubsonicExtension, 0)
+	index := make(map[string]int)
+	for _, list := range lists {
+		for _, ext := range list {
+			i, ok := index[ext.Name]
+			if !ok {
+				index[ext.Name] = len(merged)
+				merged = append(merged, model.OpenSubsonicExtension{Name: ext.Name, Versions: slices.Clone(ext.Versions)})
+				continue
+			}
+			for _, v := range ext.Versions {
+				if !slices.Contains(merged[i].Versions, v) {
+					merged[i].Versions = append(merged[i].Versions, v)
+				}
+			}
+			slices.Sort(merged[i].Versions)
+		}
+	}
+	return merged
+}
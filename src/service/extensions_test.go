@@ -0,0 +1,49 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+func TestMergeExtensions(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream []model.OpenSubsonicExtension
+		want     []model.OpenSubsonicExtension
+	}{
+		{
+			name: "navidrome unreachable",
+			want: navifetchExtensions,
+		},
+		{
+			name: "union of both",
+			upstream: []model.OpenSubsonicExtension{
+				{Name: "formPost", Versions: []int{1}},
+				{Name: "songLyrics", Versions: []int{1}},
+			},
+			want: []model.OpenSubsonicExtension{
+				{Name: "formPost", Versions: []int{1}},
+				{Name: "songLyrics", Versions: []int{1}},
+				{Name: "transcodeOffset", Versions: []int{1}},
+			},
+		},
+		{
+			name:     "versions merged and sorted",
+			upstream: []model.OpenSubsonicExtension{{Name: "transcodeOffset", Versions: []int{3, 2}}},
+			want: []model.OpenSubsonicExtension{
+				{Name: "transcodeOffset", Versions: []int{1, 2, 3}},
+				{Name: "songLyrics", Versions: []int{1}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeExtensions(tt.upstream, navifetchExtensions)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
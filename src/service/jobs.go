@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+var (
+	ErrJobNotFound = errors.New("no such download job")
+	ErrJobRunning  = errors.New("download job is still running")
+	ErrJobFinished = errors.New("download job already finished")
+)
+
+// finishedJobs is how many finished downloads are kept for the admin UI, running ones are always kept.
+const finishedJobs = 100
+
+// DownloadJob is one run of StreamService.DownloadTrack, with the steps it went through in Log.
+type DownloadJob struct {
+	ID         string     `json:"id"`
+	TrackID    string     `json:"trackId"`
+	Artist     string     `json:"artist,omitempty"`
+	Album      string     `json:"album,omitempty"`
+	Title      string     `json:"title,omitempty"`
+	Permanent  bool       `json:"permanent"`
+	Path       string     `json:"path,omitempty"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Log        []string   `json:"log"`
+
+	// cancel stops the download while it's running.
+	cancel context.CancelFunc
+}
+
+// jobRegistry keeps the running downloads and the last finished ones in memory.
+type jobRegistry struct {
+	mu   sync.Mutex
+	next int
+	jobs []*DownloadJob
+}
+
+func (r *jobRegistry) start(trackID string, permanent bool, cancel context.CancelFunc) *DownloadJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	job := &DownloadJob{
+		ID:        strconv.Itoa(r.next),
+		TrackID:   "external-" + trackID,
+		Permanent: permanent,
+		Status:    JobRunning,
+		StartedAt: time.Now(),
+		Log:       make([]string, 0),
+		cancel:    cancel,
+	}
+	r.jobs = append(r.jobs, job)
+	return job
+}
+
+// update changes job under the registry's lock.
+func (r *jobRegistry) update(job *DownloadJob, fn func(job *DownloadJob)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(job)
+}
+
+func (r *jobRegistry) logf(job *DownloadJob, format string, args ...any) {
+	line := time.Now().Format("15:04:05") + " " + fmt.Sprintf(format, args...)
+	r.update(job, func(job *DownloadJob) {
+		job.Log = append(job.Log, line)
+	})
+}
+
+// finish marks job done, failed with err or cancelled, and forgets the oldest finished jobs beyond finishedJobs.
+func (r *jobRegistry) finish(job *DownloadJob, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	job.FinishedAt = &now
+	switch {
+	case errors.Is(err, ErrDownloadCancelled):
+		job.Status = JobCancelled
+	case err != nil:
+		job.Status = JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = JobDone
+	}
+
+	finished := 0
+	for _, j := range r.jobs {
+		if j.Status != JobRunning {
+			finished++
+		}
+	}
+	kept := r.jobs[:0]
+	for _, j := range r.jobs {
+		if j.Status != JobRunning && finished > finishedJobs {
+			finished--
+			continue
+		}
+		kept = append(kept, j)
+	}
+	r.jobs = kept
+}
+
+// get returns a copy of the job with the given ID.
+func (r *jobRegistry) get(id string) (DownloadJob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if job.ID == id {
+			copied := *job
+			copied.Log = append([]string(nil), job.Log...)
+			return copied, true
+		}
+	}
+	return DownloadJob{}, false
+}
+
+// cancelJob stops the running job with the given ID.
+func (r *jobRegistry) cancelJob(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if job.ID != id {
+			continue
+		}
+		if job.Status != JobRunning {
+			return ErrJobFinished
+		}
+		job.Log = append(job.Log, time.Now().Format("15:04:05")+" Cancelled through the API")
+		job.cancel()
+		return nil
+	}
+	return ErrJobNotFound
+}
+
+// list returns copies of the jobs, newest first.
+func (r *jobRegistry) list() []DownloadJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	jobs := make([]DownloadJob, 0, len(r.jobs))
+	for i := len(r.jobs) - 1; i >= 0; i-- {
+		job := *r.jobs[i]
+		job.Log = append([]string(nil), job.Log...)
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestJobRegistryFinish(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus string
+		wantError  string
+	}{
+		{name: "done", wantStatus: JobDone},
+		{name: "failed", err: errors.New("yt-dlp found nothing"), wantStatus: JobFailed, wantError: "yt-dlp found nothing"},
+		{name: "cancelled", err: ErrDownloadCancelled, wantStatus: JobCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r jobRegistry
+			job := r.start("1", true, func() {})
+			if job.TrackID != "external-1" || job.Status != JobRunning {
+				t.Fatalf("got %+v", job)
+			}
+			r.logf(job, "Downloading %s", "track")
+			r.finish(job, tt.err)
+
+			got, ok := r.get(job.ID)
+			if !ok || got.Status != tt.wantStatus || got.Error != tt.wantError || got.FinishedAt == nil || len(got.Log) != 1 {
+				t.Errorf("got %+v", got)
+			}
+		})
+	}
+}
+
+func TestJobRegistryKeepsRecentJobs(t *testing.T) {
+	var r jobRegistry
+	running := r.start("running", false, func() {})
+	for i := range finishedJobs + 5 {
+		r.finish(r.start(strconv.Itoa(i), false, func() {}), nil)
+	}
+
+	jobs := r.list()
+	if len(jobs) != finishedJobs+1 {
+		t.Fatalf("kept %d jobs, want %d", len(jobs), finishedJobs+1)
+	}
+	if jobs[0].TrackID != "external-"+strconv.Itoa(finishedJobs+4) {
+		t.Errorf("newest job is %s", jobs[0].TrackID)
+	}
+	if _, ok := r.get(running.ID); !ok {
+		t.Error("dropped the running job")
+	}
+	if _, ok := r.get("2"); ok {
+		t.Error("kept the oldest finished job")
+	}
+}
+
+func TestJobRegistryCancel(t *testing.T) {
+	var r jobRegistry
+	cancelled := false
+	running := r.start("1", false, func() { cancelled = true })
+	finished := r.start("2", false, func() {})
+	r.finish(finished, nil)
+
+	tests := []struct {
+		name string
+		id   string
+		want error
+	}{
+		{name: "running", id: running.ID},
+		{name: "finished", id: finished.ID, want: ErrJobFinished},
+		{name: "unknown", id: "9", want: ErrJobNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.cancelJob(tt.id); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if got, _ := r.get(running.ID); !cancelled || len(got.Log) != 1 {
+		t.Errorf("cancel func called: %v, log %v", cancelled, got.Log)
+	}
+}
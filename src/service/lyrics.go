@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/GerardPolloRebozado/navifetch/src/lyrics"
+	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+type LyricsService struct {
+	upstream NavidromeClient
+	metadata metadata.Provider
+	lyrics   lyrics.Provider
+}
+
+func NewLyricsService(upstream NavidromeClient, metadata metadata.Provider, lyrics lyrics.Provider) *LyricsService {
+	return &LyricsService{
+		upstream: upstream,
+		metadata: metadata,
+		lyrics:   lyrics,
+	}
+}
+
+// GetLyrics answers getLyrics with Navidrome's embedded lyrics when there are any, falling back to the
+// lyrics provider. authQuery holds the client's Subsonic auth parameters.
+func (s *LyricsService) GetLyrics(ctx context.Context, artist string, title string, authQuery url.Values) (*model.SubsonicLyricsResponse, error) {
+	var resp model.SubsonicLyricsResponse
+
+	params := cloneQuery(authQuery)
+	params.Set("artist", artist)
+	params.Set("title", title)
+	params.Set("f", "json")
+	body, _, _, err := s.upstream.SendNavidromeRequest(ctx, "/rest/getLyrics", params.Encode())
+	if err == nil && json.Unmarshal(body, &resp) == nil && resp.Subsonic.Lyrics != nil && resp.Subsonic.Lyrics.Value != "" {
+		return &resp, nil
+	}
+
+	resp.Subsonic.Envelope = model.OKEnvelope()
+	resp.Subsonic.Lyrics = &model.SubsonicLyrics{Artist: artist, Title: title}
+	if s.lyrics == nil || artist == "" || title == "" {
+		return &resp, nil
+	}
+
+	found, err := s.lyrics.GetLyrics(ctx, lyrics.Track{Artist: artist, Title: title})
+	if err != nil {
+		if !errors.Is(err, lyrics.ErrNotFound) {
+			return nil, err
+		}
+		return &resp, nil
+	}
+	resp.Subsonic.Lyrics.Value = found.PlainText()
+	return &resp, nil
+}
+
+// GetLyricsBySongID answers the OpenSubsonic getLyricsBySongId for local and external songs. Local songs
+// keep Navidrome's lyrics when the file has any.
+func (s *LyricsService) GetLyricsBySongID(ctx context.Context, id string, authQuery url.Values) (*model.SubsonicLyricsListResponse, error) {
+	var resp model.SubsonicLyricsListResponse
+
+	var track lyrics.Track
+	if strings.HasPrefix(id, "external-") {
+		song, err := s.metadata.GetSong(ctx, strings.TrimPrefix(id, "external-"))
+		if err != nil {
+			return nil, err
+		}
+		track = songTrack(song)
+	} else {
+		params := cloneQuery(authQuery)
+		params.Set("id", id)
+		params.Set("f", "json")
+		body, _, _, err := s.upstream.SendNavidromeRequest(ctx, "/rest/getLyricsBySongId", params.Encode())
+		if err == nil && json.Unmarshal(body, &resp) == nil && resp.Subsonic.LyricsList != nil && len(resp.Subsonic.LyricsList.StructuredLyrics) > 0 {
+			return &resp, nil
+		}
+
+		body, _, _, err = s.upstream.SendNavidromeRequest(ctx, "/rest/getSong", params.Encode())
+		if err != nil {
+			return nil, err
+		}
+		var songResponse model.SubsonicSongResponse
+		if err := json.Unmarshal(body, &songResponse); err != nil {
+			return nil, err
+		}
+		if songResponse.Subsonic.Song != nil {
+			track = songTrack(songResponse.Subsonic.Song)
+		}
+	}
+
+	resp.Subsonic.Envelope = model.OKEnvelope()
+	resp.Subsonic.LyricsList = &model.LyricsList{StructuredLyrics: make([]model.StructuredLyrics, 0)}
+	if s.lyrics == nil || track.Title == "" {
+		return &resp, nil
+	}
+
+	found, err := s.lyrics.GetLyrics(ctx, track)
+	if err != nil {
+		if !errors.Is(err, lyrics.ErrNotFound) {
+			return nil, err
+		}
+		return &resp, nil
+	}
+	if found.Plain != "" || found.Synced != "" {
+		resp.Subsonic.LyricsList.StructuredLyrics = append(resp.Subsonic.LyricsList.StructuredLyrics, found.Structured(track))
+	}
+	return &resp, nil
+}
+
+// WriteSidecar saves the synced lyrics of song as an .lrc file next to trackPath, or the plain ones when
+// there's no synced version. Missing lyrics are not an error.
+func WriteSidecar(ctx context.Context, provider lyrics.Provider, song *model.SubsonicSong, trackPath string) error {
+	found, err := provider.GetLyrics(ctx, songTrack(song))
+	if err != nil {
+		if errors.Is(err, lyrics.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	content := found.Synced
+	if content == "" {
+		content = found.Plain
+	}
+	if content == "" {
+		return nil
+	}
+
+	lrcPath := lyricsSidecarPath(trackPath)
+	if err := os.WriteFile(lrcPath, []byte(content), 0644); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Saved lyrics", "path", lrcPath)
+	return nil
+}
+
+// lyricsSidecarPath returns where WriteSidecar saves the lyrics of the track at trackPath.
+func lyricsSidecarPath(trackPath string) string {
+	return strings.TrimSuffix(trackPath, ".mp3") + ".lrc"
+}
+
+func songTrack(song *model.SubsonicSong) lyrics.Track {
+	return lyrics.Track{
+		Artist:   strings.TrimSuffix(song.Artist, " (external)"),
+		Title:    strings.TrimSuffix(song.Title, " (external)"),
+		Album:    strings.TrimSuffix(song.Album, " (external)"),
+		Duration: song.Duration,
+	}
+}
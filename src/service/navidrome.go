@@ -2,15 +2,22 @@ package service
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/logging"
+	"github.com/GerardPolloRebozado/navifetch/src/metrics"
 	"github.com/GerardPolloRebozado/navifetch/src/model"
 	"github.com/GerardPolloRebozado/navifetch/src/util"
 )
@@ -25,12 +32,7 @@ type NavidromeClient interface {
 	SearchNavidrome(ctx context.Context, path, rawQuery string) ([]model.SubsonicSong, string, error)
 }
 
-var subsonicReverseProxyInstance *SubsonicReverseProxy
-
 func NewSubsonicReverseProxy(base string) (*SubsonicReverseProxy, error) {
-	if subsonicReverseProxyInstance != nil {
-		return subsonicReverseProxyInstance, nil
-	}
 	target, err := url.Parse(base)
 	if err != nil {
 		return nil, err
@@ -49,6 +51,7 @@ func NewSubsonicReverseProxy(base string) (*SubsonicReverseProxy, error) {
 	}
 
 	proxy.ModifyResponse = func(resp *http.Response) error {
+		metrics.UpstreamRequests.Inc(metrics.SubsonicMethod(resp.Request.URL.Path), metrics.StatusClass(resp.StatusCode))
 		resp.Header.Del("Access-Control-Allow-Origin")
 		resp.Header.Del("Access-Control-Allow-Methods")
 		resp.Header.Del("Access-Control-Allow-Headers")
@@ -57,15 +60,100 @@ func NewSubsonicReverseProxy(base string) (*SubsonicReverseProxy, error) {
 	}
 
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		log.Printf("proxy error for %s: %v", r.URL.String(), err)
+		metrics.UpstreamRequests.Inc(metrics.SubsonicMethod(r.URL.Path), "error")
+		slog.ErrorContext(r.Context(), "Proxy error", "path", r.URL.Path, "err", err)
 		http.Error(w, "Upstream error", http.StatusBadGateway)
 	}
 
-	subsonicReverseProxyInstance = &SubsonicReverseProxy{
+	return &SubsonicReverseProxy{
 		base:  base,
 		proxy: proxy,
+	}, nil
+}
+
+// ServiceAuthQuery returns the Subsonic auth parameters Navifetch uses for requests that aren't made on
+// behalf of a client, such as background jobs.
+func ServiceAuthQuery(cfg *config.Config) url.Values {
+	token, salt := subsonicToken(cfg.Auth.NavidromePassword)
+	return url.Values{
+		"u": {cfg.Auth.NavidromeUser},
+		"t": {token},
+		"s": {salt},
+		"v": {model.APIVersion},
+		"c": {"navifetch"},
+		"f": {"json"},
+	}
+}
+
+// subsonicToken returns the Subsonic token auth pair for password with a fresh salt.
+func subsonicToken(password string) (token string, salt string) {
+	saltBytes := make([]byte, 8)
+	_, _ = rand.Read(saltBytes)
+	salt = hex.EncodeToString(saltBytes)
+	sum := md5.Sum([]byte(password + salt))
+	return hex.EncodeToString(sum[:]), salt
+}
+
+// ClientAuthQuery keeps only the Subsonic auth parameters of a client request, for requests made on the
+// client's behalf outside of it. A plain or hex encoded password is replaced with a salted token so it never
+// ends up on disk or in logs.
+func ClientAuthQuery(query url.Values) url.Values {
+	auth := url.Values{}
+	for _, key := range []string{"u", "t", "s", "apiKey", "v", "c"} {
+		if v := query.Get(key); v != "" {
+			auth.Set(key, v)
+		}
+	}
+
+	password := query.Get("p")
+	if password == "" {
+		return auth
+	}
+	if encoded, ok := strings.CutPrefix(password, "enc:"); ok {
+		if decoded, err := hex.DecodeString(encoded); err == nil {
+			password = string(decoded)
+		}
+	}
+	token, salt := subsonicToken(password)
+	auth.Set("t", token)
+	auth.Set("s", salt)
+	return auth
+}
+
+// CheckAuth pings Navidrome with authQuery, returning an error when the credentials are rejected.
+func CheckAuth(ctx context.Context, upstream NavidromeClient, authQuery url.Values) error {
+	params := cloneQuery(authQuery)
+	params.Set("f", "json")
+	body, status, _, err := upstream.SendNavidromeRequest(ctx, "/rest/ping", params.Encode())
+	if err != nil {
+		return err
+	}
+	return subsonicError(body, status)
+}
+
+// ErrNotAdmin is returned by CheckAdmin for valid credentials of a user without Navidrome's admin role.
+var ErrNotAdmin = errors.New("navidrome user is not an admin")
+
+// CheckAdmin checks that authQuery belongs to a Navidrome admin, for Navifetch's administrative endpoints.
+func CheckAdmin(ctx context.Context, upstream NavidromeClient, authQuery url.Values) error {
+	params := cloneQuery(authQuery)
+	params.Set("username", authQuery.Get("u"))
+	params.Set("f", "json")
+	body, status, _, err := upstream.SendNavidromeRequest(ctx, "/rest/getUser", params.Encode())
+	if err != nil {
+		return err
+	}
+	if err := subsonicError(body, status); err != nil {
+		return err
+	}
+	var resp model.SubsonicUserResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return err
+	}
+	if resp.Subsonic.User == nil || !resp.Subsonic.User.AdminRole {
+		return ErrNotAdmin
 	}
-	return subsonicReverseProxyInstance, nil
+	return nil
 }
 
 func (p *SubsonicReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -80,12 +168,18 @@ func (p *SubsonicReverseProxy) SendNavidromeRequest(ctx context.Context, path, r
 		urlBuilder.WriteString("?")
 		urlBuilder.WriteString(rawQuery)
 	}
-	log.Printf("URL for Navidrome request: %s", urlBuilder.String())
-	body, status, contentType, err := util.HTTPGet(ctx, urlBuilder.String(), nil)
+	slog.DebugContext(ctx, "Navidrome request", "url", urlBuilder.String())
+	var headers map[string]string
+	if id := logging.RequestID(ctx); id != "" {
+		headers = map[string]string{logging.RequestIDHeader: id}
+	}
+	body, status, contentType, err := util.HTTPGet(ctx, urlBuilder.String(), headers)
 	if err != nil {
-		log.Printf("Navidrome request error: %v", err)
+		metrics.UpstreamRequests.Inc(metrics.SubsonicMethod(path), "error")
+		slog.ErrorContext(ctx, "Navidrome request error", "path", path, "err", err)
 		return nil, 0, "", err
 	}
+	metrics.UpstreamRequests.Inc(metrics.SubsonicMethod(path), metrics.StatusClass(status))
 	return body, status, contentType, nil
 }
 
@@ -108,56 +202,110 @@ func (p *SubsonicReverseProxy) SearchNavidrome(ctx context.Context, path, rawQue
 	return nil, "", err
 }
 
+// LookupNavidromeSong searches Navidrome once for a song Navifetch downloaded, returning nil when it isn't in
+// the library yet. Unlike FindNavidromeSongID it neither waits for a scan nor falls back to a loose match.
+func LookupNavidromeSong(ctx context.Context, upstream NavidromeClient, artist string, title string, mbid string, authQuery url.Values) (*model.SubsonicSong, error) {
+	params := cloneQuery(authQuery)
+	params.Set("query", fmt.Sprintf("%s %s", artist, title))
+	params.Set("f", "json")
+	searchResult, _, err := upstream.SearchNavidrome(ctx, "/rest/search3.view", params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	return matchNavidromeSong(searchResult, artist, title, mbid), nil
+}
+
+// WaitForNavidromeSong looks a freshly downloaded song up until Navidrome's scanner has picked it up,
+// triggering a scan when it isn't there on the first attempt.
+func WaitForNavidromeSong(ctx context.Context, upstream NavidromeClient, artist string, title string, mbid string, authQuery url.Values) (*model.SubsonicSong, error) {
+	start := time.Now()
+	for i := 0; i < 15; i++ {
+		song, err := LookupNavidromeSong(ctx, upstream, artist, title, mbid, authQuery)
+		if err == nil && song != nil {
+			metrics.RescanWait.Since(start, "found")
+			return song, nil
+		}
+		if i == 0 {
+			_ = StartScan(ctx, upstream, authQuery)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+	metrics.RescanWait.Since(start, "not_found")
+	return nil, fmt.Errorf("song not found in Navidrome after download")
+}
+
+// StartScan asks Navidrome to scan the library for changes, needing the credentials of an admin.
+func StartScan(ctx context.Context, upstream NavidromeClient, authQuery url.Values) error {
+	params := cloneQuery(authQuery)
+	params.Set("f", "json")
+	body, status, _, err := upstream.SendNavidromeRequest(ctx, "/rest/startScan", params.Encode())
+	if err != nil {
+		return err
+	}
+	return subsonicError(body, status)
+}
+
+func matchNavidromeSong(songs []model.SubsonicSong, artist string, title string, mbid string) *model.SubsonicSong {
+	for _, song := range songs {
+		// 1. Try match by MBID if available
+		if mbid != "" && song.MusicBrainzId == mbid {
+			slog.Debug("Found exact match in Navidrome by MBID", "title", song.Title, "id", song.ID)
+			return &song
+		}
+		// 2. Try match by Artist and Title as fallback
+		if strings.EqualFold(song.Artist, artist) && strings.EqualFold(song.Title, title) {
+			slog.Debug("Found match in Navidrome by artist and title", "artist", song.Artist, "title", song.Title, "id", song.ID)
+			return &song
+		}
+	}
+	return nil
+}
+
 func (p *SubsonicReverseProxy) FindNavidromeSongID(artist string, title string, mbid string, r *http.Request) (*model.SubsonicSong, error) {
+	start := time.Now()
 	var foundSong *model.SubsonicSong
 	query := fmt.Sprintf("%s %s", artist, title)
 
 	// Use background context with timeout for Navidrome searches to avoid cancellation if a client disconnects
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(logging.WithRequestID(context.Background(), logging.RequestID(r.Context())), 60*time.Second)
 	defer cancel()
 
 	searchParams := r.URL.Query()
 	searchParams.Set("query", query)
 	searchRawQuery := searchParams.Encode()
 
-	log.Printf("Searching Navidrome for exact match: %s (MBID: %s)", query, mbid)
+	slog.InfoContext(ctx, "Searching Navidrome for exact match", "query", query, "mbid", mbid)
 
 	for i := 0; i < 15; i++ {
 		searchResult, _, err := p.SearchNavidrome(ctx, "/rest/search3.view", searchRawQuery)
 		if err == nil {
-			for _, song := range searchResult {
-				// 1. Try match by MBID if available
-				if mbid != "" && song.MusicBrainzId == mbid {
-					log.Printf("Found exact match in Navidrome by MBID: %s (ID: %s)", song.Title, song.ID)
-					foundSong = &song
-					break
-				}
-				// 2. Try match by Artist and Title as fallback
-				if strings.EqualFold(song.Artist, artist) && strings.EqualFold(song.Title, title) {
-					log.Printf("Found match in Navidrome by Artist/Title: %s - %s (ID: %s)", song.Artist, song.Title, song.ID)
-					foundSong = &song
-					break
-				}
-			}
+			foundSong = matchNavidromeSong(searchResult, artist, title, mbid)
 		}
 
 		if foundSong != nil {
+			metrics.RescanWait.Since(start, "found")
 			return foundSong, nil
 		}
 
 		if i < 14 {
-			log.Printf("Match not found yet, retrying in 2s... (attempt %d/15)", i+1)
+			slog.DebugContext(ctx, "Match not found yet, retrying in 2s", "attempt", i+1)
 			time.Sleep(2 * time.Second)
 			// Re-trigger scan
-			go p.SendNavidromeRequest(context.Background(), "/rest/startScan.view", r.URL.RawQuery)
+			go p.SendNavidromeRequest(logging.WithRequestID(context.Background(), logging.RequestID(ctx)), "/rest/startScan.view", r.URL.RawQuery)
 		}
 	}
 
-	log.Printf("Failed to find exact match, falling back to first search result for: %s", title)
+	slog.WarnContext(ctx, "Failed to find exact match, falling back to first search result", "title", title)
 	searchResult, _, err := p.SearchNavidrome(ctx, "/rest/search3.view", searchRawQuery)
 	if err == nil && len(searchResult) > 0 {
+		metrics.RescanWait.Since(start, "fallback")
 		return &searchResult[0], nil
 	}
 
+	metrics.RescanWait.Since(start, "not_found")
 	return nil, fmt.Errorf("song not found in Navidrome after download")
 }
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/playlist"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
+)
+
+// PlaylistExporter turns Navidrome playlists into portable playlist files with paths relative to the music
+// library, so another Navifetch instance or player with the same library layout can read them.
+type PlaylistExporter struct {
+	cfg      *config.Holder
+	upstream NavidromeClient
+}
+
+func NewPlaylistExporter(cfg *config.Holder, upstream NavidromeClient) *PlaylistExporter {
+	return &PlaylistExporter{
+		cfg:      cfg,
+		upstream: upstream,
+	}
+}
+
+// Export fetches the playlist with the given ID, or the given name among the playlists the user authQuery
+// belongs to can see.
+func (e *PlaylistExporter) Export(ctx context.Context, idOrName string, authQuery url.Values) (*playlist.Playlist, error) {
+	found, err := e.getPlaylist(ctx, idOrName, authQuery)
+	if err != nil {
+		id, lookupErr := e.findPlaylistID(ctx, idOrName, authQuery)
+		if lookupErr != nil || id == "" {
+			return nil, err
+		}
+		if found, err = e.getPlaylist(ctx, id, authQuery); err != nil {
+			return nil, err
+		}
+	}
+
+	pl := &playlist.Playlist{Name: found.Name, Entries: make([]playlist.Entry, 0, len(found.Entry))}
+	for _, song := range found.Entry {
+		pl.Entries = append(pl.Entries, e.exportEntry(song))
+	}
+	return pl, nil
+}
+
+func (e *PlaylistExporter) getPlaylist(ctx context.Context, id string, authQuery url.Values) (*model.SubsonicPlaylist, error) {
+	params := cloneQuery(authQuery)
+	params.Set("id", id)
+	params.Set("f", "json")
+	body, status, _, err := e.upstream.SendNavidromeRequest(ctx, "/rest/getPlaylist", params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if err := subsonicError(body, status); err != nil {
+		return nil, fmt.Errorf("playlist %s: %w", id, err)
+	}
+	var resp model.SubsonicPlaylistResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Subsonic.Playlist == nil {
+		return nil, fmt.Errorf("playlist %s not found", id)
+	}
+	return resp.Subsonic.Playlist, nil
+}
+
+func (e *PlaylistExporter) findPlaylistID(ctx context.Context, name string, authQuery url.Values) (string, error) {
+	params := cloneQuery(authQuery)
+	params.Set("f", "json")
+	body, status, _, err := e.upstream.SendNavidromeRequest(ctx, "/rest/getPlaylists", params.Encode())
+	if err != nil {
+		return "", err
+	}
+	if err := subsonicError(body, status); err != nil {
+		return "", err
+	}
+	var resp model.SubsonicPlaylistsResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	for _, candidate := range resp.Subsonic.Playlists.Playlist {
+		if strings.EqualFold(candidate.Name, name) {
+			return candidate.ID, nil
+		}
+	}
+	return "", nil
+}
+
+func (e *PlaylistExporter) exportEntry(song model.SubsonicSong) playlist.Entry {
+	artist := strings.TrimSuffix(song.Artist, " (external)")
+	album := strings.TrimSuffix(song.Album, " (external)")
+	title := strings.TrimSuffix(song.Title, " (external)")
+	entry := playlist.Entry{
+		Artist:   artist,
+		Title:    title,
+		Album:    album,
+		MBID:     song.MusicBrainzId,
+		Duration: song.Duration,
+	}
+	if len(song.ISRC) > 0 {
+		entry.ISRC = song.ISRC[0]
+	}
+
+	if strings.HasPrefix(song.ID, "external-") || song.Path == "" {
+		// Point external placeholders at where Navifetch saves the track once it's downloaded.
+		entry.Location = e.libraryPath(util.GetTrackPath(e.cfg.Get(), artist, album, title, true))
+	} else {
+		entry.Location = e.libraryPath(song.Path)
+	}
+	return entry
+}
+
+// libraryPath makes paths inside MusicLibraryPath relative to it. Navidrome already reports paths relative
+// to its library unless it's set to report real paths.
+func (e *PlaylistExporter) libraryPath(path string) string {
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(e.cfg.Get().Downloader.MusicLibraryPath, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(path)
+}
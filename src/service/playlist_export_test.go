@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/playlist"
+)
+
+func TestExportEntry(t *testing.T) {
+	cfg := config.Default()
+	cfg.Downloader.MusicLibraryPath = "/music"
+	e := NewPlaylistExporter(config.NewHolder(cfg), &fakeNavidrome{})
+
+	tests := []struct {
+		name string
+		song model.SubsonicSong
+		want playlist.Entry
+	}{
+		{
+			name: "library song",
+			song: model.SubsonicSong{ID: "nd-1", Artist: "Daft Punk", Title: "Digital Love", Album: "Discovery", Duration: 301,
+				Path: "Daft Punk/Discovery/03 Digital Love.flac", ISRC: []string{"GBDUW0000059"}},
+			want: playlist.Entry{Artist: "Daft Punk", Title: "Digital Love", Album: "Discovery", Duration: 301, ISRC: "GBDUW0000059",
+				Location: "Daft Punk/Discovery/03 Digital Love.flac"},
+		},
+		{
+			name: "real path inside the library",
+			song: model.SubsonicSong{ID: "nd-2", Title: "Digital Love", Path: "/music/downloads/Daft Punk/Discovery/Digital Love.mp3"},
+			want: playlist.Entry{Title: "Digital Love", Location: "downloads/Daft Punk/Discovery/Digital Love.mp3"},
+		},
+		{
+			name: "real path outside the library",
+			song: model.SubsonicSong{ID: "nd-3", Title: "Digital Love", Path: "/elsewhere/Digital Love.mp3"},
+			want: playlist.Entry{Title: "Digital Love", Location: "/elsewhere/Digital Love.mp3"},
+		},
+		{
+			name: "external placeholder",
+			song: model.SubsonicSong{ID: "external-1", Artist: "Daft Punk (external)", Title: "Digital Love (external)",
+				Album: "Discovery (external)", MusicBrainzId: "mbid"},
+			want: playlist.Entry{Artist: "Daft Punk", Title: "Digital Love", Album: "Discovery", MBID: "mbid",
+				Location: "downloads/Daft Punk/Discovery/Digital Love.mp3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.exportEntry(tt.song); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// playlistNavidrome serves getPlaylist for the playlists it knows by ID and lists them in getPlaylists.
+type playlistNavidrome struct {
+	fakeNavidrome
+	playlists []model.SubsonicPlaylist
+}
+
+func (f *playlistNavidrome) SendNavidromeRequest(ctx context.Context, path, rawQuery string) ([]byte, int, string, error) {
+	query, _ := url.ParseQuery(rawQuery)
+	switch path {
+	case "/rest/getPlaylist":
+		for _, pl := range f.playlists {
+			if pl.ID == query.Get("id") {
+				var resp model.SubsonicPlaylistResponse
+				resp.Subsonic.Envelope = model.OKEnvelope()
+				resp.Subsonic.Playlist = &pl
+				body, err := json.Marshal(resp)
+				return body, 200, "application/json", err
+			}
+		}
+		body, err := json.Marshal(model.ErrorResponse(70, "Playlist not found"))
+		return body, 200, "application/json", err
+	case "/rest/getPlaylists":
+		var resp model.SubsonicPlaylistsResponse
+		resp.Subsonic.Envelope = model.OKEnvelope()
+		resp.Subsonic.Playlists.Playlist = f.playlists
+		body, err := json.Marshal(resp)
+		return body, 200, "application/json", err
+	}
+	return f.fakeNavidrome.SendNavidromeRequest(ctx, path, rawQuery)
+}
+
+func TestExport(t *testing.T) {
+	navidrome := &playlistNavidrome{playlists: []model.SubsonicPlaylist{
+		{ID: "pl-1", Name: "Road Trip", Entry: []model.SubsonicSong{{ID: "nd-1", Title: "Digital Love", Path: "Digital Love.flac"}}},
+	}}
+	e := NewPlaylistExporter(config.NewHolder(config.Default()), navidrome)
+
+	tests := []struct {
+		idOrName string
+		wantErr  bool
+	}{
+		{idOrName: "pl-1"},
+		{idOrName: "road trip"},
+		{idOrName: "Night Drive", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.idOrName, func(t *testing.T) {
+			pl, err := e.Export(context.Background(), tt.idOrName, url.Values{})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want an error", pl)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pl.Name != "Road Trip" || len(pl.Entries) != 1 || pl.Entries[0].Location != "Digital Love.flac" {
+				t.Errorf("got %+v", pl)
+			}
+		})
+	}
+}
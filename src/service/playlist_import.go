@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/playlist"
+)
+
+// playlistChunkSize bounds the number of songs sent per createPlaylist/updatePlaylist call so the URL stays
+// within what Navidrome and proxies in front of it accept.
+const playlistChunkSize = 100
+
+// PlaylistImporter turns a parsed playlist file into a Navidrome playlist, downloading the tracks the
+// library doesn't have yet.
+type PlaylistImporter struct {
+	upstream NavidromeClient
+	metadata metadata.Provider
+	stream   *StreamService
+}
+
+// ImportReport describes the outcome of an import, Unmatched lists the entries left out of the playlist.
+type ImportReport struct {
+	Name       string           `json:"name"`
+	PlaylistID string           `json:"playlistId,omitempty"`
+	Total      int              `json:"total"`
+	Local      int              `json:"local"`
+	Downloaded int              `json:"downloaded"`
+	Unmatched  []UnmatchedEntry `json:"unmatched"`
+}
+
+type UnmatchedEntry struct {
+	Entry  playlist.Entry `json:"entry"`
+	Reason string         `json:"reason"`
+}
+
+func NewPlaylistImporter(upstream NavidromeClient, metadata metadata.Provider, stream *StreamService) *PlaylistImporter {
+	return &PlaylistImporter{
+		upstream: upstream,
+		metadata: metadata,
+		stream:   stream,
+	}
+}
+
+// Import matches every entry against Navidrome first and the metadata provider second, downloads what's
+// missing and creates the playlist as the user authQuery belongs to. Entries that can't be matched or
+// downloaded are reported instead of failing the import.
+func (i *PlaylistImporter) Import(ctx context.Context, pl *playlist.Playlist, authQuery url.Values) (*ImportReport, error) {
+	report := &ImportReport{
+		Name:      pl.Name,
+		Total:     len(pl.Entries),
+		Unmatched: make([]UnmatchedEntry, 0),
+	}
+	if report.Name == "" {
+		report.Name = "Imported " + time.Now().Format("2006-01-02 15:04")
+	}
+
+	songIDs := make([]string, 0, len(pl.Entries))
+	for n, entry := range pl.Entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		slog.InfoContext(ctx, "Importing playlist entry", "n", n+1, "total", len(pl.Entries), "artist", entry.Artist, "title", entry.Title)
+
+		local, err := LookupNavidromeSong(ctx, i.upstream, entry.Artist, entry.Title, entry.MBID, authQuery)
+		if err == nil && local != nil {
+			report.Local++
+			songIDs = append(songIDs, local.ID)
+			continue
+		}
+
+		id, reason := i.download(ctx, entry, authQuery)
+		if id == "" {
+			report.Unmatched = append(report.Unmatched, UnmatchedEntry{Entry: entry, Reason: reason})
+			continue
+		}
+		report.Downloaded++
+		songIDs = append(songIDs, id)
+	}
+
+	if len(songIDs) == 0 {
+		return report, nil
+	}
+	playlistID, err := i.createPlaylist(ctx, report.Name, songIDs, authQuery)
+	if err != nil {
+		return report, err
+	}
+	report.PlaylistID = playlistID
+	slog.InfoContext(ctx, "Imported playlist", "name", report.Name, "local", report.Local, "downloaded", report.Downloaded, "unmatched", len(report.Unmatched))
+	return report, nil
+}
+
+// download finds entry with the metadata provider, saves it permanently and waits for Navidrome to list it.
+// It returns the Navidrome ID, or "" and the reason the entry was skipped.
+func (i *PlaylistImporter) download(ctx context.Context, entry playlist.Entry, authQuery url.Values) (string, string) {
+	query := strings.TrimSpace(entry.Artist + " " + entry.Title)
+	results, err := i.metadata.SearchSongs(ctx, query)
+	if err != nil {
+		return "", fmt.Sprintf("metadata search failed: %v", err)
+	}
+	song := bestPlaylistMatch(entry, results)
+	if song == nil {
+		return "", "no match in Navidrome or the metadata provider"
+	}
+
+	trackID := strings.TrimPrefix(song.ID, "external-")
+	downloaded, _, err := i.stream.DownloadTrack(ctx, trackID, true)
+	if err != nil {
+		return "", fmt.Sprintf("download failed: %v", err)
+	}
+
+	artist := strings.TrimSuffix(downloaded.Artist, " (external)")
+	title := strings.TrimSuffix(downloaded.Title, " (external)")
+	mbid := downloaded.MusicBrainzId
+	if mbid == "" {
+		mbid = trackID
+	}
+	imported, err := WaitForNavidromeSong(ctx, i.upstream, artist, title, mbid, authQuery)
+	if err != nil {
+		return "", err.Error()
+	}
+	i.stream.Tracks().Link(song.ID, imported.ID)
+	return imported.ID, ""
+}
+
+// bestPlaylistMatch prefers an ISRC match, then a song with the same title by the same artist. Results
+// with a different title are never picked, importing the wrong song is worse than reporting it.
+func bestPlaylistMatch(entry playlist.Entry, results []model.SubsonicSong) *model.SubsonicSong {
+	if entry.ISRC != "" {
+		for n := range results {
+			if slices.Contains(results[n].ISRC, entry.ISRC) {
+				return &results[n]
+			}
+		}
+	}
+
+	var titleOnly *model.SubsonicSong
+	for n := range results {
+		title := strings.TrimSuffix(results[n].Title, " (external)")
+		if !strings.EqualFold(strings.TrimSpace(title), entry.Title) {
+			continue
+		}
+		if entry.Artist == "" || strings.Contains(strings.ToLower(results[n].Artist), strings.ToLower(entry.Artist)) {
+			return &results[n]
+		}
+		if titleOnly == nil {
+			titleOnly = &results[n]
+		}
+	}
+	if entry.Artist == "" {
+		return titleOnly
+	}
+	return nil
+}
+
+func (i *PlaylistImporter) createPlaylist(ctx context.Context, name string, songIDs []string, authQuery url.Values) (string, error) {
+	first := songIDs[:min(len(songIDs), playlistChunkSize)]
+	params := cloneQuery(authQuery)
+	params.Set("name", name)
+	params.Set("f", "json")
+	params["songId"] = first
+	body, err := i.sendPlaylistRequest(ctx, "/rest/createPlaylist", params)
+	if err != nil {
+		return "", err
+	}
+
+	var created struct {
+		Subsonic struct {
+			Playlist struct {
+				ID string `json:"id"`
+			} `json:"playlist"`
+		} `json:"subsonic-response"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil || created.Subsonic.Playlist.ID == "" {
+		return "", fmt.Errorf("createPlaylist returned no playlist ID")
+	}
+	playlistID := created.Subsonic.Playlist.ID
+
+	for start := len(first); start < len(songIDs); start += playlistChunkSize {
+		params := cloneQuery(authQuery)
+		params.Set("playlistId", playlistID)
+		params.Set("f", "json")
+		params["songIdToAdd"] = songIDs[start:min(len(songIDs), start+playlistChunkSize)]
+		if _, err := i.sendPlaylistRequest(ctx, "/rest/updatePlaylist", params); err != nil {
+			return playlistID, err
+		}
+	}
+	return playlistID, nil
+}
+
+func (i *PlaylistImporter) sendPlaylistRequest(ctx context.Context, path string, params url.Values) ([]byte, error) {
+	body, status, _, err := i.upstream.SendNavidromeRequest(ctx, path, params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if err := subsonicError(body, status); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return body, nil
+}
+
+// subsonicError turns a failed Subsonic response into an error.
+func subsonicError(body []byte, status int) error {
+	var resp struct {
+		Subsonic struct {
+			Status string `json:"status"`
+			Error  *struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"subsonic-response"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		if status != 200 {
+			return fmt.Errorf("status %d", status)
+		}
+		return err
+	}
+	if resp.Subsonic.Status == "ok" {
+		return nil
+	}
+	if resp.Subsonic.Error != nil {
+		return fmt.Errorf("subsonic error %d: %s", resp.Subsonic.Error.Code, resp.Subsonic.Error.Message)
+	}
+	return fmt.Errorf("subsonic status %q", resp.Subsonic.Status)
+}
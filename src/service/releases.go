@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
+)
+
+// ReleaseTracker walks the artists in Navidrome and remembers the external releases that appear after an
+// artist was first seen, so they can be offered as new releases.
+type ReleaseTracker struct {
+	cfg      *config.Holder
+	upstream NavidromeClient
+	artists  *ArtistService
+	path     string
+	running  atomic.Bool
+
+	mu    sync.RWMutex
+	state releaseTrackerState
+}
+
+type releaseTrackerState struct {
+	// Seen holds, per local artist ID, the IDs of the external releases already known. The first walk of an
+	// artist takes in the whole discography, later walks track the releases that weren't there before.
+	Seen     map[string][]string       `json:"seen"`
+	Releases map[string]TrackedRelease `json:"releases"`
+	LastRun  time.Time                 `json:"lastRun"`
+}
+
+type TrackedRelease struct {
+	Album         model.SubsonicAlbum `json:"album"`
+	LocalArtistID string              `json:"localArtistId"`
+	FoundAt       time.Time           `json:"foundAt"`
+}
+
+func NewReleaseTracker(cfg *config.Holder, upstream NavidromeClient, artists *ArtistService) *ReleaseTracker {
+	t := &ReleaseTracker{
+		cfg:      cfg,
+		upstream: upstream,
+		artists:  artists,
+		path:     filepath.Join(cfg.Get().Server.DataPath, "releases.json"),
+		state: releaseTrackerState{
+			Seen:     make(map[string][]string),
+			Releases: make(map[string]TrackedRelease),
+		},
+	}
+	if err := util.ReadJSONFile(t.path, &t.state); err != nil {
+		slog.Error("Error reading tracked releases", "path", t.path, "err", err)
+	}
+	if t.state.Seen == nil {
+		t.state.Seen = make(map[string][]string)
+	}
+	return t
+}
+
+// Start runs release tracking now and then every RELEASE_TRACKING_INTERVAL until ctx is done. It's paused
+// while the configuration lacks NAVIDROME_USER or a positive interval, and a reload that fixes that resumes it.
+func (t *ReleaseTracker) Start(ctx context.Context) {
+	if trackingInterval(t.cfg.Get()) <= 0 {
+		slog.Info("Release tracking disabled, it needs NAVIDROME_USER and a positive RELEASE_TRACKING_INTERVAL")
+	} else {
+		go t.Run(ctx)
+	}
+	every(ctx, t.cfg, trackingInterval, func() {
+		t.Run(ctx)
+	})
+}
+
+func trackingInterval(cfg *config.Config) time.Duration {
+	if cfg.Auth.NavidromeUser == "" {
+		return 0
+	}
+	return cfg.Releases.TrackingInterval
+}
+
+// Run walks every artist in Navidrome once. A call while a walk is in progress returns right away.
+func (t *ReleaseTracker) Run(ctx context.Context) {
+	if !t.running.CompareAndSwap(false, true) {
+		slog.InfoContext(ctx, "Release tracking is already running")
+		return
+	}
+	defer t.running.Store(false)
+
+	slog.InfoContext(ctx, "Running release tracking")
+	auth := ServiceAuthQuery(t.cfg.Get())
+	body, _, _, err := t.upstream.SendNavidromeRequest(ctx, "/rest/getArtists", auth.Encode())
+	if err != nil {
+		slog.ErrorContext(ctx, "Release tracking failed to list artists", "err", err)
+		return
+	}
+	var artistsResponse model.SubsonicArtistsResponse
+	if err := json.Unmarshal(body, &artistsResponse); err != nil {
+		slog.ErrorContext(ctx, "Release tracking failed to decode artists", "err", err)
+		return
+	}
+
+	found := 0
+	for _, index := range artistsResponse.Subsonic.Artists.Index {
+		for _, artist := range index.Artist {
+			found += t.trackArtist(ctx, artist.ID)
+			// Stay well within the rate limits of the metadata providers.
+			select {
+			case <-ctx.Done():
+				t.save()
+				slog.InfoContext(ctx, "Release tracking interrupted", "new_releases", found)
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}
+
+	t.mu.Lock()
+	t.state.LastRun = time.Now()
+	t.expire()
+	t.mu.Unlock()
+	t.save()
+	slog.InfoContext(ctx, "Release tracking finished", "new_releases", found)
+}
+
+// expire drops the releases found longer than RELEASE_MAX_AGE ago. Callers hold t.mu.
+func (t *ReleaseTracker) expire() {
+	maxAge := t.cfg.Get().Releases.MaxAge
+	if maxAge <= 0 {
+		return
+	}
+	for id, release := range t.state.Releases {
+		if time.Since(release.FoundAt) > maxAge {
+			delete(t.state.Releases, id)
+		}
+	}
+}
+
+func (t *ReleaseTracker) trackArtist(ctx context.Context, artistID string) int {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	params := ServiceAuthQuery(t.cfg.Get())
+	params.Set("id", artistID)
+	body, _, _, err := t.upstream.SendNavidromeRequest(ctx, "/rest/getArtist", params.Encode())
+	if err != nil {
+		slog.ErrorContext(ctx, "Release tracking failed to fetch artist", "id", artistID, "err", err)
+		return 0
+	}
+	var artistResponse model.SubsonicArtistResponse
+	if err := json.Unmarshal(body, &artistResponse); err != nil || artistResponse.Subsonic.Artist == nil {
+		return 0
+	}
+	local := artistResponse.Subsonic.Artist
+
+	t.mu.Lock()
+	// Drop tracked releases that have made it into the library since.
+	for id, release := range t.state.Releases {
+		if release.LocalArtistID == artistID && util.IsAlbumInSubsonicAlbumList(release.Album.Name, local.Album) {
+			delete(t.state.Releases, id)
+		}
+	}
+	t.mu.Unlock()
+
+	external, err := t.artists.FindExternalArtist(ctx, local)
+	if err != nil {
+		return 0
+	}
+
+	// Releases dated before RELEASE_MAX_AGE are catalogue additions rather than new releases.
+	oldestYear := 0
+	if maxAge := t.cfg.Get().Releases.MaxAge; maxAge > 0 {
+		oldestYear = time.Now().Add(-maxAge).Year()
+	}
+
+	found := 0
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seen, known := t.state.Seen[artistID]
+	for _, album := range external.Album {
+		if slices.Contains(seen, album.ID) {
+			continue
+		}
+		seen = append(seen, album.ID)
+		// The first walk takes in the discography.
+		if !known {
+			continue
+		}
+		if album.Year < oldestYear || util.IsAlbumInSubsonicAlbumList(album.Name, local.Album) {
+			continue
+		}
+		if _, ok := t.state.Releases[album.ID]; ok {
+			continue
+		}
+		slog.InfoContext(ctx, "New release found", "artist", local.Name, "album", album.Name, "year", album.Year)
+		t.state.Releases[album.ID] = TrackedRelease{
+			Album:         album,
+			LocalArtistID: artistID,
+			FoundAt:       time.Now(),
+		}
+		found++
+	}
+	t.state.Seen[artistID] = seen
+	return found
+}
+
+// Releases returns the tracked releases, newest first.
+func (t *ReleaseTracker) Releases() []model.SubsonicAlbum {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	maxAge := t.cfg.Get().Releases.MaxAge
+	albums := make([]model.SubsonicAlbum, 0, len(t.state.Releases))
+	for _, release := range t.state.Releases {
+		if maxAge > 0 && time.Since(release.FoundAt) > maxAge {
+			continue
+		}
+		albums = append(albums, release.Album)
+	}
+	sort.SliceStable(albums, func(i, j int) bool {
+		if albums[i].Year != albums[j].Year {
+			return albums[i].Year > albums[j].Year
+		}
+		return strings.ToLower(albums[i].Name) < strings.ToLower(albums[j].Name)
+	})
+	return albums
+}
+
+// NewestPage splits the page offset, size of getAlbumList2?type=newest between the tracked releases, which
+// come first, and Navidrome's own list. It returns the releases on the page and the offset and size to ask
+// Navidrome for the rest of it, a size of 0 meaning the page holds no local albums.
+func (t *ReleaseTracker) NewestPage(offset int, size int) ([]model.SubsonicAlbum, int, int) {
+	releases := t.Releases()
+	start := min(offset, len(releases))
+	end := min(offset+size, len(releases))
+	page := releases[start:end]
+	return page, max(0, offset-len(releases)), size - len(page)
+}
+
+// InjectNewest prepends releases to a Navidrome getAlbumList2 JSON response, keeping at most localCount of
+// its albums and every other field Navidrome sent untouched.
+func (t *ReleaseTracker) InjectNewest(body []byte, releases []model.SubsonicAlbum, localCount int) ([]byte, error) {
+	if len(releases) == 0 {
+		return body, nil
+	}
+
+	var resp map[string]any
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	subsonic, ok := resp["subsonic-response"].(map[string]any)
+	if !ok || subsonic["status"] != "ok" {
+		return body, nil
+	}
+	albumList, ok := subsonic["albumList2"].(map[string]any)
+	if !ok {
+		albumList = map[string]any{}
+		subsonic["albumList2"] = albumList
+	}
+	local, _ := albumList["album"].([]any)
+	local = local[:min(len(local), localCount)]
+
+	albums := make([]any, 0, len(releases)+len(local))
+	for _, release := range releases {
+		albums = append(albums, release)
+	}
+	albumList["album"] = append(albums, local...)
+	return json.Marshal(resp)
+}
+
+func (t *ReleaseTracker) save() {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if err := util.WriteJSONFile(t.path, t.state); err != nil {
+		slog.Error("Error saving tracked releases", "path", t.path, "err", err)
+	}
+}
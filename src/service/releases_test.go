@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+// fakeNavidrome answers getArtist with the albums in library, keyed by artist ID, searches with songs and
+// records every other request, which succeeds.
+type fakeNavidrome struct {
+	library  map[string][]model.SubsonicAlbum
+	songs    []model.SubsonicSong
+	requests []string
+}
+
+func (f *fakeNavidrome) SendNavidromeRequest(_ context.Context, path, rawQuery string) ([]byte, int, string, error) {
+	query, _ := url.ParseQuery(rawQuery)
+	if path != "/rest/getArtist" {
+		f.requests = append(f.requests, path+"?"+rawQuery)
+		body, err := json.Marshal(model.OKResponse())
+		return body, 200, "application/json", err
+	}
+	var resp model.SubsonicArtistResponse
+	resp.Subsonic.Envelope = model.OKEnvelope()
+	resp.Subsonic.Artist = &model.SubsonicArtist{ID: query.Get("id"), Name: "Daft Punk", Album: f.library[query.Get("id")]}
+	body, err := json.Marshal(resp)
+	return body, 200, "application/json", err
+}
+
+func (f *fakeNavidrome) SearchNavidrome(context.Context, string, string) ([]model.SubsonicSong, string, error) {
+	return f.songs, "application/json", nil
+}
+
+func newTestTracker(t *testing.T, navidrome NavidromeClient, provider *fakeProvider) *ReleaseTracker {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Server.DataPath = t.TempDir()
+	return NewReleaseTracker(config.NewHolder(cfg), navidrome, NewArtistService(navidrome, provider))
+}
+
+func TestTrackArtist(t *testing.T) {
+	year := time.Now().Year()
+	album := func(id string, year int) model.SubsonicAlbum {
+		return model.SubsonicAlbum{ID: "external-" + id, Name: id, Year: year}
+	}
+	discography := &model.SubsonicArtist{ID: "external-dp", Name: "Daft Punk"}
+	provider := &fakeProvider{artists: map[string]*model.SubsonicArtist{"dp": discography}}
+	navidrome := &fakeNavidrome{library: map[string][]model.SubsonicAlbum{}}
+	tracker := newTestTracker(t, navidrome, provider)
+
+	walks := []struct {
+		name     string
+		external []model.SubsonicAlbum
+		library  []model.SubsonicAlbum
+		want     []string
+	}{
+		{
+			name:     "first walk takes in the discography",
+			external: []model.SubsonicAlbum{album("Homework", 1997), album("Discovery", year)},
+			library:  []model.SubsonicAlbum{{Name: "Homework"}},
+		},
+		{
+			name:     "new release",
+			external: []model.SubsonicAlbum{album("Homework", 1997), album("Discovery", year), album("Alive", year)},
+			library:  []model.SubsonicAlbum{{Name: "Homework"}},
+			want:     []string{"Alive"},
+		},
+		{
+			name: "catalogue additions and albums in library are skipped",
+			external: []model.SubsonicAlbum{album("Homework", 1997), album("Discovery", year), album("Alive", year),
+				album("Daft Club", 2003), album("Human After All", year)},
+			library: []model.SubsonicAlbum{{Name: "Homework"}, {Name: "Human After All"}},
+			want:    []string{"Alive"},
+		},
+		{
+			name:     "releases that reach the library are dropped",
+			external: []model.SubsonicAlbum{album("Homework", 1997), album("Discovery", year), album("Alive", year)},
+			library:  []model.SubsonicAlbum{{Name: "Homework"}, {Name: "Alive"}},
+		},
+	}
+	for _, walk := range walks {
+		discography.Album = walk.external
+		navidrome.library["1"] = walk.library
+		tracker.trackArtist(context.Background(), "1")
+
+		var got []string
+		for _, release := range tracker.Releases() {
+			got = append(got, release.Name)
+		}
+		if !slices.Equal(got, walk.want) {
+			t.Errorf("%s: got %v, want %v", walk.name, got, walk.want)
+		}
+	}
+}
+
+func TestNewestPage(t *testing.T) {
+	tracker := newTestTracker(t, &fakeNavidrome{}, &fakeProvider{})
+	for i, name := range []string{"c", "b", "a"} {
+		tracker.state.Releases[name] = TrackedRelease{Album: model.SubsonicAlbum{Name: name, Year: 2020 + i}, FoundAt: time.Now()}
+	}
+	tracker.state.Releases["expired"] = TrackedRelease{Album: model.SubsonicAlbum{Name: "expired", Year: 2030}, FoundAt: time.Now().AddDate(-1, 0, 0)}
+
+	tests := []struct {
+		offset, size        int
+		want                []string
+		localOffset, locals int
+	}{
+		{0, 10, []string{"a", "b", "c"}, 0, 7},
+		{0, 2, []string{"a", "b"}, 0, 0},
+		{2, 2, []string{"c"}, 0, 1},
+		{3, 5, nil, 0, 5},
+		{10, 5, nil, 7, 5},
+	}
+	for _, tt := range tests {
+		page, localOffset, locals := tracker.NewestPage(tt.offset, tt.size)
+		var got []string
+		for _, album := range page {
+			got = append(got, album.Name)
+		}
+		if !slices.Equal(got, tt.want) || localOffset != tt.localOffset || locals != tt.locals {
+			t.Errorf("NewestPage(%d, %d) = %v, %d, %d, want %v, %d, %d",
+				tt.offset, tt.size, got, localOffset, locals, tt.want, tt.localOffset, tt.locals)
+		}
+	}
+}
+
+func TestInjectNewest(t *testing.T) {
+	tracker := newTestTracker(t, &fakeNavidrome{}, &fakeProvider{})
+	releases := []model.SubsonicAlbum{{ID: "external-new", Name: "new"}}
+	local := `{"subsonic-response":{"status":"ok","type":"navidrome","albumList2":{"album":[{"id":"1","name":"one","playCount":3},{"id":"2","name":"two"}]}}}`
+
+	tests := []struct {
+		name       string
+		body       string
+		releases   []model.SubsonicAlbum
+		localCount int
+		want       []string
+	}{
+		{name: "releases first", body: local, releases: releases, localCount: 2, want: []string{"new", "one", "two"}},
+		{name: "local albums trimmed", body: local, releases: releases, localCount: 1, want: []string{"new", "one"}},
+		{name: "no local albums", body: local, releases: releases, want: []string{"new"}},
+		{name: "empty list", body: `{"subsonic-response":{"status":"ok","albumList2":{}}}`, releases: releases, localCount: 5, want: []string{"new"}},
+		{name: "no releases", body: local, localCount: 2},
+		{name: "failed response", body: `{"subsonic-response":{"status":"failed"}}`, releases: releases},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tracker.InjectNewest([]byte(tt.body), tt.releases, tt.localCount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if string(got) != tt.body {
+					t.Errorf("body changed to %s", got)
+				}
+				return
+			}
+			var resp struct {
+				Subsonic struct {
+					Type       string `json:"type"`
+					AlbumList2 struct {
+						Album []struct {
+							Name string `json:"name"`
+						} `json:"album"`
+					} `json:"albumList2"`
+				} `json:"subsonic-response"`
+			}
+			if err := json.Unmarshal(got, &resp); err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, album := range resp.Subsonic.AlbumList2.Album {
+				names = append(names, album.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
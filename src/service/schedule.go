@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+)
+
+// every runs job in the background each interval, read from the current configuration, until ctx is done.
+// A reload restarts the wait with the new interval, and a non-positive interval pauses the job until a reload
+// enables it.
+func every(ctx context.Context, cfg *config.Holder, interval func(*config.Config) time.Duration, job func()) {
+	reloaded := make(chan struct{}, 1)
+	cfg.OnReload(func(_, _ *config.Config) {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	})
+
+	go func() {
+		for {
+			var tick <-chan time.Time
+			var timer *time.Timer
+			if d := interval(cfg.Get()); d > 0 {
+				timer = time.NewTimer(d)
+				tick = timer.C
+			}
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case <-tick:
+				job()
+			case <-reloaded:
+				if timer != nil {
+					timer.Stop()
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+)
+
+func TestEvery(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		wantRuns bool
+	}{
+		{name: "runs each interval", interval: time.Millisecond, wantRuns: true},
+		{name: "paused", interval: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			var runs atomic.Int32
+			every(ctx, config.NewHolder(config.Default()), func(*config.Config) time.Duration { return tt.interval }, func() {
+				runs.Add(1)
+			})
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+			// Let a run already past the select finish before counting.
+			time.Sleep(10 * time.Millisecond)
+			stopped := runs.Load()
+			if (stopped > 0) != tt.wantRuns {
+				t.Fatalf("ran %d times", stopped)
+			}
+			time.Sleep(20 * time.Millisecond)
+			if got := runs.Load(); got != stopped {
+				t.Errorf("ran %d more times after ctx was done", got-stopped)
+			}
+		})
+	}
+}
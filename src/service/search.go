@@ -3,14 +3,33 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"sync"
+	"time"
 
 	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+	"github.com/GerardPolloRebozado/navifetch/src/metrics"
 	"github.com/GerardPolloRebozado/navifetch/src/model"
 )
 
+// recentSearches is how many searches SearchService remembers for the admin UI.
+const recentSearches = 50
+
 type SearchService struct {
 	rp       *SubsonicReverseProxy
 	metadata metadata.Provider
+
+	mu     sync.Mutex
+	recent []RecentSearch
+}
+
+// RecentSearch is a search a client made, answered from the Navidrome library (local) or the metadata
+// provider (external).
+type RecentSearch struct {
+	Query   string    `json:"query"`
+	Source  string    `json:"source"`
+	Results int       `json:"results"`
+	Error   string    `json:"error,omitempty"`
+	At      time.Time `json:"at"`
 }
 
 func NewSearchService(rp *SubsonicReverseProxy, metadata metadata.Provider) *SearchService {
@@ -23,6 +42,8 @@ func NewSearchService(rp *SubsonicReverseProxy, metadata metadata.Provider) *Sea
 func (s *SearchService) SmartSearch(ctx context.Context, query string, path string, rawQuery string) ([]byte, string, error) {
 	body, contentType, err := s.rp.SearchNavidrome(ctx, path, rawQuery)
 	if err == nil && body != nil {
+		metrics.Searches.Inc("local")
+		s.remember(query, "local", len(body), nil)
 		bytes, err := json.Marshal(body)
 		if err != nil {
 			return nil, "", err
@@ -39,7 +60,9 @@ func (s *SearchService) SmartSearch(ctx context.Context, query string, path stri
 		return jsonBody, "application/json; charset=utf-8", nil
 	}
 
+	metrics.Searches.Inc("external")
 	songs, err := s.metadata.SearchSongs(ctx, query)
+	s.remember(query, "external", len(songs), err)
 	if err != nil {
 		return nil, "", err
 	}
@@ -53,18 +76,34 @@ func (s *SearchService) SmartSearch(ctx context.Context, query string, path stri
 	return jsonBody, "application/json; charset=utf-8", nil
 }
 
-func WrapExternalSearch(songs []model.SubsonicSong) map[string]any {
-	return map[string]any{
-		"subsonic-response": map[string]any{
-			"status":  "ok",
-			"version": "1.16.1",
-			"searchResult3": map[string]any{
-				"song": songs,
-			},
-		},
+func (s *SearchService) remember(query string, source string, results int, err error) {
+	search := RecentSearch{Query: query, Source: source, Results: results, At: time.Now()}
+	if err != nil {
+		search.Error = err.Error()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recent = append(s.recent, search)
+	if len(s.recent) > recentSearches {
+		s.recent = s.recent[len(s.recent)-recentSearches:]
 	}
 }
 
+// Recent returns the last searches, newest first.
+func (s *SearchService) Recent() []RecentSearch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recent := make([]RecentSearch, len(s.recent))
+	for i, search := range s.recent {
+		recent[len(s.recent)-1-i] = search
+	}
+	return recent
+}
+
+func WrapExternalSearch(songs []model.SubsonicSong) map[string]any {
+	return model.WrapResponse("searchResult3", map[string]any{"song": songs})
+}
+
 func ContentTypeOrJSON(ct string) string {
 	if ct != "" {
 		return ct
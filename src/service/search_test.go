@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestRecentSearches(t *testing.T) {
+	s := NewSearchService(nil, nil)
+	s.remember("daft punk", "local", 3, nil)
+	s.remember("justice", "external", 0, errors.New("provider down"))
+
+	recent := s.Recent()
+	if len(recent) != 2 {
+		t.Fatalf("got %+v", recent)
+	}
+	if recent[0].Query != "justice" || recent[0].Error != "provider down" || recent[1].Query != "daft punk" || recent[1].Results != 3 {
+		t.Errorf("got %+v", recent)
+	}
+
+	for i := range recentSearches {
+		s.remember(strconv.Itoa(i), "local", 1, nil)
+	}
+	recent = s.Recent()
+	if len(recent) != recentSearches || recent[len(recent)-1].Query != "0" {
+		t.Errorf("kept %d searches, oldest %q", len(recent), recent[len(recent)-1].Query)
+	}
+}
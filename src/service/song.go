@@ -2,7 +2,12 @@ package service
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/GerardPolloRebozado/navifetch/src/metadata"
 	"github.com/GerardPolloRebozado/navifetch/src/model"
@@ -27,8 +32,152 @@ func (s *SongService) GetSong(ctx context.Context, id string) (*model.SubsonicSo
 func (s *SongService) GetCoverArt(ctx context.Context, id string, size int64) ([]byte, string, error) {
 	image, contentType, err := s.metadata.GetCoverArt(ctx, id, size)
 	if err != nil {
-		log.Printf("Error fetching cover art: %v", err)
+		slog.ErrorContext(ctx, "Error fetching cover art", "id", id, "err", err)
 		return nil, "", err
 	}
 	return image, contentType, nil
 }
+
+// GetTopSongs returns the artist's most popular songs, using the local copy of each one Navidrome has.
+// authQuery holds the client's Subsonic auth parameters, used for the Navidrome lookups.
+func (s *SongService) GetTopSongs(ctx context.Context, artist string, count int, authQuery url.Values) ([]model.SubsonicSong, error) {
+	p, ok := s.metadata.(metadata.TopSongsProvider)
+	if !ok {
+		return nil, metadata.ErrUnsupported
+	}
+	songs, err := p.GetTopSongs(ctx, artist, count)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolveLocalSongs(ctx, songs, authQuery), nil
+}
+
+// GetSimilarSongs returns songs similar to id, which may be a local or external song or a local artist,
+// in which case the artist's top song is used as the seed.
+func (s *SongService) GetSimilarSongs(ctx context.Context, id string, count int, authQuery url.Values) ([]model.SubsonicSong, error) {
+	p, ok := s.metadata.(metadata.SimilarSongsProvider)
+	if !ok {
+		return nil, metadata.ErrUnsupported
+	}
+	artist, title, err := s.similarSeed(ctx, id, authQuery)
+	if err != nil {
+		return nil, err
+	}
+	slog.DebugContext(ctx, "Fetching similar songs", "artist", artist, "title", title)
+	songs, err := p.GetSimilarSongs(ctx, artist, title, count)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolveLocalSongs(ctx, songs, authQuery), nil
+}
+
+func (s *SongService) similarSeed(ctx context.Context, id string, authQuery url.Values) (string, string, error) {
+	if strings.HasPrefix(id, "external-") {
+		song, err := s.metadata.GetSong(ctx, strings.TrimPrefix(id, "external-"))
+		if err != nil {
+			return "", "", err
+		}
+		return song.Artist, strings.TrimSuffix(song.Title, " (external)"), nil
+	}
+
+	params := cloneQuery(authQuery)
+	params.Set("id", id)
+	params.Set("f", "json")
+	body, _, _, err := s.upstream.SendNavidromeRequest(ctx, "/rest/getSong", params.Encode())
+	if err != nil {
+		return "", "", err
+	}
+	var songResponse model.SubsonicSongResponse
+	if err := json.Unmarshal(body, &songResponse); err == nil && songResponse.Subsonic.Song != nil {
+		return songResponse.Subsonic.Song.Artist, songResponse.Subsonic.Song.Title, nil
+	}
+
+	// getSimilarSongs2 is called with an artist ID.
+	body, _, _, err = s.upstream.SendNavidromeRequest(ctx, "/rest/getArtist", params.Encode())
+	if err != nil {
+		return "", "", err
+	}
+	var artistResponse model.SubsonicArtistResponse
+	if err := json.Unmarshal(body, &artistResponse); err != nil || artistResponse.Subsonic.Artist == nil {
+		return "", "", fmt.Errorf("no song or artist with ID %s", id)
+	}
+	artist := artistResponse.Subsonic.Artist.Name
+	top, ok := s.metadata.(metadata.TopSongsProvider)
+	if !ok {
+		return "", "", metadata.ErrUnsupported
+	}
+	songs, err := top.GetTopSongs(ctx, artist, 1)
+	if err != nil {
+		return "", "", err
+	}
+	if len(songs) == 0 {
+		return "", "", fmt.Errorf("no songs found for artist %s", artist)
+	}
+	return artist, strings.TrimSuffix(songs[0].Title, " (external)"), nil
+}
+
+// resolveWorkers bounds how many Navidrome searches resolveLocalSongs runs at once.
+const resolveWorkers = 4
+
+// resolveLocalSongs swaps external songs for their Navidrome copy when there is one and drops
+// external songs that can't be streamed.
+func (s *SongService) resolveLocalSongs(ctx context.Context, songs []model.SubsonicSong, authQuery url.Values) []model.SubsonicSong {
+	resolved := make([]*model.SubsonicSong, len(songs))
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, resolveWorkers)
+	for i, song := range songs {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(idx int, song model.SubsonicSong) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			title := strings.TrimSuffix(song.Title, " (external)")
+			params := cloneQuery(authQuery)
+			params.Set("query", song.Artist+" "+title)
+			params.Set("songCount", "5")
+			params.Set("albumCount", "0")
+			params.Set("artistCount", "0")
+			params.Set("f", "json")
+			localSongs, _, err := s.upstream.SearchNavidrome(ctx, "/rest/search3.view", params.Encode())
+			if err == nil {
+				for _, local := range localSongs {
+					if strings.EqualFold(local.Artist, song.Artist) && strings.EqualFold(local.Title, title) {
+						resolved[idx] = &local
+						return
+					}
+				}
+			}
+			if song.ID != "external-" {
+				resolved[idx] = &song
+			}
+		}(i, song)
+	}
+	wg.Wait()
+
+	result := make([]model.SubsonicSong, 0, len(songs))
+	for _, song := range resolved {
+		if song != nil {
+			result = append(result, *song)
+		}
+	}
+	return result
+}
+
+// cloneQuery copies the client's query without the parameters of the endpoint it called.
+func cloneQuery(query url.Values) url.Values {
+	params := url.Values{}
+	for k, v := range query {
+		switch k {
+		case "id", "artist", "count", "query":
+			continue
+		}
+		params[k] = append([]string(nil), v...)
+	}
+	return params
+}
+
+func WrapSongList(element string, songs []model.SubsonicSong) map[string]any {
+	return model.WrapResponse(element, map[string]any{"song": songs})
+}
@@ -2,39 +2,191 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/logging"
+	"github.com/GerardPolloRebozado/navifetch/src/lyrics"
 	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+	"github.com/GerardPolloRebozado/navifetch/src/metrics"
 	"github.com/GerardPolloRebozado/navifetch/src/model"
 	"github.com/GerardPolloRebozado/navifetch/src/util"
 )
 
+// ErrShuttingDown is returned for downloads requested after Shutdown started.
+var ErrShuttingDown = errors.New("navifetch is shutting down")
+
+// ErrDownloadCancelled is returned for downloads cancelled through the admin API.
+var ErrDownloadCancelled = errors.New("download cancelled")
+
+// ytdlpStopDelay is how long yt-dlp gets to clean up after an interrupt before it's killed.
+const ytdlpStopDelay = 5 * time.Second
+
+// A download's duration may differ from the metadata by durationTolerance or durationToleranceRatio of the
+// expected length, whichever is larger, to allow for intros, outros and radio edits.
+const (
+	durationTolerance      = 15 * time.Second
+	durationToleranceRatio = 0.1
+)
+
 type StreamService struct {
-	cfg      *config.Config
+	cfg      *config.Holder
+	upstream NavidromeClient
 	metadata metadata.Provider
+	lyrics   lyrics.Provider
+
+	// ctx is cancelled when Shutdown gives up waiting, stopping the yt-dlp processes still running.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	closing   bool
+	downloads sync.WaitGroup
+
+	jobs   jobRegistry
+	tracks *TrackIndex
 }
 
-func NewStreamService(cfg *config.Config, metadata metadata.Provider) *StreamService {
+func NewStreamService(cfg *config.Holder, upstream NavidromeClient, metadata metadata.Provider, lyrics lyrics.Provider) *StreamService {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &StreamService{
 		cfg:      cfg,
+		upstream: upstream,
 		metadata: metadata,
+		lyrics:   lyrics,
+		ctx:      ctx,
+		cancel:   cancel,
+		tracks:   NewTrackIndex(cfg.Get()),
+	}
+}
+
+// Jobs returns the running downloads and the last finished ones, newest first.
+func (s *StreamService) Jobs() []DownloadJob {
+	return s.jobs.list()
+}
+
+// Tracks returns the index of the tracks Navifetch downloaded.
+func (s *StreamService) Tracks() *TrackIndex {
+	return s.tracks
+}
+
+// Shutdown stops accepting downloads and waits for the running ones until ctx is done. Downloads still
+// running then are interrupted and their partial files removed before Shutdown returns ctx's error.
+func (s *StreamService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.downloads.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		slog.Warn("Interrupting downloads still running")
+		s.cancel()
+		<-drained
+		return ctx.Err()
 	}
 }
 
-func (s *StreamService) DownloadTrack(trackID string, permanent bool) (*model.SubsonicSong, string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// DownloadTrack downloads a track into the library, or into the cache unless permanent. Downloads outlive the
+// request that started them and only stop at shutdown or when cancelled, so ctx only lends them its request
+// ID for logging.
+func (s *StreamService) DownloadTrack(ctx context.Context, trackID string, permanent bool) (*model.SubsonicSong, string, error) {
+	job, base, err := s.begin(ctx, trackID, permanent)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.download(base, job, trackID, permanent)
+}
+
+// StartDownload starts downloading a track in the background like DownloadTrack and returns its job.
+func (s *StreamService) StartDownload(ctx context.Context, trackID string, permanent bool) (DownloadJob, error) {
+	job, base, err := s.begin(ctx, trackID, permanent)
+	if err != nil {
+		return DownloadJob{}, err
+	}
+	started, _ := s.jobs.get(job.ID)
+	go func() {
+		_, _, _ = s.download(base, job, trackID, permanent)
+	}()
+	return started, nil
+}
+
+// Job returns the download job with the given ID.
+func (s *StreamService) Job(id string) (DownloadJob, bool) {
+	return s.jobs.get(id)
+}
+
+// CancelJob stops a running download, whose partial files are removed.
+func (s *StreamService) CancelJob(id string) error {
+	return s.jobs.cancelJob(id)
+}
+
+// RetryJob downloads the track of a finished job again, in the background.
+func (s *StreamService) RetryJob(ctx context.Context, id string) (DownloadJob, error) {
+	job, ok := s.jobs.get(id)
+	if !ok {
+		return DownloadJob{}, ErrJobNotFound
+	}
+	if job.Status == JobRunning {
+		return DownloadJob{}, ErrJobRunning
+	}
+	return s.StartDownload(ctx, strings.TrimPrefix(job.TrackID, "external-"), job.Permanent)
+}
+
+// begin registers a download unless Shutdown started, returning its job and the context it runs in.
+func (s *StreamService) begin(ctx context.Context, trackID string, permanent bool) (*DownloadJob, context.Context, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return nil, nil, ErrShuttingDown
+	}
+	s.downloads.Add(1)
+	base, cancel := context.WithCancel(logging.WithRequestID(s.ctx, logging.RequestID(ctx)))
+	return s.jobs.start(trackID, permanent, cancel), base, nil
+}
+
+func (s *StreamService) download(base context.Context, job *DownloadJob, trackID string, permanent bool) (res *model.SubsonicSong, targetPath string, err error) {
+	defer s.downloads.Done()
+	defer job.cancel()
+
+	start := time.Now()
+	result := "failed"
+	metrics.DownloadsInProgress.Add(1)
+	defer func() {
+		if err != nil && base.Err() != nil && s.ctx.Err() == nil {
+			err = ErrDownloadCancelled
+		}
+		metrics.DownloadsInProgress.Add(-1)
+		metrics.DownloadDuration.Since(start, result)
+		s.jobs.finish(job, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(base, 30*time.Second)
 	defer cancel()
 
-	res, err := s.metadata.GetSong(ctx, trackID)
+	s.jobs.logf(job, "Looking up %s", trackID)
+	res, err = s.metadata.GetSong(ctx, trackID)
 	if err != nil {
-		log.Printf("Background download failed lookup for %s: %v", trackID, err)
+		metrics.DownloadFailures.Inc("lookup")
+		s.jobs.logf(job, "Lookup failed: %v", err)
+		slog.ErrorContext(ctx, "Download failed to look the track up", "id", trackID, "err", err)
 		return nil, "", err
 	}
 
@@ -42,13 +194,57 @@ func (s *StreamService) DownloadTrack(trackID string, permanent bool) (*model.Su
 	album := strings.TrimSuffix(res.Album, "(external)")
 	title := strings.TrimSuffix(res.Title, "(external)")
 	coverURL := res.CoverArt
-	targetPath := util.GetTrackPath(s.cfg, artist, album, title, permanent)
+	targetPath = util.GetTrackPath(s.cfg.Get(), artist, album, title, permanent)
+	s.jobs.update(job, func(job *DownloadJob) {
+		job.Artist, job.Album, job.Title, job.Path = artist, album, title, targetPath
+	})
 	if _, err := os.Stat(targetPath); err == nil {
+		result = "existing"
+		s.jobs.logf(job, "Already in the library at %s", targetPath)
 		return res, targetPath, nil
 	}
+	if permanent {
+		// Streamed before, the cached copy only needs moving.
+		cachedPath := util.GetTrackPath(s.cfg.Get(), artist, album, title, false)
+		if _, err := os.Stat(cachedPath); err == nil {
+			if err := s.promoteFile(cachedPath, targetPath); err != nil {
+				slog.WarnContext(ctx, "Failed to promote the cached copy, downloading again", "path", cachedPath, "err", err)
+			} else {
+				result = "promoted"
+				if _, ok := s.tracks.ByPath(targetPath); !ok {
+					s.tracks.Add(DownloadedTrack{
+						ExternalID:   "external-" + trackID,
+						Artist:       artist,
+						Album:        album,
+						Title:        title,
+						Path:         targetPath,
+						Permanent:    true,
+						DownloadedAt: time.Now(),
+					})
+				}
+				slog.InfoContext(ctx, "Promoted cached track", "from", cachedPath, "path", targetPath)
+				s.jobs.logf(job, "Moved the cached copy from %s", cachedPath)
+				s.rescan(ctx)
+				return res, targetPath, nil
+			}
+		}
+	}
 
-	log.Printf("Saving permanent copy for Navidrome: %s", targetPath)
-	_ = os.MkdirAll(filepath.Dir(targetPath), 0755)
+	slog.InfoContext(ctx, "Downloading track", "path", targetPath, "permanent", permanent)
+	stagingRoot := util.StagingDir(s.cfg.Get())
+	if err := os.MkdirAll(stagingRoot, 0755); err != nil {
+		metrics.DownloadFailures.Inc("staging")
+		return nil, "", err
+	}
+	// Every download gets its own folder so concurrent downloads of the same track don't collide, and
+	// whatever yt-dlp leaves behind goes with it.
+	stagingDir, err := os.MkdirTemp(stagingRoot, "download-*")
+	if err != nil {
+		metrics.DownloadFailures.Inc("staging")
+		return nil, "", err
+	}
+	defer os.RemoveAll(stagingDir)
+	stagedPath := filepath.Join(stagingDir, filepath.Base(targetPath))
 
 	coverPath := ""
 	if coverURL != "" {
@@ -57,7 +253,7 @@ func (s *StreamService) DownloadTrack(trackID string, permanent bool) (*model.Su
 			defer os.Remove(tmpCover.Name())
 			defer tmpCover.Close()
 
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			ctx, cancel := context.WithTimeout(base, 10*time.Second)
 			defer cancel()
 			body, status, _, err := util.HTTPGet(ctx, coverURL, nil)
 			if err == nil && status == 200 {
@@ -83,7 +279,7 @@ func (s *StreamService) DownloadTrack(trackID string, permanent bool) (*model.Su
 	args := []string{
 		"-x", "--audio-format", "mp3",
 		"--postprocessor-args", ffmpegArgs,
-		"-o", targetPath,
+		"-o", stagedPath,
 		"--no-playlist",
 		"--add-metadata",
 		"--postprocessor-args",
@@ -97,13 +293,116 @@ func (s *StreamService) DownloadTrack(trackID string, permanent bool) (*model.Su
 
 	args = append(args, searchQuery)
 
-	cmd := exec.Command(s.cfg.YTDLPPath, args...)
+	s.jobs.logf(job, "Running yt-dlp for %q", searchQuery)
+	cmd := exec.CommandContext(base, s.cfg.Get().Downloader.YTDLPPath, args...)
+	// Interrupt rather than kill, so yt-dlp stops ffmpeg and removes its own temporary files.
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = ytdlpStopDelay
 
 	if output, err := cmd.CombinedOutput(); err != nil {
-		log.Printf("Failed to save permanent copy: %v\nOutput: %s", err, string(output))
+		if s.ctx.Err() != nil {
+			metrics.DownloadFailures.Inc("shutdown")
+			slog.WarnContext(ctx, "Download interrupted by shutdown", "path", targetPath)
+			s.jobs.logf(job, "Interrupted by shutdown")
+			return nil, "", ErrShuttingDown
+		}
+		if base.Err() != nil {
+			metrics.DownloadFailures.Inc("cancelled")
+			slog.InfoContext(ctx, "Download cancelled", "path", targetPath)
+			return nil, "", ErrDownloadCancelled
+		}
+		metrics.DownloadFailures.Inc("ytdlp")
+		slog.ErrorContext(ctx, "yt-dlp failed", "path", targetPath, "err", err, "output", string(output))
+		s.jobs.logf(job, "yt-dlp failed: %v\n%s", err, tail(string(output), 20))
 		return nil, "", err
 	}
 
-	log.Printf("Successfully saved: %s", targetPath)
+	if err := s.verifyDownload(stagedPath, res.Duration); err != nil {
+		metrics.DownloadFailures.Inc("verify")
+		slog.WarnContext(ctx, "Discarding download", "path", targetPath, "err", err)
+		s.jobs.logf(job, "Discarded: %v", err)
+		return nil, "", err
+	}
+	if err := util.MoveFile(stagedPath, targetPath); err != nil {
+		metrics.DownloadFailures.Inc("move")
+		slog.ErrorContext(ctx, "Failed to move download into the library", "path", targetPath, "err", err)
+		s.jobs.logf(job, "Moving into the library failed: %v", err)
+		return nil, "", err
+	}
+
+	slog.InfoContext(ctx, "Download complete", "path", targetPath)
+	s.jobs.logf(job, "Saved to %s", targetPath)
+	result = "ok"
+	s.tracks.Add(DownloadedTrack{
+		ExternalID:   "external-" + trackID,
+		Artist:       artist,
+		Album:        album,
+		Title:        title,
+		Path:         targetPath,
+		Permanent:    permanent,
+		DownloadedAt: time.Now(),
+	})
+	if !permanent {
+		if info, err := os.Stat(targetPath); err == nil {
+			metrics.CacheBytes.Add(float64(info.Size()))
+			metrics.CacheFiles.Add(1)
+		}
+	}
+
+	if s.cfg.Get().Downloader.LyricsSidecar && s.lyrics != nil {
+		ctx, cancel := context.WithTimeout(base, 10*time.Second)
+		defer cancel()
+		if err := WriteSidecar(ctx, s.lyrics, res, targetPath); err != nil {
+			slog.WarnContext(ctx, "Failed to save lyrics", "path", targetPath, "err", err)
+			s.jobs.logf(job, "Saving lyrics failed: %v", err)
+		}
+	}
 	return res, targetPath, nil
 }
+
+// tail returns the last n lines of output.
+func tail(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// verifyDownload checks that path is a non-empty audio file whose duration is close to the expected one,
+// in seconds, when that is known. A different length usually means yt-dlp picked the wrong video.
+func (s *StreamService) verifyDownload(path string, expected int64) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("yt-dlp produced no file: %w", err)
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("yt-dlp produced an empty file")
+	}
+
+	ffprobe, err := exec.LookPath(s.cfg.Get().Downloader.FFprobePath)
+	if err != nil {
+		// Without ffprobe only the size can be checked.
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, ffprobe, "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", path).Output()
+	if err != nil {
+		return fmt.Errorf("ffprobe can't decode it: %w", err)
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || duration <= 0 {
+		return fmt.Errorf("ffprobe found no duration in %q", strings.TrimSpace(string(out)))
+	}
+	if expected > 0 {
+		tolerance := max(durationTolerance.Seconds(), float64(expected)*durationToleranceRatio)
+		if math.Abs(duration-float64(expected)) > tolerance {
+			return fmt.Errorf("duration is %.0fs, expected %ds", duration, expected)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+)
+
+func newTestStreamService(t *testing.T) *StreamService {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Server.DataPath = t.TempDir()
+	cfg.Downloader.MusicLibraryPath = t.TempDir()
+	cfg.Downloader.FFprobePath = filepath.Join(cfg.Server.DataPath, "no-ffprobe")
+	return NewStreamService(config.NewHolder(cfg), &fakeNavidrome{}, &fakeProvider{}, nil)
+}
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		downloading bool
+		timeout     time.Duration
+		wantErr     error
+	}{
+		{name: "idle", timeout: time.Second},
+		{name: "download drained", downloading: true, timeout: time.Second},
+		{name: "download interrupted", downloading: true, wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStreamService(t)
+			interrupted := make(chan bool, 1)
+			if tt.downloading {
+				_, base, err := s.begin(context.Background(), "1", false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// The download finishes on its own unless Shutdown interrupts it first.
+				go func() {
+					defer s.downloads.Done()
+					select {
+					case <-base.Done():
+						interrupted <- true
+					case <-time.After(20 * time.Millisecond):
+						interrupted <- false
+					}
+				}()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			if err := s.Shutdown(ctx); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.downloading {
+				if got := <-interrupted; got != (tt.wantErr != nil) {
+					t.Errorf("download interrupted: %v", got)
+				}
+			}
+			if _, _, err := s.DownloadTrack(context.Background(), "2", false); !errors.Is(err, ErrShuttingDown) {
+				t.Errorf("got %v for a download after shutdown", err)
+			}
+		})
+	}
+}
+
+func TestVerifyDownload(t *testing.T) {
+	s := newTestStreamService(t)
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.mp3")
+	audio := filepath.Join(dir, "audio.mp3")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(audio, []byte("audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "missing", path: filepath.Join(dir, "missing.mp3"), wantErr: true},
+		{name: "empty", path: empty, wantErr: true},
+		// Without ffprobe only the size is checked.
+		{name: "not empty", path: audio},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.verifyDownload(tt.path, 301); (err != nil) != tt.wantErr {
+				t.Errorf("got %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
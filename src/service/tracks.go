@@ -0,0 +1,204 @@
+package service
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
+)
+
+// DownloadedTrack maps an external song to the file Navifetch saved it to and, once Navidrome has indexed
+// that file, to its Navidrome ID.
+type DownloadedTrack struct {
+	ExternalID   string    `json:"externalId"`
+	NavidromeID  string    `json:"navidromeId,omitempty"`
+	Artist       string    `json:"artist"`
+	Album        string    `json:"album"`
+	Title        string    `json:"title"`
+	Path         string    `json:"path"`
+	Permanent    bool      `json:"permanent"`
+	DownloadedAt time.Time `json:"downloadedAt"`
+	// Plays are when a cached track was played, within the cache's max age.
+	Plays []time.Time `json:"plays,omitempty"`
+}
+
+// TrackIndex remembers every track Navifetch downloaded, kept in DATA_PATH/tracks.json. The cleanup job drops
+// the cached tracks it removes, other tracks whose file is gone are dropped the next time the index is listed.
+type TrackIndex struct {
+	path string
+
+	mu     sync.Mutex
+	tracks map[string]*DownloadedTrack
+}
+
+func NewTrackIndex(cfg *config.Config) *TrackIndex {
+	t := &TrackIndex{
+		path:   filepath.Join(cfg.Server.DataPath, "tracks.json"),
+		tracks: make(map[string]*DownloadedTrack),
+	}
+	if err := util.ReadJSONFile(t.path, &t.tracks); err != nil {
+		slog.Error("Error reading track index", "path", t.path, "err", err)
+	}
+	return t
+}
+
+// Add records a downloaded track, replacing what was known about the same external ID.
+func (t *TrackIndex) Add(track DownloadedTrack) {
+	t.mu.Lock()
+	t.tracks[track.ExternalID] = &track
+	t.mu.Unlock()
+	t.save()
+}
+
+// Link records the Navidrome ID an external track got once Navidrome indexed it.
+func (t *TrackIndex) Link(externalID string, navidromeID string) {
+	t.mu.Lock()
+	track, ok := t.tracks[externalID]
+	changed := ok && track.NavidromeID != navidromeID
+	if changed {
+		track.NavidromeID = navidromeID
+	}
+	t.mu.Unlock()
+	if changed {
+		t.save()
+	}
+}
+
+// Get returns the track downloaded for an external ID.
+func (t *TrackIndex) Get(externalID string) (DownloadedTrack, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	track, ok := t.tracks[externalID]
+	if !ok {
+		return DownloadedTrack{}, false
+	}
+	return *track, true
+}
+
+// Remove forgets the track downloaded for an external ID.
+func (t *TrackIndex) Remove(externalID string) {
+	t.mu.Lock()
+	_, ok := t.tracks[externalID]
+	delete(t.tracks, externalID)
+	t.mu.Unlock()
+	if ok {
+		t.save()
+	}
+}
+
+// ByPath returns the track saved at path.
+func (t *TrackIndex) ByPath(path string) (DownloadedTrack, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, track := range t.tracks {
+		if track.Path == path {
+			return *track, true
+		}
+	}
+	return DownloadedTrack{}, false
+}
+
+// Played records a play of the cached track Navidrome knows as navidromeID, forgetting the plays before
+// since, and returns the track with its plays.
+func (t *TrackIndex) Played(navidromeID string, since time.Time) (DownloadedTrack, bool) {
+	t.mu.Lock()
+	var played *DownloadedTrack
+	for _, track := range t.tracks {
+		if track.NavidromeID == navidromeID && !track.Permanent {
+			played = track
+			break
+		}
+	}
+	if played == nil {
+		t.mu.Unlock()
+		return DownloadedTrack{}, false
+	}
+	plays := []time.Time{time.Now()}
+	for _, at := range played.Plays {
+		if at.After(since) {
+			plays = append(plays, at)
+		}
+	}
+	played.Plays = plays
+	track := *played
+	track.Plays = append([]time.Time(nil), plays...)
+	t.mu.Unlock()
+	t.save()
+	return track, true
+}
+
+// Moved follows a track from oldPath to newPath. Navidrome gives the moved file a new ID, so the old one is
+// forgotten until the track is linked again.
+func (t *TrackIndex) Moved(oldPath string, newPath string, permanent bool) {
+	t.mu.Lock()
+	moved := false
+	for _, track := range t.tracks {
+		if track.Path == oldPath {
+			track.Path = newPath
+			track.Permanent = permanent
+			track.NavidromeID = ""
+			track.Plays = nil
+			moved = true
+		}
+	}
+	t.mu.Unlock()
+	if moved {
+		t.save()
+	}
+}
+
+// RemovePaths forgets the tracks saved to any of paths.
+func (t *TrackIndex) RemovePaths(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	t.mu.Lock()
+	removed := false
+	for id, track := range t.tracks {
+		if slices.Contains(paths, track.Path) {
+			delete(t.tracks, id)
+			removed = true
+		}
+	}
+	t.mu.Unlock()
+	if removed {
+		t.save()
+	}
+}
+
+// Tracks returns the tracks whose file still exists, newest first.
+func (t *TrackIndex) Tracks() []DownloadedTrack {
+	t.mu.Lock()
+	tracks := make([]DownloadedTrack, 0, len(t.tracks))
+	removed := false
+	for id, track := range t.tracks {
+		if _, err := os.Stat(track.Path); os.IsNotExist(err) {
+			delete(t.tracks, id)
+			removed = true
+			continue
+		}
+		tracks = append(tracks, *track)
+	}
+	t.mu.Unlock()
+	if removed {
+		t.save()
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		return tracks[i].DownloadedAt.After(tracks[j].DownloadedAt)
+	})
+	return tracks
+}
+
+func (t *TrackIndex) save() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := util.WriteJSONFile(t.path, t.tracks); err != nil {
+		slog.Error("Error saving track index", "path", t.path, "err", err)
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+)
+
+func TestTrackIndexPlayed(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.DataPath = t.TempDir()
+	index := NewTrackIndex(cfg)
+	index.Add(DownloadedTrack{ExternalID: "1", NavidromeID: "nd-1", Path: "/music/cached/a.mp3",
+		Plays: []time.Time{time.Now().Add(-48 * time.Hour), time.Now().Add(-time.Hour)}})
+	index.Add(DownloadedTrack{ExternalID: "2", NavidromeID: "nd-2", Path: "/music/downloads/b.mp3", Permanent: true})
+
+	tests := []struct {
+		name      string
+		id        string
+		wantOK    bool
+		wantPlays int
+	}{
+		{name: "old plays forgotten", id: "nd-1", wantOK: true, wantPlays: 2},
+		{name: "counted again", id: "nd-1", wantOK: true, wantPlays: 3},
+		{name: "permanent tracks aren't counted", id: "nd-2"},
+		{name: "unknown", id: "nd-3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track, ok := index.Played(tt.id, time.Now().Add(-24*time.Hour))
+			if ok != tt.wantOK || len(track.Plays) != tt.wantPlays {
+				t.Errorf("got %v with %d plays, want %v with %d", ok, len(track.Plays), tt.wantOK, tt.wantPlays)
+			}
+		})
+	}
+}
+
+func TestTrackIndexPersists(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.DataPath = t.TempDir()
+	path := filepath.Join(t.TempDir(), "a.mp3")
+	writeFile(t, path, "audio")
+
+	index := NewTrackIndex(cfg)
+	index.Add(DownloadedTrack{ExternalID: "1", Path: path})
+	index.Add(DownloadedTrack{ExternalID: "2", Path: filepath.Join(t.TempDir(), "gone.mp3")})
+	index.Link("1", "nd-1")
+
+	reloaded := NewTrackIndex(cfg)
+	if track, ok := reloaded.Get("1"); !ok || track.NavidromeID != "nd-1" {
+		t.Errorf("got %+v after reloading", track)
+	}
+	if tracks := reloaded.Tracks(); len(tracks) != 1 || tracks[0].ExternalID != "1" {
+		t.Errorf("got %+v, want the track whose file is gone dropped", tracks)
+	}
+
+	reloaded.RemovePaths([]string{path})
+	if _, ok := reloaded.ByPath(path); ok {
+		t.Error("removed path still indexed")
+	}
+}
@@ -0,0 +1,460 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/metadata"
+	"github.com/GerardPolloRebozado/navifetch/src/metrics"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+	"github.com/GerardPolloRebozado/navifetch/src/util"
+)
+
+const (
+	WantedTrack  = "track"
+	WantedAlbum  = "album"
+	WantedArtist = "artist"
+
+	WantedPending = "pending"
+	WantedDone    = "done"
+	WantedFailed  = "failed"
+
+	// WantedSourceRequest marks items asked for through the API, WantedSourceFailure downloads that failed
+	// while a client was waiting for them.
+	WantedSourceRequest = "request"
+	WantedSourceFailure = "failure"
+
+	// WantedPlaylistID is the ID of the virtual playlist listing the pending tracks.
+	WantedPlaylistID   = "navifetch-wanted"
+	wantedPlaylistName = "Navifetch: Wanted"
+)
+
+// Retry schedule of the wanted list, the delay doubles after every failed attempt.
+const (
+	wantedCheckInterval = time.Minute
+	wantedRetryBase     = 5 * time.Minute
+	wantedRetryMax      = 24 * time.Hour
+	wantedMaxAttempts   = 10
+	// wantedHistory is how long finished items are kept around for the API.
+	wantedHistory = 30 * 24 * time.Hour
+)
+
+var ErrUnknownWantedKind = errors.New("wanted items are tracks, albums or artists")
+
+type WantedItem struct {
+	Key    string `json:"key"`
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Source string `json:"source"`
+	// Parent is the key of the album or artist this item was expanded from.
+	Parent      string              `json:"parent,omitempty"`
+	Song        *model.SubsonicSong `json:"song,omitempty"`
+	Attempts    []WantedAttempt     `json:"attempts"`
+	NextAttempt time.Time           `json:"nextAttempt"`
+	AddedAt     time.Time           `json:"addedAt"`
+	CompletedAt *time.Time          `json:"completedAt,omitempty"`
+}
+
+type WantedAttempt struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+// WantedList keeps tracks, albums and artist discographies to download in the background, retrying failed
+// downloads on a backoff schedule. Albums and artists are expanded into their tracks when processed.
+type WantedList struct {
+	metadata metadata.Provider
+	stream   *StreamService
+	path     string
+
+	mu    sync.Mutex
+	items map[string]*WantedItem
+}
+
+func NewWantedList(cfg *config.Config, metadata metadata.Provider, stream *StreamService) *WantedList {
+	l := &WantedList{
+		metadata: metadata,
+		stream:   stream,
+		path:     filepath.Join(cfg.Server.DataPath, "wanted.json"),
+		items:    make(map[string]*WantedItem),
+	}
+	if err := util.ReadJSONFile(l.path, &l.items); err != nil {
+		slog.Error("Error reading wanted list", "path", l.path, "err", err)
+	}
+	l.countPending()
+	return l
+}
+
+// Start processes the list every minute until ctx is done.
+func (l *WantedList) Start(ctx context.Context) {
+	ticker := time.NewTicker(wantedCheckInterval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				l.Run(ctx)
+			}
+		}
+	}()
+}
+
+// Add puts an external track, album or artist on the list, or schedules an immediate retry when it is
+// already there and not done. An item that had given up starts over with no attempts.
+func (l *WantedList) Add(ctx context.Context, kind string, id string, source string) (*WantedItem, error) {
+	if !strings.HasPrefix(id, "external-") {
+		id = "external-" + id
+	}
+	key := kind + ":" + id
+
+	l.mu.Lock()
+	if existing, ok := l.items[key]; ok && existing.Status != WantedDone {
+		existing.requeue()
+		item := *existing
+		l.mu.Unlock()
+		l.save()
+		return &item, nil
+	}
+	l.mu.Unlock()
+
+	item := &WantedItem{
+		Key:         key,
+		Kind:        kind,
+		ID:          id,
+		Status:      WantedPending,
+		Source:      source,
+		Attempts:    make([]WantedAttempt, 0),
+		NextAttempt: time.Now(),
+		AddedAt:     time.Now(),
+	}
+	if err := l.describe(ctx, item); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	l.items[key] = item
+	copied := *item
+	l.mu.Unlock()
+	l.save()
+	slog.InfoContext(ctx, "Added to the wanted list", "kind", kind, "name", item.Name)
+	return &copied, nil
+}
+
+// AddFailed records a track download that failed while a client was waiting for it.
+func (l *WantedList) AddFailed(id string, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	item, err := l.Add(ctx, WantedTrack, id, WantedSourceFailure)
+	if err != nil {
+		slog.Error("Failed to add to the wanted list", "id", id, "err", err)
+		return
+	}
+	l.mu.Lock()
+	if stored, ok := l.items[item.Key]; ok {
+		stored.Attempts = append(stored.Attempts, WantedAttempt{At: time.Now(), Error: cause.Error()})
+		stored.NextAttempt = time.Now().Add(wantedRetryDelay(len(stored.Attempts)))
+	}
+	l.mu.Unlock()
+	l.save()
+}
+
+// requeue makes the item due now, resetting the attempts of one that had given up so it gets the whole
+// retry schedule again. Callers hold l.mu.
+func (item *WantedItem) requeue() {
+	if item.Status == WantedFailed {
+		item.Attempts = make([]WantedAttempt, 0)
+		item.CompletedAt = nil
+	}
+	item.Status = WantedPending
+	item.NextAttempt = time.Now()
+}
+
+func (l *WantedList) describe(ctx context.Context, item *WantedItem) error {
+	trimmed := strings.TrimPrefix(item.ID, "external-")
+	switch item.Kind {
+	case WantedTrack:
+		song, err := l.metadata.GetSong(ctx, trimmed)
+		if err != nil {
+			return err
+		}
+		item.Song = song
+		item.Name = strings.TrimSuffix(song.Artist, " (external)") + " - " + strings.TrimSuffix(song.Title, " (external)")
+	case WantedAlbum:
+		album, err := l.metadata.GetAlbum(ctx, trimmed)
+		if err != nil {
+			return err
+		}
+		item.Name = album.Artist + " - " + strings.TrimSuffix(album.Name, " (external)")
+	case WantedArtist:
+		artist, err := l.metadata.GetArtist(ctx, trimmed)
+		if err != nil {
+			return err
+		}
+		item.Name = artist.Name
+	default:
+		return ErrUnknownWantedKind
+	}
+	return nil
+}
+
+// Items returns the wanted items, pending ones first and otherwise the most recently added first.
+func (l *WantedList) Items() []WantedItem {
+	l.mu.Lock()
+	items := make([]WantedItem, 0, len(l.items))
+	for _, item := range l.items {
+		items = append(items, *item)
+	}
+	l.mu.Unlock()
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if (items[i].Status == WantedPending) != (items[j].Status == WantedPending) {
+			return items[i].Status == WantedPending
+		}
+		return items[i].AddedAt.After(items[j].AddedAt)
+	})
+	return items
+}
+
+func (l *WantedList) Remove(key string) bool {
+	l.mu.Lock()
+	_, ok := l.items[key]
+	delete(l.items, key)
+	l.mu.Unlock()
+	if ok {
+		l.save()
+	}
+	return ok
+}
+
+// Retry schedules an item, including one that gave up, for the next run.
+func (l *WantedList) Retry(key string) bool {
+	l.mu.Lock()
+	item, ok := l.items[key]
+	if ok && item.Status != WantedDone {
+		item.requeue()
+	}
+	l.mu.Unlock()
+	if ok {
+		l.save()
+	}
+	return ok
+}
+
+// Run processes the items that are due, one at a time to keep yt-dlp and the providers unhurried.
+func (l *WantedList) Run(ctx context.Context) {
+	now := time.Now()
+	l.mu.Lock()
+	due := make([]WantedItem, 0)
+	for key, item := range l.items {
+		if item.Status != WantedPending && item.CompletedAt != nil && now.Sub(*item.CompletedAt) > wantedHistory {
+			delete(l.items, key)
+			continue
+		}
+		if item.Status == WantedPending && !item.NextAttempt.After(now) {
+			due = append(due, *item)
+		}
+	}
+	l.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].AddedAt.Before(due[j].AddedAt) })
+	for _, item := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		err := l.process(ctx, item)
+		if errors.Is(err, ErrShuttingDown) {
+			// Not the item's fault, it stays due for the next run.
+			break
+		}
+		l.finish(item.Key, err)
+	}
+	if len(due) > 0 {
+		l.save()
+	}
+}
+
+func (l *WantedList) process(ctx context.Context, item WantedItem) error {
+	trimmed := strings.TrimPrefix(item.ID, "external-")
+	switch item.Kind {
+	case WantedTrack:
+		_, _, err := l.stream.DownloadTrack(ctx, trimmed, true)
+		return err
+	case WantedAlbum:
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		songs, err := l.metadata.GetAlbumSongs(ctx, trimmed)
+		if err != nil {
+			return err
+		}
+		if len(songs) == 0 {
+			return fmt.Errorf("album has no tracks")
+		}
+		for n := range songs {
+			song := songs[n]
+			l.addChild(item, WantedTrack, song.ID, strings.TrimSuffix(song.Artist, " (external)")+" - "+strings.TrimSuffix(song.Title, " (external)"), &song)
+		}
+		return nil
+	case WantedArtist:
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		artist, err := l.metadata.GetArtist(ctx, trimmed)
+		if err != nil {
+			return err
+		}
+		if len(artist.Album) == 0 {
+			return fmt.Errorf("artist has no releases")
+		}
+		for _, album := range artist.Album {
+			l.addChild(item, WantedAlbum, album.ID, artist.Name+" - "+strings.TrimSuffix(album.Name, " (external)"), nil)
+		}
+		return nil
+	default:
+		return ErrUnknownWantedKind
+	}
+}
+
+// addChild adds an item an album or artist expanded into, unless it's already on the list.
+func (l *WantedList) addChild(parent WantedItem, kind string, id string, name string, song *model.SubsonicSong) {
+	key := kind + ":" + id
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.items[key]; ok {
+		return
+	}
+	l.items[key] = &WantedItem{
+		Key:         key,
+		Kind:        kind,
+		ID:          id,
+		Name:        name,
+		Status:      WantedPending,
+		Source:      parent.Source,
+		Parent:      parent.Key,
+		Song:        song,
+		Attempts:    make([]WantedAttempt, 0),
+		NextAttempt: time.Now(),
+		AddedAt:     time.Now(),
+	}
+}
+
+func (l *WantedList) finish(key string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	item, ok := l.items[key]
+	if !ok {
+		// Removed through the API while it was being processed.
+		return
+	}
+
+	now := time.Now()
+	attempt := WantedAttempt{At: now}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	item.Attempts = append(item.Attempts, attempt)
+
+	if err == nil {
+		item.Status = WantedDone
+		item.CompletedAt = &now
+		slog.Info("Wanted item done", "kind", item.Kind, "name", item.Name)
+		return
+	}
+	if len(item.Attempts) >= wantedMaxAttempts {
+		item.Status = WantedFailed
+		item.CompletedAt = &now
+		slog.Warn("Giving up on wanted item", "kind", item.Kind, "name", item.Name, "attempts", len(item.Attempts), "err", err)
+		return
+	}
+	delay := wantedRetryDelay(len(item.Attempts))
+	item.NextAttempt = now.Add(delay)
+	slog.Warn("Wanted item failed, retrying later", "kind", item.Kind, "name", item.Name, "retry_in", delay, "err", err)
+}
+
+func wantedRetryDelay(attempts int) time.Duration {
+	if attempts > 10 {
+		return wantedRetryMax
+	}
+	return min(wantedRetryBase<<max(attempts-1, 0), wantedRetryMax)
+}
+
+// Playlist returns the virtual playlist of the pending tracks.
+func (l *WantedList) Playlist() *model.SubsonicPlaylist {
+	songs := make([]model.SubsonicSong, 0)
+	var duration int64
+	for _, item := range l.Items() {
+		if item.Kind != WantedTrack || item.Status != WantedPending || item.Song == nil {
+			continue
+		}
+		songs = append(songs, *item.Song)
+		duration += item.Song.Duration
+	}
+	return &model.SubsonicPlaylist{
+		ID:        WantedPlaylistID,
+		Name:      wantedPlaylistName,
+		Comment:   "Tracks Navifetch is still trying to download",
+		Owner:     model.ServerName,
+		SongCount: len(songs),
+		Duration:  duration,
+		Entry:     songs,
+	}
+}
+
+// InjectPlaylist appends the virtual wanted playlist to a Navidrome getPlaylists JSON response, as long as
+// there are pending tracks.
+func (l *WantedList) InjectPlaylist(body []byte) ([]byte, error) {
+	wanted := l.Playlist()
+	if wanted.SongCount == 0 {
+		return body, nil
+	}
+	wanted.Entry = nil
+
+	var resp map[string]any
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	subsonic, ok := resp["subsonic-response"].(map[string]any)
+	if !ok {
+		return body, nil
+	}
+	playlists, ok := subsonic["playlists"].(map[string]any)
+	if !ok {
+		playlists = map[string]any{}
+		subsonic["playlists"] = playlists
+	}
+	existing, _ := playlists["playlist"].([]any)
+	playlists["playlist"] = append(existing, wanted)
+	return json.Marshal(resp)
+}
+
+func (l *WantedList) save() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.countPending()
+	if err := util.WriteJSONFile(l.path, l.items); err != nil {
+		slog.Error("Error saving wanted list", "path", l.path, "err", err)
+	}
+}
+
+// countPending publishes the number of pending items. Callers hold l.mu.
+func (l *WantedList) countPending() {
+	pending := 0
+	for _, item := range l.items {
+		if item.Status == WantedPending {
+			pending++
+		}
+	}
+	metrics.WantedPending.Set(float64(pending))
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+	"github.com/GerardPolloRebozado/navifetch/src/model"
+)
+
+func newTestWantedList(t *testing.T) *WantedList {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Server.DataPath = t.TempDir()
+	provider := &fakeProvider{songs: map[string]*model.SubsonicSong{
+		"1": {ID: "external-1", Artist: "Daft Punk (external)", Title: "Digital Love (external)", Duration: 301},
+		"2": {ID: "external-2", Artist: "Justice (external)", Title: "D.A.N.C.E. (external)", Duration: 242},
+	}}
+	return NewWantedList(cfg, provider, nil)
+}
+
+func TestWantedRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 5 * time.Minute},
+		{1, 5 * time.Minute},
+		{2, 10 * time.Minute},
+		{3, 20 * time.Minute},
+		{9, 1280 * time.Minute},
+		{10, 24 * time.Hour},
+		{11, 24 * time.Hour},
+		{100, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := wantedRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("wantedRetryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWantedFinish(t *testing.T) {
+	tests := []struct {
+		name         string
+		attempts     int
+		err          error
+		wantStatus   string
+		wantAttempts int
+	}{
+		{name: "downloaded", err: nil, wantStatus: WantedDone, wantAttempts: 1},
+		{name: "failed, retried later", attempts: 2, err: errors.New("no match"), wantStatus: WantedPending, wantAttempts: 3},
+		{name: "failed too often", attempts: wantedMaxAttempts - 1, err: errors.New("no match"), wantStatus: WantedFailed, wantAttempts: wantedMaxAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestWantedList(t)
+			item, err := l.Add(context.Background(), WantedTrack, "1", WantedSourceRequest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for range tt.attempts {
+				l.finish(item.Key, errors.New("earlier failure"))
+			}
+			l.finish(item.Key, tt.err)
+
+			got := l.items[item.Key]
+			if got.Status != tt.wantStatus || len(got.Attempts) != tt.wantAttempts {
+				t.Errorf("got %s after %d attempts, want %s after %d", got.Status, len(got.Attempts), tt.wantStatus, tt.wantAttempts)
+			}
+			if tt.wantStatus == WantedPending && !got.NextAttempt.After(time.Now()) {
+				t.Errorf("retry not scheduled, next attempt %s", got.NextAttempt)
+			}
+			if tt.wantStatus != WantedPending && got.CompletedAt == nil {
+				t.Error("finished item has no completion time")
+			}
+		})
+	}
+}
+
+func TestWantedAddRequeues(t *testing.T) {
+	l := newTestWantedList(t)
+	item, err := l.Add(context.Background(), WantedTrack, "external-1", WantedSourceRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Key != "track:external-1" || item.Name != "Daft Punk - Digital Love" {
+		t.Fatalf("got %+v", item)
+	}
+	for range wantedMaxAttempts {
+		l.finish(item.Key, errors.New("no match"))
+	}
+
+	again, err := l.Add(context.Background(), WantedTrack, "1", WantedSourceRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Status != WantedPending || len(again.Attempts) != 0 || again.CompletedAt != nil || len(l.items) != 1 {
+		t.Errorf("got %+v with %d items", again, len(l.items))
+	}
+
+	if _, err := l.Add(context.Background(), "playlist", "1", WantedSourceRequest); !errors.Is(err, ErrUnknownWantedKind) {
+		t.Errorf("got %v, want ErrUnknownWantedKind", err)
+	}
+}
+
+func TestWantedInjectPlaylist(t *testing.T) {
+	l := newTestWantedList(t)
+	body := []byte(`{"subsonic-response":{"status":"ok","playlists":{"playlist":[{"id":"pl-1","name":"Road Trip"}]}}}`)
+
+	got, err := l.InjectPlaylist(body)
+	if err != nil || string(got) != string(body) {
+		t.Fatalf("got %s, %v for an empty wanted list", got, err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		if _, err := l.Add(context.Background(), WantedTrack, id, WantedSourceFailure); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.finish("track:external-2", nil)
+
+	got, err = l.InjectPlaylist(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp model.SubsonicPlaylistsResponse
+	if err := json.Unmarshal(got, &resp); err != nil {
+		t.Fatal(err)
+	}
+	playlists := resp.Subsonic.Playlists.Playlist
+	if len(playlists) != 2 || playlists[1].ID != WantedPlaylistID || playlists[1].SongCount != 1 || playlists[1].Duration != 301 {
+		t.Errorf("got %+v", playlists)
+	}
+}
@@ -2,9 +2,13 @@ package util
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -48,11 +52,61 @@ func GetTrackPath(cfg *config.Config, artist, album, title string, permanent boo
 		folder = "downloads"
 	}
 
-	dir := filepath.Join(cfg.MusicLibraryPath, folder, safeArtist, safeAlbum)
+	dir := filepath.Join(cfg.Downloader.MusicLibraryPath, folder, safeArtist, safeAlbum)
 	filename := fmt.Sprintf("%s.mp3", safeTitle)
 	return filepath.Join(dir, filename)
 }
 
+// StagingDir returns where downloads are put together before being moved to their GetTrackPath. The default
+// is a folder in the data path, out of Navidrome's sight. MoveFile still lands tracks atomically when it's
+// on another filesystem than the library.
+func StagingDir(cfg *config.Config) string {
+	if cfg.Downloader.StagingPath != "" {
+		return cfg.Downloader.StagingPath
+	}
+	return filepath.Join(cfg.Server.DataPath, "staging")
+}
+
+// MoveFile moves src to dst so that dst never exists half-written. Across filesystems, src is first copied
+// next to dst under a hidden temporary name and renamed from there.
+func MoveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
 func SanitizeFilename(s string) string {
 	s = strings.ReplaceAll(s, "/", "-")
 	s = strings.ReplaceAll(s, "\\", "-")
@@ -75,3 +129,57 @@ func IsSongInSubsonicSongList(name string, subsonicList []model.SubsonicSong) bo
 	}
 	return false
 }
+
+func IsAlbumInSubsonicAlbumList(name string, subsonicList []model.SubsonicAlbum) bool {
+	cleanName := cleanAlbumName(name)
+	for _, album := range subsonicList {
+		if cleanName == cleanAlbumName(album.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanAlbumName drops the release type suffixes some stores append to album names.
+func cleanAlbumName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimSuffix(name, " - single")
+	name = strings.TrimSuffix(name, " - ep")
+	return strings.TrimSpace(name)
+}
+
+// ReadJSONFile decodes the JSON file at path into v, a missing file leaves v untouched.
+func ReadJSONFile(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// WriteJSONFile writes v to path through a temporary file so readers never see a partial file.
+func WriteJSONFile(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
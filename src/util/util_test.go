@@ -0,0 +1,71 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GerardPolloRebozado/navifetch/src/config"
+)
+
+func TestStagingDir(t *testing.T) {
+	tests := []struct {
+		name        string
+		stagingPath string
+		want        string
+	}{
+		{name: "default", want: "/data/staging"},
+		{name: "configured", stagingPath: "/music/.staging", want: "/music/.staging"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Server.DataPath = "/data"
+			cfg.Downloader.StagingPath = tt.stagingPath
+			if got := StagingDir(cfg); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  string
+	}{
+		{name: "into an existing folder", dst: "track.mp3"},
+		{name: "into a new folder", dst: "Daft Punk/Discovery/track.mp3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "staged.mp3")
+			if err := os.WriteFile(src, []byte("audio"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			library := t.TempDir()
+			dst := filepath.Join(library, tt.dst)
+
+			if err := MoveFile(src, dst); err != nil {
+				t.Fatal(err)
+			}
+			if data, err := os.ReadFile(dst); err != nil || string(data) != "audio" {
+				t.Errorf("got %q, %v at the destination", data, err)
+			}
+			if _, err := os.Stat(src); !os.IsNotExist(err) {
+				t.Errorf("source still there: %v", err)
+			}
+			entries, _ := os.ReadDir(filepath.Dir(dst))
+			if len(entries) != 1 {
+				t.Errorf("left temporary files behind: %v", entries)
+			}
+		})
+	}
+}
+
+func TestMoveFileMissingSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := MoveFile(filepath.Join(dir, "missing.mp3"), filepath.Join(dir, "out", "track.mp3")); err == nil {
+		t.Error("got no error")
+	}
+}